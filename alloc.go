@@ -0,0 +1,238 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"strings"
+)
+
+// AllocStrategy 表示创建 /ballast 文件的方式
+type AllocStrategy string
+
+const (
+	// AllocAuto 优先使用 fallocate，镜像中没有 fallocate 或者文件系统不支持时使用 dd
+	AllocAuto AllocStrategy = "auto"
+	// AllocFallocate 使用 fallocate 预分配空间，速度最快
+	AllocFallocate AllocStrategy = "fallocate"
+	// AllocDD 使用 dd 写入真实的数据，适用于稀疏文件不占用配额的文件系统，大小按 MB 向下取整
+	AllocDD AllocStrategy = "dd"
+	// AllocTruncate 使用 truncate 创建稀疏文件，只有在稀疏文件也会占用配额的文件系统上才有意义
+	AllocTruncate AllocStrategy = "truncate"
+)
+
+// BallastMode 表示 /ballast 文件是否需要写入真实的数据
+type BallastMode string
+
+const (
+	// BallastSparse 按照 AllocStrategy 创建 /ballast，默认使用 fallocate
+	BallastSparse BallastMode = "sparse"
+	// BallastDense 使用 dd 写入真实的 0，保证 /ballast 一定占用配额
+	BallastDense BallastMode = "dense"
+)
+
+// FillPattern 表示 dd 写入 /ballast 的数据
+type FillPattern string
+
+const (
+	// FillZero 写入 /dev/zero 的 0，开销最小
+	FillZero FillPattern = "zero"
+	// FillRandom 写入 /dev/urandom 的随机数据，开启压缩或者去重的文件系统（例如 ZFS、btrfs 的压缩）无法压缩随机数据，
+	// 可以保证 /ballast 真实占用配额，但是写入速度远低于 fallocate
+	FillRandom FillPattern = "random"
+)
+
+const (
+	// megabyte dd 使用的块大小
+	megabyte = 1000 * 1000
+
+	// defaultAllocTolerance 创建 /ballast 后已用空间的增量默认允许比预期少的百分比
+	defaultAllocTolerance = 5
+)
+
+// validAllocStrategy 判断 s 是否是支持的创建方式
+func validAllocStrategy(s AllocStrategy) bool {
+	switch s {
+	case AllocAuto, AllocFallocate, AllocDD, AllocTruncate:
+		return true
+	}
+	return false
+}
+
+// allocCommand 返回使用 strategy 创建 size 字节的 path 文件的命令，pattern 只对 dd 生效
+func allocCommand(strategy AllocStrategy, pattern FillPattern, path string, size int64) string {
+	switch strategy {
+	case AllocDD:
+		source := "/dev/zero"
+		if pattern == FillRandom {
+			source = "/dev/urandom"
+		}
+		return fmt.Sprintf("dd if=%s of=%s bs=%d count=%d", source, path, megabyte, size/megabyte)
+	case AllocTruncate:
+		return fmt.Sprintf("truncate -s %d %s", size, path)
+	default:
+		return fmt.Sprintf("fallocate -l %d %s", size, path)
+	}
+}
+
+// allocateBallast 按照配置的方式将 /ballast 文件从 current 字节扩大到 size 字节，返回实际使用的方式
+//
+// 使用 AllocAuto 时，如果 fallocate 失败并且不是因为空间不足，会改用 dd 重试。
+// 创建完成后会检查 df 的已用空间是否增加了预期的大小，避免稀疏文件不占用配额导致 /ballast 失效，
+// 增量少于 WithAllocTolerance 允许的误差时返回 ErrBallastIneffective，开启 WithDenseRetry 时会先使用 dd 重新创建一次。
+// 配置了 WithBallastChunkSize 时只会创建或者扩大需要变化的分片。
+// 磁盘空间不足时返回 *InsufficientSpaceError，开启 WithBestEffortBallast 时改为创建剩余空间允许的最大的 /ballast。
+func (dc *DockerContainer) allocateBallast(ctx context.Context, containerID, path string, current, size int64) (AllocStrategy, error) {
+	return dc.allocate(ctx, containerID, path, current, size, dc.bestEffort)
+}
+
+// allocate 实现 allocateBallast，bestEffort 为 true 时空间不足会按照剩余空间重新分配一次
+func (dc *DockerContainer) allocate(ctx context.Context, containerID, path string, current, size int64, bestEffort bool) (AllocStrategy, error) {
+	strategy := dc.initialStrategy()
+	chunks := dc.chunksToAllocate(path, current, size)
+	if dc.dryRun {
+		for _, chunk := range chunks {
+			dc.dryRunf("Would run in container %s: %s", containerID, allocCommand(strategy, dc.fillPattern, chunk.path, chunk.size))
+			if dc.backend == BackendLoop {
+				dc.dryRunf("Would run in container %s: losetup -f %s", containerID, chunk.path)
+			}
+		}
+		return strategy, nil
+	}
+
+	// 自定义的路径所在的目录可能还不存在
+	if dir := pathpkg.Dir(path); dir != "/" && len(chunks) > 0 {
+		if _, err := dc.executeCommand(ctx, containerID, []string{"mkdir", "-p", dir}); err != nil {
+			return strategy, fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	before, err := dc.usedSpace(ctx, containerID)
+	if err != nil {
+		return strategy, err
+	}
+
+	strategy, err = dc.runChunks(ctx, containerID, strategy, chunks)
+	if isNoSpace(err) {
+		return dc.allocateAvailable(ctx, containerID, path, size, strategy, bestEffort, err)
+	}
+	if err != nil {
+		return strategy, err
+	}
+
+	after, err := dc.usedSpace(ctx, containerID)
+	if err != nil {
+		return strategy, err
+	}
+	if !allocEffective(after-before, size-current, dc.allocTolerance) && dc.denseRetry && strategy != AllocDD && dc.backend == BackendFile {
+		dc.logger.Infof("%s allocated with %s in container %s consumed %d bytes, expected %d bytes, retrying with dd", path, strategy, containerID, after-before, size-current)
+		// dd 会覆盖之前创建的文件
+		strategy, err = dc.runChunks(ctx, containerID, AllocDD, chunks)
+		if isNoSpace(err) {
+			return dc.allocateAvailable(ctx, containerID, path, size, strategy, bestEffort, err)
+		}
+		if err != nil {
+			return strategy, err
+		}
+		if after, err = dc.usedSpace(ctx, containerID); err != nil {
+			return strategy, err
+		}
+	}
+	if !allocEffective(after-before, size-current, dc.allocTolerance) {
+		err := fmt.Errorf("%w: %s allocated with %s consumed %d bytes, expected %d bytes", ErrBallastIneffective, path, strategy, after-before, size-current)
+		if strategy == AllocDD && dc.fillPattern != FillRandom {
+			// 写入的 0 被文件系统压缩或者去重了
+			err = fmt.Errorf("%w, the filesystem may compress zeros, consider WithFillPattern(FillRandom)", err)
+		}
+		return strategy, err
+	}
+
+	dc.logger.Infof("Allocated %d bytes %s in container %s using %s", size, path, containerID, strategy)
+	return strategy, nil
+}
+
+// runChunks 使用 strategy 依次创建 chunks，返回实际使用的方式
+//
+// 使用 AllocAuto 时，如果 fallocate 失败并且不是因为空间不足，会改用 dd 重试，之后的分片同样使用 dd。
+func (dc *DockerContainer) runChunks(ctx context.Context, containerID string, strategy AllocStrategy, chunks []ballastChunk) (AllocStrategy, error) {
+	for _, chunk := range chunks {
+		err := dc.runAlloc(ctx, containerID, strategy, chunk.path, chunk.size)
+		if err != nil && strategy == AllocFallocate && dc.allocStrategy == AllocAuto && !isNoSpace(err) {
+			dc.logger.Infof("fallocate failed in container %s, falling back to dd: %v", containerID, err)
+			strategy = AllocDD
+			err = dc.runAlloc(ctx, containerID, strategy, chunk.path, chunk.size)
+		}
+		if err != nil {
+			return strategy, err
+		}
+		if err := dc.attachLoop(ctx, containerID, chunk.path); err != nil {
+			return strategy, err
+		}
+	}
+	return strategy, nil
+}
+
+// allocateAvailable 处理分配 /ballast 时的空间不足，返回 *InsufficientSpaceError，
+// bestEffort 为 true 时在保留 targetFree 剩余空间的前提下将 /ballast 扩大到剩余空间允许的大小
+func (dc *DockerContainer) allocateAvailable(ctx context.Context, containerID, path string, size int64, strategy AllocStrategy, bestEffort bool, cause error) (AllocStrategy, error) {
+	// 失败之前可能已经创建了部分分片，以实际的大小为准
+	current, err := statBallast(dc, ctx, containerID, path)
+	if err != nil && !errors.Is(err, ErrBallastNotFound) {
+		return strategy, fmt.Errorf("failed to get ballast size after %v: %w", cause, err)
+	}
+	usage, err := dc.diskUsage(ctx, containerID)
+	if err != nil {
+		return strategy, fmt.Errorf("failed to get disk usage after %v: %w", cause, err)
+	}
+	spaceErr := &InsufficientSpaceError{Requested: size - current, Available: usage.available, Err: cause}
+	if !bestEffort {
+		return strategy, spaceErr
+	}
+
+	newSize := growBallastSize(current, size, usage.available, dc.targetFree)
+	dc.logger.Infof("Failed to allocate %d bytes %s in container %s: %v, allocating %d bytes instead", size, path, containerID, spaceErr, newSize)
+	if newSize <= current {
+		return strategy, nil
+	}
+	return dc.allocate(ctx, containerID, path, current, newSize, false)
+}
+
+// initialStrategy 返回创建 /ballast 时首先尝试的方式
+func (dc *DockerContainer) initialStrategy() AllocStrategy {
+	switch {
+	case dc.ballastMode == BallastDense, dc.fillPattern == FillRandom:
+		return AllocDD
+	case dc.allocStrategy == AllocAuto:
+		return AllocFallocate
+	}
+	return dc.allocStrategy
+}
+
+// allocEffective 判断已用空间的增量 consumed 是否达到了预期的 expected（允许 tolerancePercent 的误差）
+func allocEffective(consumed, expected int64, tolerancePercent int) bool {
+	if expected <= 0 {
+		return true
+	}
+	return consumed*100 >= expected*int64(100-tolerancePercent)
+}
+
+// runAlloc 在容器内执行创建 /ballast（或者其中一个分片）的命令
+func (dc *DockerContainer) runAlloc(ctx context.Context, containerID string, strategy AllocStrategy, path string, size int64) error {
+	cmd := allocCommand(strategy, dc.fillPattern, path, size)
+	shellCmd, err := dc.shellCommand(ctx, containerID, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to allocate %s with %s: %w", path, strategy, err)
+	}
+	dc.logger.Infof("Executing command in container %s: %s", containerID, cmd)
+	if _, err := dc.executeCommand(ctx, containerID, shellCmd); err != nil {
+		return fmt.Errorf("failed to allocate %s with %s: %w", path, strategy, err)
+	}
+	return nil
+}
+
+// isNoSpace 判断命令是否因为磁盘空间不足而失败
+func isNoSpace(err error) bool {
+	var exitErr *exitError
+	return errors.As(err, &exitErr) && strings.Contains(exitErr.stderr+exitErr.stdout, "No space left on device")
+}
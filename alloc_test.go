@@ -0,0 +1,231 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAllocCommand(t *testing.T) {
+	tests := []struct {
+		strategy AllocStrategy
+		want     string
+	}{
+		{AllocFallocate, "fallocate -l 5000000000 /ballast"},
+		{AllocAuto, "fallocate -l 5000000000 /ballast"},
+		{AllocDD, "dd if=/dev/zero of=/ballast bs=1000000 count=5000"},
+		{AllocTruncate, "truncate -s 5000000000 /ballast"},
+	}
+
+	for _, tt := range tests {
+		if got := allocCommand(tt.strategy, FillZero, ballastPath, 5*gigabyte); got != tt.want {
+			t.Fatalf("allocCommand(%s) = %q, want %q", tt.strategy, got, tt.want)
+		}
+	}
+	if got, want := allocCommand(AllocDD, FillRandom, ballastPath, 5*gigabyte), "dd if=/dev/urandom of=/ballast bs=1000000 count=5000"; got != want {
+		t.Fatalf("allocCommand(dd, random) = %q, want %q", got, want)
+	}
+	// fallocate 不写入数据，pattern 不生效
+	if got, want := allocCommand(AllocFallocate, FillRandom, ballastPath, 5*gigabyte), "fallocate -l 5000000000 /ballast"; got != want {
+		t.Fatalf("allocCommand(fallocate, random) = %q, want %q", got, want)
+	}
+}
+
+func TestAllocateBallastFallsBackToDD(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if len(cmd) == 3 && strings.HasPrefix(cmd[2], "fallocate") {
+			return fakeExecResult{stderr: "/bin/sh: fallocate: not found\n", exitCode: 127}, true
+		}
+		return fakeExecResult{}, false
+	}
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if c := api.container("test"); c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+
+	var usedDD bool
+	for _, cmd := range api.commands {
+		if strings.HasPrefix(cmd[len(cmd)-1], "dd ") {
+			usedDD = true
+		}
+	}
+	if !usedDD {
+		t.Fatalf("commands = %q, want dd", api.commands)
+	}
+}
+
+func TestAllocateBallastNoFallbackOnNoSpace(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if len(cmd) == 3 && strings.HasPrefix(cmd[2], "fallocate") {
+			return fakeExecResult{stderr: "fallocate: fallocate failed: No space left on device\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err == nil {
+		t.Fatal("expected allocation error")
+	}
+	for _, cmd := range api.commands {
+		if strings.HasPrefix(cmd[len(cmd)-1], "dd ") {
+			t.Fatalf("unexpected dd fallback: %q", cmd)
+		}
+	}
+}
+
+// hostFullExecFn 在第一次执行 fallocate 之前把宿主机的剩余空间占到只剩 free 字节
+func hostFullExecFn(free int64) func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+	var filled bool
+	return func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if !filled && len(cmd) == 3 && strings.HasPrefix(cmd[2], "fallocate") {
+			filled = true
+			c.dataUsed = c.limit() - free
+		}
+		return fakeExecResult{}, false
+	}
+}
+
+func TestAllocateBallastInsufficientSpace(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = hostFullExecFn(3 * gigabyte)
+	dc := newTestContainer(api)
+
+	_, err := dc.Run("test")
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("err = %v, want ErrInsufficientSpace", err)
+	}
+	var spaceErr *InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("err = %v, want *InsufficientSpaceError", err)
+	}
+	if spaceErr.Requested != int64(ballastSize) || spaceErr.Available != 3*gigabyte {
+		t.Fatalf("requested = %d, available = %d", spaceErr.Requested, spaceErr.Available)
+	}
+}
+
+func TestAllocateBallastBestEffort(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = hostFullExecFn(3 * gigabyte)
+	dc := newTestContainer(api, WithBestEffortBallast(true))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 保留默认 1GB 的剩余空间
+	if c := api.container("test"); c.ballast != 2*gigabyte {
+		t.Fatalf("ballast = %d, want %d", c.ballast, 2*gigabyte)
+	}
+}
+
+func TestNewDockerContainerInvalidAllocStrategy(t *testing.T) {
+	if _, err := newDockerContainer(WithAllocStrategy("zero")); err == nil {
+		t.Fatal("expected invalid alloc strategy error")
+	}
+}
+
+func TestAllocateBallastDense(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithBallastMode(BallastDense))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range api.commands {
+		if strings.Contains(strings.Join(cmd, " "), "fallocate") {
+			t.Fatalf("unexpected fallocate in dense mode: %q", cmd)
+		}
+	}
+	if c := api.container("test"); c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+}
+
+func TestAllocateBallastFillPattern(t *testing.T) {
+	// 模拟会压缩 0 的文件系统，写入 /dev/zero 不占用空间
+	compressZeros := func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		return fakeExecResult{}, len(cmd) == 3 && strings.HasPrefix(cmd[2], "dd if=/dev/zero")
+	}
+
+	api := newFakeDockerAPI()
+	api.execFn = compressZeros
+	dc := newTestContainer(api, WithBallastMode(BallastDense))
+	if _, err := dc.Run("test"); err == nil || !strings.Contains(err.Error(), "FillRandom") {
+		t.Fatalf("Run() error = %v, want a hint to use FillRandom", err)
+	}
+
+	// 默认仍然使用 fallocate，FillRandom 时使用 dd 写入随机数据
+	api = newFakeDockerAPI()
+	api.execFn = compressZeros
+	dc = newTestContainer(api, WithFillPattern(FillRandom))
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var usedRandom bool
+	for _, cmd := range api.commands {
+		joined := strings.Join(cmd, " ")
+		if strings.Contains(joined, "fallocate") {
+			t.Fatalf("unexpected fallocate with random fill: %q", cmd)
+		}
+		usedRandom = usedRandom || strings.Contains(joined, "dd if=/dev/urandom")
+	}
+	if !usedRandom {
+		t.Fatalf("commands = %q, want dd from /dev/urandom", api.commands)
+	}
+	if c := api.container("test"); c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+
+	if _, err := newDockerContainer(WithFillPattern("ones")); err == nil {
+		t.Fatal("expected invalid fill pattern error")
+	}
+}
+
+func TestAllocateBallastVerifiesConsumption(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithAllocStrategy(AllocTruncate))
+
+	// truncate 创建的是稀疏文件，不会占用空间
+	_, err := dc.Run("test")
+	if !errors.Is(err, ErrBallastIneffective) || !strings.Contains(err.Error(), "expected 5000000000 bytes") {
+		t.Fatalf("expected ineffective ballast error, got %v", err)
+	}
+
+	// 开启 WithDenseRetry 后使用 dd 重新创建
+	api = newFakeDockerAPI()
+	dc = newTestContainer(api, WithAllocStrategy(AllocTruncate), WithDenseRetry(true))
+	result, err := dc.RunWithResult(context.Background(), RunOptions{Name: "test"})
+	if err != nil || result.AllocMethod != AllocDD || result.BallastBytes != int64(ballastSize) {
+		t.Fatalf("RunWithResult() = %+v, %v, want the ballast created with dd", result, err)
+	}
+	if c := api.container("test"); c.sparse {
+		t.Fatal("ballast should not be sparse after the dense retry")
+	}
+
+	for _, tolerance := range []int{-1, 100} {
+		if _, err := newDockerContainer(WithAllocTolerance(tolerance)); err == nil {
+			t.Fatalf("expected invalid alloc tolerance error for %d", tolerance)
+		}
+	}
+}
+
+func TestAllocEffective(t *testing.T) {
+	if !allocEffective(5*gigabyte, 5*gigabyte, defaultAllocTolerance) {
+		t.Fatal("exact consumption should be effective")
+	}
+	if !allocEffective(5*gigabyte-megabyte, 5*gigabyte, defaultAllocTolerance) {
+		t.Fatal("consumption within tolerance should be effective")
+	}
+	if allocEffective(0, 5*gigabyte, defaultAllocTolerance) {
+		t.Fatal("zero consumption should not be effective")
+	}
+	if !allocEffective(0, 0, defaultAllocTolerance) {
+		t.Fatal("nothing expected should be effective")
+	}
+}
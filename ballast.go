@@ -0,0 +1,417 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"regexp"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+)
+
+// usedSpace 获取 /ballast 所在挂载点的已用空间，精确到字节
+func (dc *DockerContainer) usedSpace(ctx context.Context, containerID string) (int64, error) {
+	usage, err := dc.diskUsage(ctx, containerID)
+	if err != nil {
+		return 0, err
+	}
+	return usage.used, nil
+}
+
+// diskUsage 使用 df 获取 /ballast 所在文件系统的使用情况，默认为容器的系统盘
+func (dc *DockerContainer) diskUsage(ctx context.Context, containerID string) (dfUsage, error) {
+	dfOutput, err := dc.executeCommand(ctx, containerID, []string{"df", "-P", "-B1", dc.dfTargetOfContainer(ctx, containerID)})
+	if err != nil {
+		return dfUsage{}, fmt.Errorf("failed to get disk usage: %w", err)
+	}
+
+	usage, err := parseDfOutput(dfOutput)
+	if err != nil {
+		return dfUsage{}, fmt.Errorf("failed to parse df output: %w", err)
+	}
+	return usage, nil
+}
+
+// checkBallast 检查容器的剩余空间，剩余空间小于等于 freeMargin 时调整 /ballast 文件，
+// 返回调整前的已用空间以及 /ballast 减少的字节数
+func (dc *DockerContainer) checkBallast(ctx context.Context, name, containerID, path string, limit int64) (used, reduced int64, err error) {
+	used, err = dc.usedSpace(ctx, containerID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !needsAdjust(limit, used, dc.freeMargin) {
+		return used, 0, nil
+	}
+
+	// 按照剩余空间距离 targetFree 的缺口减少 /ballast，直到剩余空间大于 targetFree
+	// 例如：容器购买时赠送的系统盘大小为 20G，那么实际进行限制的时候是 25G,
+	// 当用户使用到了 19.2G，这时候 df 显示的剩余空间为 0.8G，缺口为 0.2G，默认的 DeficitReduction 会让 /ballast 减少 0.2G + reductionCushion
+	shortfall := deficit(limit, used, dc.targetFree)
+	dc.logger.Infof("Disk usage %s >= threshold %s for container %s, reducing %s by deficit %s", storageSize(used), storageSize(limit-dc.freeMargin), name, path, storageSize(shortfall))
+
+	adjust := adjustBallast
+	if dc.dryRun {
+		adjust = dryRunAdjust
+	}
+	reduced, err = adjust(dc, ctx, name, containerID, path, limit, dc.targetFree, shortfall)
+	if err != nil {
+		return used, reduced, fmt.Errorf("failed to adjust /ballast: %w", err)
+	}
+	return used, reduced, nil
+}
+
+// adjustBallast 将 /ballast 文件减少 WithReductionPolicy 根据缺口 shortfall 计算的大小，
+// 如果 df 显示的剩余空间（limit - 已用空间）仍然没有大于 targetFree，按照新的缺口继续减少，
+// 直到 /ballast 已经被完全删除，返回 /ballast 一共减少的字节数
+//
+// /ballast 已经被用户手动删除时没有可以释放的空间，直接返回，下一次 Start 时会重新创建。
+// 每次成功调整后会调用 onAdjust，失败时调用 onAdjustError。
+func adjustBallast(dc *DockerContainer, ctx context.Context, name, containerID, path string, limit, targetFree, shortfall int64) (reduced int64, err error) {
+	defer dc.observeDuration("adjust", name)()
+	ctx, span := dc.startSpan(ctx, "ballast.adjust", Attribute{attrContainerName, name}, Attribute{attrBallastPath, path})
+	defer func() {
+		span.SetAttributes(Attribute{attrFreedBytes, reduced})
+		endSpan(span, &err)
+	}()
+	defer func() {
+		if err != nil && dc.onAdjustError != nil {
+			dc.onAdjustError(name, err)
+		}
+	}()
+
+	for {
+		oldBallastSize, newBallastSize, err := shrinkBallastBy(dc, ctx, containerID, path, func(current int64) int64 {
+			return dc.reduction(current, shortfall)
+		})
+		reduced += oldBallastSize - newBallastSize
+		if errors.Is(err, ErrBallastNotFound) {
+			dc.logger.Infof("%s not found in container %s, nothing to reduce", path, name)
+			return reduced, nil
+		}
+		if err != nil {
+			return reduced, err
+		}
+		if dc.onAdjust != nil {
+			dc.onAdjust(name, oldBallastSize, newBallastSize)
+		}
+		if newBallastSize == 0 {
+			return reduced, nil
+		}
+
+		used, err := dc.usedSpace(ctx, containerID)
+		if err != nil {
+			return reduced, err
+		}
+		if free := limit - used; free > targetFree {
+			dc.logger.Infof("Free space %s is above target %s after adjusting /ballast", storageSize(free), storageSize(targetFree))
+			return reduced, nil
+		}
+		shortfall = deficit(limit, used, targetFree)
+	}
+}
+
+// deficit 计算剩余空间（limit - used）距离 targetFree 的缺口，剩余空间已经足够时返回 0
+func deficit(limit, used, targetFree int64) int64 {
+	if free := limit - used; free < targetFree {
+		return targetFree - free
+	}
+	return 0
+}
+
+// shrinkBallast 将 path 处的 /ballast 文件减少 reductionBytes 字节，返回调整前后的大小
+//
+// /ballast 会先被删除再重新创建，如果重新创建失败，调整后的大小为 0。
+// 配置了 WithBallastChunkSize 时从最后一个分片开始删除，只有最后保留的分片需要重新创建。
+// 使用 BackendLoop 时删除前会先卸载对应的 loop 设备，否则空间不会被释放。
+func shrinkBallast(dc *DockerContainer, ctx context.Context, containerID, path string, reductionBytes int64) (oldSize, newSize int64, err error) {
+	return shrinkBallastBy(dc, ctx, containerID, path, func(int64) int64 { return reductionBytes })
+}
+
+// shrinkBallastBy 和 shrinkBallast 相同，减少的字节数由 reduction 根据 /ballast 当前的大小计算
+func shrinkBallastBy(dc *DockerContainer, ctx context.Context, containerID, path string, reduction func(current int64) int64) (oldSize, newSize int64, err error) {
+	ballastSizeBytes, err := statBallast(dc, ctx, containerID, path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 计算新的 ballast 大小
+	newBallastSize := shrunkBallastSize(ballastSizeBytes, reduction(ballastSizeBytes))
+
+	// 删除现有 ballast 文件
+	paths, kept := dc.chunksToRemove(path, ballastSizeBytes, newBallastSize)
+	for _, p := range paths {
+		if err := dc.detachLoop(ctx, containerID, p); err != nil {
+			return ballastSizeBytes, ballastSizeBytes, err
+		}
+	}
+	if _, err := dc.executeCommand(ctx, containerID, removeCommand(paths)); err != nil {
+		return ballastSizeBytes, ballastSizeBytes, fmt.Errorf("failed to remove ballast file: %w", err)
+	}
+
+	// 创建新的 ballast 文件（如果新的大小大于 0）
+	if newBallastSize > 0 {
+		if _, err := dc.allocateBallast(ctx, containerID, path, kept, newBallastSize); err != nil {
+			return ballastSizeBytes, kept, fmt.Errorf("failed to create new ballast file: %w", err)
+		}
+		dc.logger.Infof("Reduced %s size to %d bytes", path, newBallastSize)
+	} else {
+		dc.logger.Infof("%s file removed as new size is %d bytes", path, newBallastSize)
+	}
+
+	return ballastSizeBytes, newBallastSize, nil
+}
+
+// GrowBallast 在剩余空间允许的情况下，将 /ballast 文件扩大到 targetBytes
+//
+// /ballast 最大不会超过 ballast 标签中记录的大小（调用过 SetQuota 时为调整后的大小），同时会保留至少 targetFree 的剩余空间，
+// 避免扩大 /ballast 后把用户的磁盘占满。容器必须处于运行状态。
+func (dc *DockerContainer) GrowBallast(ctx context.Context, name string, targetBytes int64) (err error) {
+	defer wrapOp("grow", name, &err)
+	defer dc.lock(name)()
+	return dc.growBallast(ctx, name, targetBytes)
+}
+
+// growBallast 是 GrowBallast 不加锁的实现，调用方需要持有 name 对应的锁
+func (dc *DockerContainer) growBallast(ctx context.Context, name string, targetBytes int64) error {
+	containerInspect, limits, err := dc.inspectLimits(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check container %s: %w", name, err)
+	}
+	if !limits.limited {
+		return fmt.Errorf("container %s has no storage limit", name)
+	}
+
+	ceiling := dc.ballastCeilingOf(containerInspect.ID, limits)
+	return dc.growBallastTo(ctx, name, containerInspect.ID, limits, min(targetBytes, ceiling))
+}
+
+// growBallastTo 将容器 containerID 的 /ballast 扩大到 targetBytes，调用方需要保证 targetBytes 不超过 /ballast 的最大大小
+func (dc *DockerContainer) growBallastTo(ctx context.Context, name, containerID string, limits storageLimits, targetBytes int64) error {
+	limit, path := limits.limit, limits.path
+
+	// /ballast 可能已经在 Stop 时被删除，此时当作 0 处理
+	current, err := statBallast(dc, ctx, containerID, path)
+	if err != nil && !errors.Is(err, ErrBallastNotFound) {
+		return fmt.Errorf("failed to get ballast size of container %s: %w", name, err)
+	}
+	if current >= targetBytes {
+		return nil
+	}
+
+	used, err := dc.usedSpace(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to get disk usage of container %s: %w", name, err)
+	}
+
+	newBallastSize := growBallastSize(current, targetBytes, limit-used, dc.targetFree)
+	if newBallastSize <= current {
+		dc.logger.Infof("Not enough free space to grow /ballast for container %s, free %s", name, storageSize(limit-used))
+		return nil
+	}
+
+	if dc.dryRunf("Would grow %s of container %s from %d to %d bytes", path, name, current, newBallastSize) {
+		return nil
+	}
+	if _, err := dc.allocateBallast(ctx, containerID, path, current, newBallastSize); err != nil {
+		return fmt.Errorf("failed to grow ballast file of container %s: %w", name, err)
+	}
+	dc.logger.Infof("Grew %s size of container %s from %d to %d bytes", path, name, current, newBallastSize)
+
+	return nil
+}
+
+// ShrinkBallast 将运行中容器的 /ballast 文件减少 freeBytes 字节，最多减少到 0，用于在用户需要空间之前手动释放
+//
+// 与 GrowBallast 相对，不检查容器的剩余空间。容器没有运行时返回 ErrContainerNotRunning，
+// /ballast 不存在时返回 ErrBallastNotFound。容器被暂停时同样返回 ErrContainerNotRunning，
+// 开启 WithUpperDirAccess 后改为在宿主机上直接截断可写层中的 /ballast。
+func (dc *DockerContainer) ShrinkBallast(ctx context.Context, name string, freeBytes int64) (err error) {
+	defer wrapOp("shrink", name, &err)
+	defer dc.lock(name)()
+
+	if freeBytes <= 0 {
+		return fmt.Errorf("failed to shrink ballast file of container %s: %w: invalid size %d, must be positive", name, ErrInvalidArgument, freeBytes)
+	}
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return err
+	}
+	if containerInspect.State == nil || !containerInspect.State.Running {
+		return fmt.Errorf("failed to shrink ballast file of container %s: %w", name, ErrContainerNotRunning)
+	}
+	path := dc.ballastPathOf(containerInspect.Config.Labels)
+	if containerInspect.State.Paused {
+		if !dc.upperDirAccess {
+			return fmt.Errorf("failed to shrink ballast file of container %s: %w: container is paused", name, ErrContainerNotRunning)
+		}
+		return dc.shrinkPausedBallast(name, containerInspect, path, freeBytes)
+	}
+
+	if dc.dryRun {
+		current, err := statBallast(dc, ctx, containerInspect.ID, path)
+		if err != nil {
+			return fmt.Errorf("failed to get ballast size of container %s: %w", name, err)
+		}
+		dc.dryRunf("Would shrink %s of container %s from %d to %d bytes", path, name, current, shrunkBallastSize(current, freeBytes))
+		return nil
+	}
+
+	oldSize, newSize, err := shrinkBallast(dc, ctx, containerInspect.ID, path, freeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to shrink ballast file of container %s: %w", name, err)
+	}
+	if dc.onAdjust != nil {
+		dc.onAdjust(name, oldSize, newSize)
+	}
+	dc.logger.Infof("Shrank %s size of container %s from %d to %d bytes", path, name, oldSize, newSize)
+	return nil
+}
+
+// shrinkPausedBallast 在宿主机上缩小暂停的容器的 /ballast，容器恢复后不需要重新调整
+func (dc *DockerContainer) shrinkPausedBallast(name string, containerInspect types.ContainerJSON, path string, freeBytes int64) error {
+	// upperdir 中只有容器的系统盘，卷上的 /ballast 不在其中
+	if mount := dc.mountOf(containerInspect.Config.Labels); mount != rootMount {
+		return fmt.Errorf("failed to shrink ballast file of paused container %s: %s is on mount %s, not in the upperdir", name, path, mount)
+	}
+	upperDir, err := upperDirOf(containerInspect)
+	if err != nil {
+		return fmt.Errorf("failed to shrink ballast file of paused container %s: %w", name, err)
+	}
+	if dc.dryRunf("Would shrink %s of paused container %s by %d bytes in %s", path, name, freeBytes, upperDir) {
+		return nil
+	}
+
+	oldSize, newSize, err := dc.shrinkBallastOnHost(upperDir, path, freeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to shrink ballast file of paused container %s: %w", name, err)
+	}
+	if dc.onAdjust != nil {
+		dc.onAdjust(name, oldSize, newSize)
+	}
+	dc.logger.Infof("Shrank %s size of paused container %s from %d to %d bytes on the host", path, name, oldSize, newSize)
+	return nil
+}
+
+// needsAdjust 判断剩余空间（limit - used）是否已经小于等于 margin
+func needsAdjust(limit, used, margin int64) bool {
+	return limit-used <= margin
+}
+
+// shrunkBallastSize 计算减少 reduction 之后的 /ballast 大小，最小为 0
+func shrunkBallastSize(current, reduction int64) int64 {
+	if reduction >= current {
+		return 0
+	}
+	return current - reduction
+}
+
+// BallastSize 返回容器内 /ballast 文件的大小，单位为字节，文件不存在时返回 ErrBallastNotFound
+func (dc *DockerContainer) BallastSize(ctx context.Context, name string) (_ int64, err error) {
+	defer wrapOp("ballast_size", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := statBallast(dc, ctx, containerInspect.ID, dc.ballastPathOf(containerInspect.Config.Labels))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get ballast size of container %s: %w", name, err)
+	}
+	return size, nil
+}
+
+// statBallast 使用 stat 获取 path 处 /ballast 文件的大小，分片时返回所有分片大小的和
+func statBallast(dc *DockerContainer, ctx context.Context, containerID, path string) (int64, error) {
+	if dc.chunkSize <= 0 {
+		return statFile(dc, ctx, containerID, path)
+	}
+
+	// 分片总是从 <path>.0 开始连续编号
+	var total int64
+	for i := 0; ; i++ {
+		size, err := statFile(dc, ctx, containerID, dc.chunkPath(path, i))
+		if errors.Is(err, ErrBallastNotFound) && i > 0 {
+			return total, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+}
+
+// statFile 使用 stat 获取容器内 path 文件的大小，文件不存在时返回 ErrBallastNotFound
+func statFile(dc *DockerContainer, ctx context.Context, containerID, path string) (int64, error) {
+	statOutput, err := dc.executeCommand(ctx, containerID, []string{"stat", "-c", "%s", path})
+	if err != nil {
+		// stat 在文件不存在和没有权限等情况下的退出码都是 1，使用 test -e 的退出码确认文件是否存在
+		if missing, testErr := fileMissing(dc, ctx, containerID, path); testErr == nil && missing {
+			return 0, fmt.Errorf("%w: %s", ErrBallastNotFound, path)
+		}
+		return 0, fmt.Errorf("failed to get ballast size: %w", err)
+	}
+
+	cleanStatOutput := regexp.MustCompile("[^0-9]").ReplaceAllString(statOutput, "")
+	size, err := strconv.ParseInt(cleanStatOutput, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ballast size: %w", err)
+	}
+	return size, nil
+}
+
+// fileMissing 使用 test -e 判断容器内的 path 是否不存在，test 的退出码为 1 表示不存在
+func fileMissing(dc *DockerContainer, ctx context.Context, containerID, path string) (bool, error) {
+	_, err := dc.executeCommand(ctx, containerID, []string{"test", "-e", path})
+	var exitErr *exitError
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.As(err, &exitErr) && exitErr.code == 1:
+		return true, nil
+	}
+	return false, err
+}
+
+// ballastCeiling 从 ballast 标签中读取 /ballast 的最大大小，
+// 旧版本创建的容器没有该标签，使用默认的 ballastSize
+func (dc *DockerContainer) ballastCeiling(labels map[string]string) int64 {
+	if v, ok := dc.labelValue(labels, labelBallast); ok {
+		if size, err := parseHumanSize(v); err == nil {
+			return size
+		}
+	}
+	return int64(ballastSize)
+}
+
+// ballastPathOf 从 ballast_path 标签中读取 /ballast 文件的路径，
+// 旧版本创建的容器没有该标签，使用默认的 /ballast
+func (dc *DockerContainer) ballastPathOf(labels map[string]string) string {
+	if v, ok := dc.labelValue(labels, labelBallastPath); ok && v != "" {
+		return v
+	}
+	return ballastPath
+}
+
+// validateBallastPath 校验 /ballast 文件的路径，必须是规范的绝对路径，并且不能是根目录
+func validateBallastPath(p string) error {
+	if !pathpkg.IsAbs(p) || pathpkg.Clean(p) != p || p == "/" {
+		return fmt.Errorf("%w: invalid ballast path %q, must be a clean absolute file path", ErrInvalidArgument, p)
+	}
+	return nil
+}
+
+// growBallastSize 计算扩大后的 /ballast 大小，扩大的部分不能占用 reserve 以内的剩余空间
+func growBallastSize(current, target, free, reserve int64) int64 {
+	available := free - reserve
+	if available <= 0 {
+		return current
+	}
+	if current+available < target {
+		return current + available
+	}
+	return target
+}
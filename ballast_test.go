@@ -0,0 +1,213 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGrowBallastSize(t *testing.T) {
+	tests := []struct {
+		name                           string
+		current, target, free, reserve int64
+		want                           int64
+	}{
+		{"enough free space", 1 * gigabyte, 5 * gigabyte, 10 * gigabyte, 1 * gigabyte, 5 * gigabyte},
+		{"limited by free space", 1 * gigabyte, 5 * gigabyte, 3 * gigabyte, 1 * gigabyte, 3 * gigabyte},
+		{"no free space", 1 * gigabyte, 5 * gigabyte, 1 * gigabyte, 1 * gigabyte, 1 * gigabyte},
+		{"disk full", 0, 5 * gigabyte, 0, 1 * gigabyte, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := growBallastSize(tt.current, tt.target, tt.free, tt.reserve); got != tt.want {
+				t.Fatalf("growBallastSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsAdjust(t *testing.T) {
+	limit := int64(25 * gigabyte)
+	tinyMargin := int64(100 * 1000 * 1000)
+
+	if needsAdjust(limit, limit-200*1000*1000, tinyMargin) {
+		t.Fatal("200MB free should not trigger adjustment with a 100MB margin")
+	}
+	if !needsAdjust(limit, limit-50*1000*1000, tinyMargin) {
+		t.Fatal("50MB free should trigger adjustment with a 100MB margin")
+	}
+	if !needsAdjust(limit, limit-gigabyte, defaultFreeMargin) {
+		t.Fatal("1GB free should trigger adjustment with the default margin")
+	}
+}
+
+func TestDeficit(t *testing.T) {
+	limit := int64(25 * gigabyte)
+
+	if got := deficit(limit, limit-800*megabyte, defaultTargetFree); got != 200*megabyte {
+		t.Fatalf("deficit() = %d, want %d", got, 200*megabyte)
+	}
+	if got := deficit(limit, limit, defaultTargetFree); got != defaultTargetFree {
+		t.Fatalf("deficit() = %d, want %d", got, defaultTargetFree)
+	}
+	if got := deficit(limit, limit-2*gigabyte, defaultTargetFree); got != 0 {
+		t.Fatalf("deficit() = %d, want 0", got)
+	}
+}
+
+func TestShrunkBallastSize(t *testing.T) {
+	if got := shrunkBallastSize(5*gigabyte, 500*megabyte); got != 4500000000 {
+		t.Fatalf("shrunkBallastSize() = %d, want 4500000000", got)
+	}
+	// 较大的步长会把 /ballast 完全删除
+	if got := shrunkBallastSize(5*gigabyte, 10*gigabyte); got != 0 {
+		t.Fatalf("shrunkBallastSize() = %d, want 0", got)
+	}
+}
+
+func TestAdjustHooks(t *testing.T) {
+	type adjustment struct {
+		name             string
+		oldSize, newSize int64
+	}
+	var (
+		adjustments []adjustment
+		adjustErrs  []error
+	)
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api,
+		WithOnAdjust(func(name string, oldSize, newSize int64) {
+			adjustments = append(adjustments, adjustment{name, oldSize, newSize})
+		}),
+		WithOnAdjustError(func(name string, err error) {
+			adjustErrs = append(adjustErrs, err)
+		}),
+	)
+
+	for _, name := range []string{"ok", "broken"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+		api.container(name).dataUsed = int64(defaultStorageSize) - 800*megabyte
+	}
+
+	if err := dc.Stop("ok"); err != nil {
+		t.Fatal(err)
+	}
+	want := []adjustment{{"ok", 5 * gigabyte, 4700 * megabyte}}
+	if !reflect.DeepEqual(adjustments, want) {
+		t.Fatalf("adjustments = %+v, want %+v", adjustments, want)
+	}
+	if len(adjustErrs) != 0 {
+		t.Fatalf("unexpected adjust errors: %v", adjustErrs)
+	}
+
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] == "rm" {
+			return fakeExecResult{stderr: "rm: cannot remove '/ballast': Operation not permitted\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+	if _, err := dc.StopWithResult(context.Background(), "broken"); err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustErrs) != 1 {
+		t.Fatalf("expected one adjust error, got %v", adjustErrs)
+	}
+	if len(adjustments) != 1 {
+		t.Fatalf("failed adjustment should not call OnAdjust, got %+v", adjustments)
+	}
+}
+
+func TestStopMissingBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 用户在容器内删除了 /ballast
+	c := api.container("test")
+	c.ballast = -1
+	c.dataUsed = int64(defaultStorageSize.Add(ballastSize)) - 800*megabyte
+
+	result, err := dc.StopWithResult(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AdjustError != nil || result.Adjusted {
+		t.Fatalf("StopWithResult() = %+v, want no adjustment", result)
+	}
+
+	// 释放空间后 Start 会重新创建 /ballast
+	c.dataUsed = 10 * gigabyte
+	if err := dc.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast after Start = %d, want %d", c.ballast, ballastSize)
+	}
+}
+
+func TestStatFile(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.container("test").ballast = -1
+	if _, err := statFile(dc, context.Background(), id, ballastPath); !errors.Is(err, ErrBallastNotFound) {
+		t.Fatalf("statFile() error = %v, want ErrBallastNotFound", err)
+	}
+
+	// stat 因为其他原因失败时不能当作文件不存在
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] == "stat" {
+			return fakeExecResult{stderr: "stat: cannot statx '/ballast': Permission denied\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+	api.container("test").ballast = int64(ballastSize)
+	if _, err := statFile(dc, context.Background(), id, ballastPath); err == nil || errors.Is(err, ErrBallastNotFound) {
+		t.Fatalf("statFile() error = %v, want a non-ErrBallastNotFound error", err)
+	}
+}
+
+func TestShrinkBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+
+	if err := dc.ShrinkBallast(ctx, "test", 2*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 3*gigabyte {
+		t.Fatalf("ballast = %d, want %d", c.ballast, 3*gigabyte)
+	}
+	// 最多减少到 0
+	if err := dc.ShrinkBallast(ctx, "test", 10*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast > 0 {
+		t.Fatalf("ballast = %d, want removed", c.ballast)
+	}
+	if err := dc.ShrinkBallast(ctx, "test", 0); !errors.Is(err, ErrInvalidArgument) || KindOf(err) != KindInvalid {
+		t.Fatalf("expected ErrInvalidArgument for a non-positive size, got %v", err)
+	}
+
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	err := dc.ShrinkBallast(ctx, "test", gigabyte)
+	if !errors.Is(err, ErrContainerNotRunning) || KindOf(err) != KindConflict {
+		t.Fatalf("ShrinkBallast() error = %v, want ErrContainerNotRunning", err)
+	}
+}
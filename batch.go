@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"sync"
+)
+
+// StopAll 使用最多 concurrency 个并发停止多个容器，返回每个容器对应的错误，停止成功的容器对应 nil
+//
+// ctx 被取消后，尚未开始停止的容器不会再被停止，对应的错误为 ctx.Err()。
+// concurrency 小于等于 0 时按 1 处理。
+func (dc *DockerContainer) StopAll(ctx context.Context, names []string, concurrency int) map[string]error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(names))
+		queue   = make(chan string)
+	)
+
+	for i := 0; i < concurrency && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				err := ctx.Err()
+				if err == nil {
+					_, err = dc.StopWithResult(ctx, name)
+				}
+				mu.Lock()
+				results[name] = newOpError("stop", name, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStopAll(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	var names []string
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-%d", i)
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+		// 一半的容器需要调整 /ballast
+		if i%2 == 0 {
+			api.container(name).dataUsed = 19200 * megabyte
+		}
+		names = append(names, name)
+	}
+	names = append(names, "missing")
+
+	results := dc.StopAll(context.Background(), names, 3)
+	if len(results) != len(names) {
+		t.Fatalf("expected %d results, got %d", len(names), len(results))
+	}
+	for _, name := range names[:8] {
+		if err := results[name]; err != nil {
+			t.Errorf("stop %s: %v", name, err)
+		}
+		if api.container(name).json.State.Running {
+			t.Errorf("container %s is still running", name)
+		}
+	}
+	if !errors.Is(results["missing"], ErrContainerNotFound) {
+		t.Errorf("expected ErrContainerNotFound for missing container, got %v", results["missing"])
+	}
+	if got := api.container("test-0").ballast; got != 4700*megabyte {
+		t.Errorf("expected /ballast of test-0 to be reduced to 4.7GB, got %d", got)
+	}
+}
+
+func TestStopAllCanceled(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := dc.StopAll(ctx, []string{"test"}, 0)
+	if !errors.Is(results["test"], context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", results["test"])
+	}
+	if !api.container("test").json.State.Running {
+		t.Fatal("container should not be stopped after cancel")
+	}
+}
@@ -0,0 +1,78 @@
+package container
+
+import "fmt"
+
+// WithBallastChunkSize 将 /ballast 拆分为多个不超过 size 字节的分片文件（/ballast.0、/ballast.1……），
+// 用于单个文件大小有限制的文件系统，默认为 0，表示只使用一个 /ballast 文件
+//
+// 分片的布局由总大小和 size 计算得到，已经创建的容器不能修改 size。
+func WithBallastChunkSize(size int64) Option {
+	return func(dc *DockerContainer) {
+		dc.chunkSize = size
+	}
+}
+
+// ballastChunk 是 /ballast 的一个分片
+type ballastChunk struct {
+	path string
+	size int64
+}
+
+// chunkPath 返回 path 第 i 个分片的路径，不分片时只有 path 一个文件
+func (dc *DockerContainer) chunkPath(path string, i int) string {
+	if dc.chunkSize <= 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// ballastLayout 将 total 字节按照 chunkSize 拆分，除最后一个分片外每个分片都是 chunkSize 字节，
+// chunkSize 小于等于 0 时不拆分
+func ballastLayout(total, chunkSize int64) []int64 {
+	if total <= 0 {
+		return nil
+	}
+	if chunkSize <= 0 {
+		return []int64{total}
+	}
+	layout := make([]int64, 0, (total+chunkSize-1)/chunkSize)
+	for ; total > chunkSize; total -= chunkSize {
+		layout = append(layout, chunkSize)
+	}
+	return append(layout, total)
+}
+
+// chunksToAllocate 返回 /ballast 从 current 字节扩大到 size 字节时需要创建或者扩大的分片
+func (dc *DockerContainer) chunksToAllocate(path string, current, size int64) []ballastChunk {
+	oldLayout := ballastLayout(current, dc.chunkSize)
+	var chunks []ballastChunk
+	for i, chunkSize := range ballastLayout(size, dc.chunkSize) {
+		if i < len(oldLayout) && oldLayout[i] == chunkSize {
+			continue
+		}
+		chunks = append(chunks, ballastChunk{path: dc.chunkPath(path, i), size: chunkSize})
+	}
+	return chunks
+}
+
+// chunksToRemove 返回 /ballast 从 current 字节缩小到 size 字节时需要删除的分片（从后往前），
+// 以及删除后剩余的大小，剩余部分之后需要重新扩大到 size
+func (dc *DockerContainer) chunksToRemove(path string, current, size int64) (paths []string, kept int64) {
+	oldLayout := ballastLayout(current, dc.chunkSize)
+	newLayout := ballastLayout(size, dc.chunkSize)
+
+	k := 0
+	for k < len(oldLayout) && k < len(newLayout) && oldLayout[k] == newLayout[k] {
+		kept += oldLayout[k]
+		k++
+	}
+	for i := len(oldLayout) - 1; i >= k; i-- {
+		paths = append(paths, dc.chunkPath(path, i))
+	}
+	return paths, kept
+}
+
+// removeCommand 返回删除 paths 的命令
+func removeCommand(paths []string) []string {
+	return append([]string{"rm", "-f"}, paths...)
+}
@@ -0,0 +1,88 @@
+package container
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestBallastLayout(t *testing.T) {
+	tests := []struct {
+		total, chunkSize int64
+		want             []int64
+	}{
+		{total: 0, chunkSize: 2, want: nil},
+		{total: 5, chunkSize: 0, want: []int64{5}},
+		{total: 5, chunkSize: 2, want: []int64{2, 2, 1}},
+		{total: 4, chunkSize: 2, want: []int64{2, 2}},
+		{total: 1, chunkSize: 2, want: []int64{1}},
+	}
+	for _, tt := range tests {
+		if got := ballastLayout(tt.total, tt.chunkSize); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ballastLayout(%d, %d) = %v, want %v", tt.total, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestChunksToRemove(t *testing.T) {
+	dc := &DockerContainer{chunkSize: 2}
+	tests := []struct {
+		current, size int64
+		paths         []string
+		kept          int64
+	}{
+		{current: 5, size: 4, paths: []string{"/ballast.2"}, kept: 4},
+		{current: 5, size: 3, paths: []string{"/ballast.2", "/ballast.1"}, kept: 2},
+		{current: 5, size: 0, paths: []string{"/ballast.2", "/ballast.1", "/ballast.0"}, kept: 0},
+		{current: 4, size: 3, paths: []string{"/ballast.1"}, kept: 2},
+	}
+	for _, tt := range tests {
+		paths, kept := dc.chunksToRemove(ballastPath, tt.current, tt.size)
+		if !reflect.DeepEqual(paths, tt.paths) || kept != tt.kept {
+			t.Errorf("chunksToRemove(%d, %d) = %v, %d, want %v, %d", tt.current, tt.size, paths, kept, tt.paths, tt.kept)
+		}
+	}
+
+	single := &DockerContainer{}
+	if paths, kept := single.chunksToRemove(ballastPath, 5, 4); !reflect.DeepEqual(paths, []string{ballastPath}) || kept != 0 {
+		t.Errorf("single file chunksToRemove = %v, %d", paths, kept)
+	}
+}
+
+func TestChunkedBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithBallastChunkSize(2*gigabyte), WithTargetFreeSpace(3200*megabyte))
+	ctx := context.Background()
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	want := map[string]int64{"/ballast.0": 2 * gigabyte, "/ballast.1": 2 * gigabyte, "/ballast.2": 1 * gigabyte}
+	if !reflect.DeepEqual(c.chunks, want) {
+		t.Fatalf("chunks after Run = %v, want %v", c.chunks, want)
+	}
+	if size, err := dc.BallastSize(ctx, "test"); err != nil || size != 5*gigabyte {
+		t.Fatalf("BallastSize = %d, %v", size, err)
+	}
+
+	// 剩余 0.8GB，缺口 2.4GB，减少 2.5GB 后删除最后两个分片，并重新创建 0.5GB 的 /ballast.1
+	c.dataUsed = int64(defaultStorageSize) - 800*megabyte
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]int64{"/ballast.0": 2 * gigabyte, "/ballast.1": 500 * megabyte}
+	if !reflect.DeepEqual(c.chunks, want) {
+		t.Fatalf("chunks after Stop = %v, want %v", c.chunks, want)
+	}
+
+	// 释放空间后 Start 会把分片恢复到 5GB
+	c.dataUsed = 10 * gigabyte
+	if err := dc.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]int64{"/ballast.0": 2 * gigabyte, "/ballast.1": 2 * gigabyte, "/ballast.2": 1 * gigabyte}
+	if !reflect.DeepEqual(c.chunks, want) {
+		t.Fatalf("chunks after Start = %v, want %v", c.chunks, want)
+	}
+}
@@ -0,0 +1,234 @@
+package container
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Close 关闭 Docker 客户端，可以重复调用，之后调用其他方法会返回 ErrClosed
+//
+// 正在进行的操作不会被中断，它们之后的 Docker API 请求同样返回 ErrClosed。
+func (dc *DockerContainer) Close() error {
+	return dc.cli.Close()
+}
+
+// closeGuard 包装 DockerAPI，Close 之后所有请求都返回 ErrClosed，避免使用已经关闭的客户端
+type closeGuard struct {
+	DockerAPI
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// check 返回客户端是否已经关闭
+func (g *closeGuard) check() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close 只关闭一次底层的客户端，重复调用返回 nil
+func (g *closeGuard) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	return g.DockerAPI.Close()
+}
+
+func (g *closeGuard) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	if err := g.check(); err != nil {
+		return container.CreateResponse{}, err
+	}
+	return g.DockerAPI.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, containerName)
+}
+
+func (g *closeGuard) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerStart(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerStop(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerRemove(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerRename(ctx context.Context, containerID, newContainerName string) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerRename(ctx, containerID, newContainerName)
+}
+
+func (g *closeGuard) ContainerPause(ctx context.Context, containerID string) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerPause(ctx, containerID)
+}
+
+func (g *closeGuard) ContainerUnpause(ctx context.Context, containerID string) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.ContainerUnpause(ctx, containerID)
+}
+
+func (g *closeGuard) ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	if err := g.check(); err != nil {
+		errC := make(chan error, 1)
+		errC <- err
+		return make(chan container.WaitResponse), errC
+	}
+	return g.DockerAPI.ContainerWait(ctx, containerID, condition)
+}
+
+func (g *closeGuard) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if err := g.check(); err != nil {
+		return types.ContainerJSON{}, err
+	}
+	return g.DockerAPI.ContainerInspect(ctx, containerID)
+}
+
+func (g *closeGuard) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	return g.DockerAPI.ContainerLogs(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	if err := g.check(); err != nil {
+		return container.StatsResponseReader{}, err
+	}
+	return g.DockerAPI.ContainerStats(ctx, containerID, stream)
+}
+
+func (g *closeGuard) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	return g.DockerAPI.ContainerList(ctx, options)
+}
+
+func (g *closeGuard) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (types.IDResponse, error) {
+	if err := g.check(); err != nil {
+		return types.IDResponse{}, err
+	}
+	return g.DockerAPI.ContainerExecCreate(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	if err := g.check(); err != nil {
+		return types.HijackedResponse{}, err
+	}
+	return g.DockerAPI.ContainerExecAttach(ctx, execID, options)
+}
+
+func (g *closeGuard) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	if err := g.check(); err != nil {
+		return container.ExecInspect{}, err
+	}
+	return g.DockerAPI.ContainerExecInspect(ctx, execID)
+}
+
+func (g *closeGuard) CopyToContainer(ctx context.Context, containerID, path string, content io.Reader, options container.CopyToContainerOptions) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.CopyToContainer(ctx, containerID, path, content, options)
+}
+
+func (g *closeGuard) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	if err := g.check(); err != nil {
+		return nil, container.PathStat{}, err
+	}
+	return g.DockerAPI.CopyFromContainer(ctx, containerID, srcPath)
+}
+
+func (g *closeGuard) ContainerCommit(ctx context.Context, containerID string, options container.CommitOptions) (types.IDResponse, error) {
+	if err := g.check(); err != nil {
+		return types.IDResponse{}, err
+	}
+	return g.DockerAPI.ContainerCommit(ctx, containerID, options)
+}
+
+func (g *closeGuard) ContainerExport(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	return g.DockerAPI.ContainerExport(ctx, containerID)
+}
+
+func (g *closeGuard) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	if err := g.check(); err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	return g.DockerAPI.ImageInspectWithRaw(ctx, imageID)
+}
+
+func (g *closeGuard) ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	return g.DockerAPI.ImagePull(ctx, ref, options)
+}
+
+func (g *closeGuard) Info(ctx context.Context) (system.Info, error) {
+	if err := g.check(); err != nil {
+		return system.Info{}, err
+	}
+	return g.DockerAPI.Info(ctx)
+}
+
+func (g *closeGuard) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	if err := g.check(); err != nil {
+		return types.DiskUsage{}, err
+	}
+	return g.DockerAPI.DiskUsage(ctx, options)
+}
+
+func (g *closeGuard) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	if err := g.check(); err != nil {
+		return network.Inspect{}, err
+	}
+	return g.DockerAPI.NetworkInspect(ctx, networkID, options)
+}
+
+func (g *closeGuard) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	if err := g.check(); err != nil {
+		return network.CreateResponse{}, err
+	}
+	return g.DockerAPI.NetworkCreate(ctx, name, options)
+}
+
+func (g *closeGuard) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.DockerAPI.NetworkConnect(ctx, networkID, containerID, config)
+}
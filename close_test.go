@@ -0,0 +1,37 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := dc.Close(); err != nil {
+			t.Fatalf("Close() #%d error = %v", i+1, err)
+		}
+	}
+	if api.closed != 1 {
+		t.Fatalf("client closed %d times, want 1", api.closed)
+	}
+
+	if _, err := dc.Run("another"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Run() after Close error = %v, want ErrClosed", err)
+	}
+	if err := dc.Stop("test"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Stop() after Close error = %v, want ErrClosed", err)
+	}
+	if _, err := dc.WaitStopped(context.Background(), "test"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("WaitStopped() after Close error = %v, want ErrClosed", err)
+	}
+	if api.container("another") != nil {
+		t.Fatal("container should not be created after Close")
+	}
+}
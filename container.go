@@ -2,20 +2,22 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/dustin/go-humanize"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
-
-	"k8s.io/klog"
 )
 
 type storageSize int64
@@ -28,265 +30,905 @@ func (s storageSize) Add(delta storageSize) storageSize {
 	return storageSize(int64(s) + int64(delta))
 }
 
+// gigabytesToBytes 将十进制的 GB 转换为字节数，四舍五入到整数字节，避免 int64(gb * gigabyte) 截断浮点误差
+func gigabytesToBytes(gb float64) int64 {
+	return int64(math.Round(gb * gigabyte))
+}
+
+// 单位约定：所有的空间大小（标签、StorageOpt、df 的输出以及 /ballast 的调整）在内部都使用 int64 的字节数，
+// 只有面向用户的 GB 参数（WithReductionStep）和日志使用十进制的 GB，与 humanize 保持一致，不使用 GiB。
 const (
+	// gigabyte 与 humanize 保持一致，使用十进制的 GB
+	gigabyte = 1000 * 1000 * 1000
+
+	// ballastPath 默认的 /ballast 文件路径，旧版本创建的容器没有 ballast_path 标签时也使用该路径
 	ballastPath = "/ballast"
 
-	defaultStorageSize storageSize = 20 * 1000 * 1000 * 1000
+	// defaultLabelPrefix 标签的默认命名空间，避免与其他工具设置的 threshold 等标签冲突，
+	// 实际的标签为 io.ballast/threshold、io.ballast/ballast 等
+	defaultLabelPrefix = "io.ballast/"
+
+	// labelThreshold 记录容器的系统盘限制（StorageSize + BallastSize），单位为字节
+	labelThreshold = "threshold"
+	// labelBallast 记录创建容器时 /ballast 的大小，也是 GrowBallast 能扩大到的最大值
+	labelBallast = "ballast"
+	// labelBaseStorage 记录创建容器时用户可用的系统盘大小，不包括 /ballast
+	labelBaseStorage = "base_storage"
+	// labelBallastPath 记录创建容器时 /ballast 文件的路径，Stop、Start 和 GrowBallast 按照该路径调整 /ballast
+	labelBallastPath = "path"
+	// labelExecUser 记录在容器内执行 /ballast 相关命令时使用的用户
+	labelExecUser = "exec_user"
+	// labelMount 记录 /ballast 所在的挂载点，Stop 等使用 df 检查该挂载点的剩余空间，没有该标签时为根目录
+	labelMount = "mount"
+	// legacyLabelBallastPath 是旧版本创建的容器中没有命名空间的 ballast_path 标签
+	legacyLabelBallastPath = "ballast_path"
+
+	defaultStorageSize storageSize = 20 * gigabyte
+
+	ballastSize storageSize = 5 * gigabyte
 
-	ballastSize storageSize = 5 * 1000 * 1000 * 1000
+	defaultImage = "ubuntu:latest"
+
+	defaultFreeMargin = 1 * gigabyte
+
+	defaultTargetFree = 1 * gigabyte
+
+	// reductionCushion 调整 /ballast 时在缺口之外多释放的空间，保证调整后的剩余空间严格大于 targetFree
+	reductionCushion = 100 * 1000 * 1000
 )
 
+// defaultCmd 没有设置启动命令和 entrypoint 时使用的命令，只用于占住 /ballast，不会自己退出
+var defaultCmd = []string{"sleep", "infinity"}
+
 type Container interface {
 	Run(name string) (id string, err error)
+	RunWithOptions(ctx context.Context, opts RunOptions) (id string, err error)
+	RunWithResult(ctx context.Context, opts RunOptions) (RunResult, error)
 	Remove(name string) error
+	ForceRemove(name string) error
 	Stop(name string) error
+	StopWithResult(ctx context.Context, name string) (StopResult, error)
+	StopAll(ctx context.Context, names []string, concurrency int) map[string]error
 	Start(name string) error
+	StartWithID(ctx context.Context, name string) (id string, err error)
+	WaitStopped(ctx context.Context, name string) (exitCode int64, err error)
+	WaitRemoved(ctx context.Context, name string) error
+	WaitHealthy(ctx context.Context, name string) error
+	Restart(ctx context.Context, name string) error
+	Rename(ctx context.Context, oldName, newName string) error
+	Pause(ctx context.Context, name string) error
+	Unpause(ctx context.Context, name string) error
+	Adjust(ctx context.Context, name string) (reducedBytes int64, err error)
+	GrowBallast(ctx context.Context, name string, targetBytes int64) error
+	ShrinkBallast(ctx context.Context, name string, freeBytes int64) error
+	SetQuota(ctx context.Context, name string, newSize int64) error
+	BallastSize(ctx context.Context, name string) (int64, error)
+	GetLabel(ctx context.Context, name, key string) (string, bool, error)
+	SetLabel(ctx context.Context, name, key, value string) error
+	Inspect(ctx context.Context, name string) (Info, error)
+	Reconcile(ctx context.Context, name string) (ReconcileResult, error)
+	ReconcileAll(ctx context.Context) ([]ReconcileResult, error)
+	FromSpec(ctx context.Context, r io.Reader) ([]string, error)
+	PullImage(ctx context.Context, ref string, progress io.Writer) error
+	DiskUsage(ctx context.Context, name string) (used, total, free int64, err error)
+	HostDiskInfo(ctx context.Context) (driver string, total, available int64, err error)
+	SelfTest(ctx context.Context) (SelfTestReport, error)
+	Commit(ctx context.Context, name, ref string) (imageID string, err error)
+	Export(ctx context.Context, name string) (io.ReadCloser, error)
+	Monitor(ctx context.Context, name string, interval time.Duration) error
+	WatchPressure(ctx context.Context, name string, freeThreshold int64) (<-chan PressureEvent, error)
+	List(ctx context.Context, filters map[string]string) ([]ContainerInfo, error)
+	PruneStopped(ctx context.Context, olderThan time.Duration) ([]string, error)
+	Logs(ctx context.Context, name string, follow bool) (io.ReadCloser, error)
+	Exec(ctx context.Context, name string, cmd []string) (stdout, stderr string, exitCode int, err error)
+	ExecStream(ctx context.Context, name string, cmd []string, stdout, stderr io.Writer) (exitCode int, err error)
+	Stats(ctx context.Context, name string, stream bool) (<-chan Stat, error)
+	CopyTo(ctx context.Context, name, dstPath string, content io.Reader) error
+	CopyFrom(ctx context.Context, name, srcPath string) (io.ReadCloser, error)
 	Close() error
 }
 
+// DockerContainer 是 Container 基于 Docker API 的实现
+//
+// DockerContainer 可以同时被多个 goroutine 使用，管理多个容器时应当在整个进程中共享一个实例，
+// 这样所有容器共用同一个 Docker 客户端和它的连接池。同一个容器名称上的 Run、Remove、Stop 以及 /ballast 的调整
+// 按照名称加锁串行执行，不同容器之间互不影响。
 type DockerContainer struct {
-	cli *client.Client
+	cli DockerAPI
+
+	image string
+	// cmd 和 entrypoint 为空时使用镜像中的配置，两者都为空时使用 defaultCmd
+	cmd        []string
+	entrypoint []string
+	// autoPull 镜像不存在时是否自动拉取
+	autoPull bool
+	// registryAuth 拉取镜像时使用的认证信息，为 base64 编码的 registry.AuthConfig
+	registryAuth string
+	// credentialHelper 不为空时每次拉取镜像都使用它返回的认证信息
+	credentialHelper CredentialHelper
+	// networkAutoCreate 为 true 时自动创建 RunOptions.Networks 中不存在的网络
+	networkAutoCreate bool
+	// upperDirAccess 为 true 时允许在宿主机上修改暂停的容器的可写层
+	upperDirAccess bool
+	// tracer 为 Run、Stop 等操作创建 span
+	tracer Tracer
+	// observer 记录 Run、Stop 等操作的耗时
+	observer DurationObserver
+	// execUser 在容器内执行 /ballast 相关命令时默认使用的用户，已经创建的容器以 exec_user 标签为准
+	execUser string
+	// allocStrategy 创建 /ballast 文件的方式
+	allocStrategy AllocStrategy
+	// ballastMode 为 BallastDense 时始终使用 dd 写入真实数据
+	ballastMode BallastMode
+	// allocTolerance 创建 /ballast 后已用空间的增量允许比预期少的百分比
+	allocTolerance int
+	// denseRetry 为 true 时 /ballast 没有占用预期的空间会使用 dd 重新创建
+	denseRetry bool
+	// fillPattern dd 写入 /ballast 的数据，为 FillRandom 时始终使用 dd
+	fillPattern FillPattern
+	// chunkSize 大于 0 时 /ballast 被拆分为多个不超过 chunkSize 的分片
+	chunkSize int64
+	// backend 为 BackendLoop 时 /ballast 会被挂载为 loop 设备
+	backend BallastBackend
+	// labelPrefix 写入标签时使用的命名空间，读取时兼容旧版本没有命名空间的标签
+	labelPrefix string
+	// disableBallast 为 true 时创建的容器不限制系统盘大小，也不创建 /ballast
+	disableBallast bool
+	// keepOnFailure 为 true 时 Run 失败后保留容器
+	keepOnFailure bool
+	// ballastOptional 为 true 时 Run 创建 /ballast 失败后容器继续运行
+	ballastOptional bool
+	// idempotentStartStop 为 true 时 Start 已经运行的容器、Stop 已经停止的容器直接返回，不返回错误
+	idempotentStartStop bool
+	// snapshotStripBallast 为 true 时 Commit 和 Export 之前会删除 /ballast，完成后再恢复
+	snapshotStripBallast bool
+	// bestEffort 为 true 时空间不足会创建尽可能大的 /ballast，而不是返回 ErrInsufficientSpace
+	bestEffort bool
+	// ballastPath 新创建的容器中 /ballast 文件的路径，已经创建的容器以 ballast_path 标签为准
+	ballastPath string
+	// quotaDir 在宿主机上保存 SetQuota 配额的目录，为空时只保存在内存中
+	quotaDir string
+	// shell 在容器内执行组合命令使用的 shell，为空时自动检查
+	shell string
+	// tls 连接 tcp:// 地址时使用的证书，只对 NewDockerContainerWithHost 生效
+	tls *tlsFiles
+	// dockerContext NewDockerContainer 使用的 Docker 上下文，为空时按照 DOCKER_HOST、DOCKER_CONTEXT 和 Docker 配置选择
+	dockerContext string
+	// retry 不为空时调用 Docker API 遇到临时错误会重试
+	retry *RetryPolicy
+	// readyTimeout 启动容器后等待容器就绪的最长时间
+	readyTimeout time.Duration
+	// readyProbe 等待容器就绪时是否需要成功执行一次 exec
+	readyProbe bool
+
+	// reductionPolicy 调整 /ballast 时计算每一轮减少的字节数
+	reductionPolicy ReductionPolicy
+	// freeMargin Stop 时剩余空间小于等于该值就会调整 /ballast，单位为字节
+	freeMargin int64
+	// targetFree 调整 /ballast 后期望的最小剩余空间，单位为字节
+	targetFree int64
+	// dryRun 为 true 时只输出将要执行的操作
+	dryRun bool
+	// stopTimeout 停止容器时等待 SIGTERM 的时间，为空时使用 daemon 的默认值
+	stopTimeout *time.Duration
+	// logger 输出日志使用的 Logger
+	logger Logger
+
+	// onAdjust 和 onAdjustError 在调整 /ballast 成功或者失败后调用
+	onAdjust      func(name string, oldSize, newSize int64)
+	onAdjustError func(name string, err error)
+
+	// monitors 记录正在运行 Monitor 的容器，避免同一个容器启动多个 Monitor
+	mu       sync.Mutex
+	monitors map[string]struct{}
+
+	// labelCache 缓存每个容器 ID 对应的标签，避免每次执行命令前都重新 inspect 容器
+	labelCache sync.Map
+	// shellCache 缓存每个容器 ID 中检查到的 shell
+	shellCache sync.Map
+	// quotas 缓存每个容器 ID 对应的 SetQuota 配额
+	quotas sync.Map
+
+	// locks 保存每个容器名称对应的锁，保证同一个容器的创建、删除和 /ballast 调整串行执行
+	locksMu sync.Mutex
+	locks   map[string]*nameLock
+}
+
+// NewDockerContainer 创建连接到本机 Docker daemon 的 DockerContainer，地址的优先级从高到低为：
+// WithDockerContext 设置的上下文、DOCKER_HOST、DOCKER_CONTEXT、Docker 配置中的 currentContext，
+// 都没有设置时连接默认的 unix socket。需要直接指定地址时使用 NewDockerContainerWithHost，它会忽略以上所有设置。
+func NewDockerContainer(opts ...Option) (Container, error) {
+	dc, err := newDockerContainer(opts...)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts, err := dc.envClientOpts()
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.NewClientWithOpts(append(clientOpts, client.WithAPIVersionNegotiation())...)
+	if err != nil {
+		return nil, err
+	}
+	dc.setClient(cli)
+	return dc, nil
 }
 
-func NewDockerContainer() (Container, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// NewWithClient 使用指定的 Docker 客户端创建 Container
+func NewWithClient(api DockerAPI, opts ...Option) (Container, error) {
+	dc, err := newDockerContainer(opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &DockerContainer{cli: cli}, nil
+	dc.setClient(api)
+	return dc, nil
+}
+
+// newDockerContainer 使用默认配置和 opts 创建 DockerContainer 并校验配置
+func newDockerContainer(opts ...Option) (*DockerContainer, error) {
+	dc := &DockerContainer{
+		image:           defaultImage,
+		autoPull:        true,
+		allocStrategy:   AllocAuto,
+		ballastMode:     BallastSparse,
+		fillPattern:     FillZero,
+		allocTolerance:  defaultAllocTolerance,
+		ballastPath:     ballastPath,
+		execUser:        defaultExecUser,
+		labelPrefix:     defaultLabelPrefix,
+		backend:         BackendFile,
+		reductionPolicy: DeficitReduction(),
+		freeMargin:      defaultFreeMargin,
+		targetFree:      defaultTargetFree,
+		readyTimeout:    defaultReadyTimeout,
+		logger:          KlogLogger{},
+		tracer:          noopTracer{},
+		observer:        noopObserver{},
+		monitors:        make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(dc)
+	}
+	if err := dc.validate(); err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+// validate 校验调整 /ballast 相关的配置
+func (dc *DockerContainer) validate() error {
+	if err := validateReductionPolicy(dc.reductionPolicy); err != nil {
+		return err
+	}
+	if dc.freeMargin <= 0 {
+		return fmt.Errorf("invalid free margin %d, must be positive", dc.freeMargin)
+	}
+	if dc.targetFree <= 0 {
+		return fmt.Errorf("invalid target free space %d, must be positive", dc.targetFree)
+	}
+	if !validAllocStrategy(dc.allocStrategy) {
+		return fmt.Errorf("invalid alloc strategy %q", dc.allocStrategy)
+	}
+	if dc.ballastMode != BallastSparse && dc.ballastMode != BallastDense {
+		return fmt.Errorf("invalid ballast mode %q", dc.ballastMode)
+	}
+	if dc.allocTolerance < 0 || dc.allocTolerance >= 100 {
+		return fmt.Errorf("invalid alloc tolerance %d%%, must be in [0, 100)", dc.allocTolerance)
+	}
+	if strings.ContainsAny(dc.shell, " \t\n") {
+		return fmt.Errorf("invalid shell %q, must be a path without arguments", dc.shell)
+	}
+	if dc.fillPattern != FillZero && dc.fillPattern != FillRandom {
+		return fmt.Errorf("invalid fill pattern %q", dc.fillPattern)
+	}
+	if dc.chunkSize < 0 {
+		return fmt.Errorf("invalid ballast chunk size %d, must not be negative", dc.chunkSize)
+	}
+	if err := validateBallastPath(dc.ballastPath); err != nil {
+		return err
+	}
+	if dc.backend != BackendFile && dc.backend != BackendLoop {
+		return fmt.Errorf("invalid ballast backend %q", dc.backend)
+	}
+	if dc.readyTimeout <= 0 {
+		return fmt.Errorf("invalid ready timeout %s, must be positive", dc.readyTimeout)
+	}
+	if dc.retry != nil && (dc.retry.BaseDelay < 0 || dc.retry.Jitter < 0 || dc.retry.Jitter > 1) {
+		return fmt.Errorf("invalid retry policy %+v", *dc.retry)
+	}
+	return nil
+}
+
+// setClient 设置 Docker 客户端，配置了重试策略时为客户端增加重试，Close 之后客户端的所有请求返回 ErrClosed
+func (dc *DockerContainer) setClient(api DockerAPI) {
+	if dc.retry != nil && dc.retry.MaxAttempts > 1 {
+		api = &retryAPI{DockerAPI: api, policy: *dc.retry, logger: dc.logger}
+	}
+	dc.cli = &closeGuard{DockerAPI: api}
 }
 
 func (dc *DockerContainer) Run(name string) (string, error) {
-	createResponse, err := dc.cli.ContainerCreate(context.TODO(),
-		&container.Config{
-			Image:     "ubuntu:latest",
-			Cmd:       []string{"sleep", "3600"},
-			OpenStdin: true,
-			Tty:       true,
-			Labels: map[string]string{
-				"threshold": defaultStorageSize.Add(ballastSize).String(),
-			},
-		},
-		&container.HostConfig{
-			StorageOpt: map[string]string{
-				//"size": defaultStorageSize.Add(ballastSize).String(),
-			},
-		},
-		&network.NetworkingConfig{},
-		&ocispec.Platform{},
-		name,
-	)
+	return dc.RunWithOptions(context.TODO(), RunOptions{Name: name})
+}
+
+// RunWithOptions 按照 opts 创建并启动容器，然后在容器内创建 /ballast 文件
+func (dc *DockerContainer) RunWithOptions(ctx context.Context, opts RunOptions) (string, error) {
+	result, err := dc.RunWithResult(ctx, opts)
+	return result.ID, err
+}
+
+// RunResult 是 RunWithResult 的结果，记录了容器实际得到的保护
+type RunResult struct {
+	// ID 容器的 ID
+	ID string `json:"id"`
+	// BallastBytes 实际创建的 /ballast 的大小，没有创建 /ballast 时为 0
+	BallastBytes int64 `json:"ballast_bytes"`
+	// AllocMethod 创建 /ballast 实际使用的方式，AllocAuto 回退到 dd 时为 AllocDD，没有创建 /ballast 时为空
+	AllocMethod AllocStrategy `json:"alloc_method,omitempty"`
+	// QuotaApplied 是否通过 storage-opt size 限制了容器系统盘的大小
+	QuotaApplied bool `json:"quota_applied"`
+}
+
+// RunWithResult 与 RunWithOptions 相同，同时返回 /ballast 的实际大小、创建方式以及是否限制了系统盘大小，
+// 可以用于审计或者计费
+//
+// BallastBytes 是创建后通过 stat 得到的大小，dd 按 MB 向下取整或者 WithBestEffortBallast 时可能小于 BallastSize。
+// opts.BallastOptional 时创建 /ballast 失败同样返回容器 ID，BallastBytes 为 0，error 为 *BallastWarning。
+// OnConflict 为 ConflictReuse 并且复用了已经存在的容器时只设置 ID 和 QuotaApplied。
+func (dc *DockerContainer) RunWithResult(ctx context.Context, opts RunOptions) (_ RunResult, err error) {
+	opts = dc.withDefaults(opts)
+	name := opts.Name
+	defer wrapOp("run", name, &err)
+	defer dc.lock(name)()
+	defer dc.observeDuration("run", name)()
+	ctx, span := dc.startSpan(ctx, "ballast.run",
+		Attribute{attrContainerName, name}, Attribute{attrImage, opts.Image}, Attribute{attrBallastBytes, opts.BallastSize})
+	defer endSpan(span, &err)
+
+	if err := validateName(name); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container: %w", err)
+	}
+	if err := validateBallastPath(opts.BallastPath); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := validateBallastMount(opts); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := dc.validateLabels(opts.Labels); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := validateResources(opts); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := validateHealthcheck(opts); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := validateNetwork(opts); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := validateNetworks(opts); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if !opts.DisableBallast {
+		if err := dc.checkBallastSupport(ctx, opts.BallastMount == rootMount); err != nil {
+			return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+		}
+	}
+	if err := dc.ensureImage(ctx, opts.Image); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+	if err := dc.ensureNetworks(ctx, opts.Networks); err != nil {
+		return RunResult{}, fmt.Errorf("failed to run container %s: %w", name, err)
+	}
+
+	config, hostConfig := dc.buildContainerConfig(opts)
+	if dc.dryRunf("Would create container %s from image %s with storage-opt size=%s and labels %v", name, opts.Image, hostConfig.StorageOpt["size"], config.Labels) {
+		if opts.DisableBallast {
+			return RunResult{}, nil
+		}
+		for _, chunk := range dc.chunksToAllocate(opts.BallastPath, 0, opts.BallastSize) {
+			dc.dryRunf("Would run in container %s: %s", name, allocCommand(dc.initialStrategy(), dc.fillPattern, chunk.path, chunk.size))
+		}
+		return RunResult{}, nil
+	}
+	createResponse, err := dc.cli.ContainerCreate(ctx, config, hostConfig, &network.NetworkingConfig{}, &ocispec.Platform{}, name)
+	if err != nil && errdefs.IsConflict(err) {
+		switch opts.OnConflict {
+		case ConflictReuse:
+			// 直接复用已经存在的容器，不再重新创建 /ballast
+			existing, err := dc.inspectContainer(ctx, name)
+			if err != nil {
+				return RunResult{}, err
+			}
+			dc.logger.Infof("Container %s already exists, reusing %s", name, existing.ID)
+			return RunResult{ID: existing.ID, QuotaApplied: existing.HostConfig != nil && existing.HostConfig.StorageOpt["size"] != ""}, nil
+		case ConflictReplace:
+			dc.logger.Infof("Container %s already exists, removing and recreating it", name)
+			id := dc.containerID(ctx, name)
+			if err := dc.cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true}); err != nil {
+				return RunResult{}, fmt.Errorf("failed to remove existing container %s: %w", name, err)
+			}
+			if id != "" {
+				dc.forgetQuota(id)
+			}
+			dc.forgetLabels(name)
+			createResponse, err = dc.cli.ContainerCreate(ctx, config, hostConfig, &network.NetworkingConfig{}, &ocispec.Platform{}, name)
+		default:
+			return RunResult{}, fmt.Errorf("failed to create container %s: %w: %v", name, ErrNameConflict, err)
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create container %s: %w", name, err)
+		return RunResult{}, fmt.Errorf("failed to create container %s: %w", name, err)
 	}
+	dc.rememberLabels(createResponse.ID, name, config)
+	result := RunResult{ID: createResponse.ID, QuotaApplied: hostConfig.StorageOpt["size"] != ""}
 
-	if err := dc.cli.ContainerStart(context.TODO(), createResponse.ID, container.StartOptions{}); err != nil {
-		_ = dc.cli.ContainerRemove(context.TODO(), createResponse.ID, container.RemoveOptions{})
-		return "", fmt.Errorf("failed to start container %s: %w", name, err)
+	if err := dc.connectNetworks(ctx, createResponse.ID, opts); err != nil {
+		return RunResult{}, dc.cleanupFailedRun(ctx, opts, createResponse.ID, err)
 	}
 
-	cmd := fmt.Sprintf("fallocate -l %s %s", ballastSize.String(), ballastPath)
-	klog.Infof("Executing command in container %s: %s", name, cmd)
+	if err := dc.cli.ContainerStart(ctx, createResponse.ID, container.StartOptions{}); err != nil {
+		return RunResult{}, dc.cleanupFailedRun(ctx, opts, createResponse.ID, fmt.Errorf("failed to start container %s: %w", name, err))
+	}
 
-	if _, err = dc.executeCommand(createResponse.ID, []string{"/bin/bash", "-c", cmd}); err != nil {
-		_ = dc.cli.ContainerRemove(context.TODO(), createResponse.ID, container.RemoveOptions{})
-		return "", fmt.Errorf("failed to execute command in container %s: %w", name, err)
+	if opts.DisableBallast {
+		dc.logger.Infof("Successfully ran container %s without ballast", name)
+		return result, nil
 	}
 
-	klog.Infof("Successfully ran container %s", name)
+	// 容器刚启动时可能还无法执行命令，等待容器就绪后再创建 /ballast
+	if err := dc.waitReady(ctx, createResponse.ID); err != nil {
+		return RunResult{}, dc.cleanupFailedRun(ctx, opts, createResponse.ID, fmt.Errorf("failed to wait for container %s: %w", name, err))
+	}
 
-	return createResponse.ID, nil
+	strategy, err := dc.allocateBallast(ctx, createResponse.ID, opts.BallastPath, 0, opts.BallastSize)
+	if err != nil {
+		if opts.BallastOptional {
+			dc.logger.Errorf("Failed to create %s in container %s, keeping it running without ballast: %v", opts.BallastPath, name, err)
+			return result, &BallastWarning{Container: name, ID: createResponse.ID, Err: err}
+		}
+		return RunResult{}, dc.cleanupFailedRun(ctx, opts, createResponse.ID, fmt.Errorf("failed to execute command in container %s: %w", name, err))
+	}
+
+	result.AllocMethod = strategy
+	// WithBestEffortBallast 时空间不足可能没有创建 /ballast
+	switch size, err := statBallast(dc, ctx, createResponse.ID, opts.BallastPath); {
+	case errors.Is(err, ErrBallastNotFound):
+		result.AllocMethod = ""
+	case err != nil:
+		dc.logger.Errorf("Failed to get ballast size of container %s, reporting the requested size: %v", name, err)
+		result.BallastBytes = opts.BallastSize
+	default:
+		result.BallastBytes = size
+	}
+
+	dc.logger.Infof("Successfully ran container %s", name)
+
+	return result, nil
+}
+
+// cleanupFailedRun 处理 RunWithOptions 创建后启动或者创建 /ballast 失败的容器 id，返回包装后的 cause
+//
+// 默认强制删除容器；opts.KeepOnFailure 时停止并保留容器，返回的 OpError 中记录了容器 ID。
+// 使用新的 context，ctx 已经被取消时同样可以清理。
+func (dc *DockerContainer) cleanupFailedRun(ctx context.Context, opts RunOptions, id string, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	if !opts.KeepOnFailure {
+		if err := dc.cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil {
+			dc.logger.Errorf("Failed to remove container %s after failed run: %v", opts.Name, err)
+		}
+		dc.forgetQuota(id)
+		dc.forgetLabels(id)
+		return cause
+	}
+
+	if err := dc.cli.ContainerStop(ctx, id, dc.stopOptions()); err != nil {
+		dc.logger.Errorf("Failed to stop container %s after failed run: %v", opts.Name, err)
+	}
+	dc.logger.Infof("Keeping container %s (%s) for inspection after failed run", opts.Name, id)
+	return &OpError{Op: "run", Container: opts.Name, ID: id, Kind: KindOf(cause), Err: cause}
+}
+
+// buildContainerConfig 根据 opts 生成创建容器所需的配置
+//
+// 实际限制的系统盘大小为 StorageSize + BallastSize，同时以字节数记录在 threshold 标签中，
+// /ballast 的大小记录在 ballast 标签中，作为 Start 恢复 /ballast 时的上限，路径记录在 ballast_path 标签中。
+// StorageOpt 中同样直接使用字节数，避免 Docker 按照 1024 进制解析 GB 单位导致与标签不一致。
+// DisableBallast 时不设置 StorageOpt 和这些标签，hasStorageLimit 因此返回 false。
+func (dc *DockerContainer) buildContainerConfig(opts RunOptions) (*container.Config, *container.HostConfig) {
+	limit := storageSize(opts.StorageSize).Add(storageSize(opts.BallastSize))
+
+	labels := make(map[string]string, len(opts.Labels)+4)
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	storageOpt := map[string]string{}
+	if !opts.DisableBallast {
+		labels[dc.labelKey(labelThreshold)] = strconv.FormatInt(int64(limit), 10)
+		labels[dc.labelKey(labelBallast)] = strconv.FormatInt(opts.BallastSize, 10)
+		labels[dc.labelKey(labelBaseStorage)] = strconv.FormatInt(opts.StorageSize, 10)
+		labels[dc.labelKey(labelBallastPath)] = opts.BallastPath
+		labels[dc.labelKey(labelExecUser)] = opts.ExecUser
+		labels[dc.labelKey(labelMount)] = opts.BallastMount
+		// /ballast 在卷上时限制的是卷的空间，不需要限制系统盘
+		if opts.BallastMount == rootMount {
+			storageOpt["size"] = strconv.FormatInt(int64(limit), 10)
+		}
+	}
+
+	config := &container.Config{
+		Image:       opts.Image,
+		Entrypoint:  opts.Entrypoint,
+		Cmd:         opts.Cmd,
+		Env:         opts.Env,
+		User:        opts.User,
+		OpenStdin:   true,
+		Tty:         true,
+		Labels:      labels,
+		Healthcheck: healthConfig(opts),
+	}
+	hostConfig := &container.HostConfig{
+		StorageOpt:  storageOpt,
+		Mounts:      opts.Mounts,
+		Privileged:  opts.Privileged,
+		NetworkMode: container.NetworkMode(opts.NetworkMode),
+		DNS:         opts.DNS,
+		ExtraHosts:  opts.ExtraHosts,
+		Resources: container.Resources{
+			Memory:   opts.Memory,
+			NanoCPUs: opts.NanoCPUs,
+		},
+	}
+	if opts.PidsLimit > 0 {
+		hostConfig.PidsLimit = &opts.PidsLimit
+	}
+	return config, hostConfig
 }
 
+// Remove 删除已经停止的容器，容器不存在时不返回错误，容器正在运行时返回 ErrContainerRunning，
+// 需要删除正在运行的容器时使用 ForceRemove
 func (dc *DockerContainer) Remove(name string) error {
-	err := dc.cli.ContainerRemove(context.TODO(), name, container.RemoveOptions{Force: true})
-	if err != nil && !strings.Contains(err.Error(), "No such container") {
+	return dc.remove(name, false)
+}
+
+// ForceRemove 删除容器，容器正在运行时会先将其杀死
+func (dc *DockerContainer) ForceRemove(name string) error {
+	return dc.remove(name, true)
+}
+
+// remove 删除容器，容器不存在时不返回错误；force 为 false 时，容器正在运行会返回 ErrContainerRunning
+func (dc *DockerContainer) remove(name string, force bool) (err error) {
+	defer wrapOp("remove", name, &err)
+	defer dc.lock(name)()
+
+	if dc.dryRunf("Would remove container %s (force: %v)", name, force) {
+		return nil
+	}
+	id := dc.containerID(context.TODO(), name)
+	err = dc.cli.ContainerRemove(context.TODO(), name, container.RemoveOptions{Force: force})
+	if err != nil && !errdefs.IsNotFound(err) {
+		if !force && errdefs.IsConflict(err) {
+			return fmt.Errorf("failed to remove container %s: %w: %v", name, ErrContainerRunning, err)
+		}
 		return fmt.Errorf("failed to remove container %s: %w", name, err)
 	}
+	if id != "" {
+		dc.forgetQuota(id)
+	}
+	dc.forgetLabels(name)
 	return nil
 }
 
+// Start 启动容器，并将 /ballast 恢复到创建时的大小（受剩余空间限制）
 func (dc *DockerContainer) Start(name string) error {
-	return dc.cli.ContainerStart(context.TODO(), name, container.StartOptions{})
+	_, err := dc.StartWithID(context.TODO(), name)
+	return err
+}
+
+// StartWithID 与 Start 相同，同时返回容器的 ID，启动后获取容器信息失败时 ID 为空
+//
+// 容器已经在运行时返回 ErrAlreadyRunning，不会再次恢复 /ballast。检查状态、启动和恢复 /ballast 期间持有容器的锁，
+// 同一个容器上的 Stop 会等待 Start 完成。
+func (dc *DockerContainer) StartWithID(ctx context.Context, name string) (_ string, err error) {
+	defer wrapOp("start", name, &err)
+	defer dc.lock(name)()
+
+	if dc.dryRunf("Would start container %s and restore its ballast", name) {
+		return "", nil
+	}
+
+	current, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to start container %s: %w", name, err)
+	}
+	if current.State != nil && current.State.Running {
+		if dc.idempotentStartStop {
+			dc.logger.Infof("Container %s is already running", name)
+			return current.ID, nil
+		}
+		return "", fmt.Errorf("failed to start container %s: %w", name, ErrAlreadyRunning)
+	}
+
+	if err := dc.cli.ContainerStart(ctx, name, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start container %s: %w", name, wrapNotFound(err))
+	}
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		dc.logger.Errorf("Failed to check container %s: %v", name, err)
+		return "", nil
+	}
+	limits, err := dc.limitsOf(name, containerInspect.Config.Labels)
+	if err != nil {
+		dc.logger.Errorf("Failed to check container %s: %v", name, err)
+		return containerInspect.ID, nil
+	}
+	if !limits.limited {
+		return containerInspect.ID, nil
+	}
+
+	// 上一次 Stop 可能减小或者删除了 /ballast，这里尽量恢复，失败时不影响容器启动
+	if err := dc.waitReady(ctx, containerInspect.ID); err != nil {
+		dc.logger.Errorf("Failed to restore /ballast for container %s: %v", name, err)
+		return containerInspect.ID, nil
+	}
+	if _, err := dc.reconcile(ctx, name); err != nil {
+		dc.logger.Errorf("Failed to restore /ballast for container %s: %v", name, err)
+	}
+	return containerInspect.ID, nil
 }
 
 // Stop 停止容器并根据磁盘使用情况调整 /ballast 文件
 func (dc *DockerContainer) Stop(name string) error {
+	_, err := dc.StopWithResult(context.TODO(), name)
+	return err
+}
+
+// StopResult 描述 StopWithResult 中 /ballast 的调整情况
+type StopResult struct {
+	// ID 容器的 ID
+	ID string `json:"id"`
+	// Adjusted 是否减小了 /ballast
+	Adjusted bool `json:"adjusted"`
+	// ReducedBytes /ballast 减少的字节数
+	ReducedBytes int64 `json:"reduced_bytes"`
+	// UsedBytes 调整前的已用空间，单位为字节
+	UsedBytes int64 `json:"used_bytes"`
+	// FreeBytes 调整前的剩余空间，单位为字节
+	FreeBytes int64 `json:"free_bytes"`
+	// AdjustError 检查或者调整 /ballast 失败的原因，此时容器仍然会被停止
+	AdjustError error `json:"-"`
+}
+
+// StopWithResult 停止容器并根据磁盘使用情况调整 /ballast 文件，返回 /ballast 的调整情况
+//
+// 调整 /ballast 失败不会阻止容器停止，失败原因记录在 StopResult.AdjustError 中，
+// 只有停止容器失败时才会返回 error。容器已经停止时不会检查磁盘，返回 ErrAlreadyStopped。
+func (dc *DockerContainer) StopWithResult(ctx context.Context, name string) (_ StopResult, err error) {
+	defer wrapOp("stop", name, &err)
+	defer dc.lock(name)()
+	defer dc.observeDuration("stop", name)()
+	ctx, span := dc.startSpan(ctx, "ballast.stop", Attribute{attrContainerName, name})
+	defer endSpan(span, &err)
+
+	var result StopResult
+
 	var stopFn = func(name string) error {
-		timeout := container.StopOptions{}
-		if err := dc.cli.ContainerStop(context.TODO(), name, timeout); err != nil {
-			return fmt.Errorf("failed to stop container %s: %w", name, err)
+		if dc.dryRunf("Would stop container %s", name) {
+			return nil
+		}
+		if err := dc.cli.ContainerStop(ctx, name, dc.stopOptions()); err != nil {
+			return fmt.Errorf("failed to stop container %s: %w", name, wrapNotFound(err))
 		}
 		return nil
 	}
 
-	size, limited, err := dc.hasStorageLimit(name)
+	containerInspect, err := dc.inspectContainer(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to check container %s: %w", name, err)
+		return result, fmt.Errorf("failed to check container %s: %w", name, err)
 	}
+	result.ID = containerInspect.ID
 
-	if !limited {
-		// 如果容器没有被限制系统盘空间，直接停止容器
-		err = stopFn(name)
-		if err != nil {
-			return fmt.Errorf("failed to stop container %s: %w", name, err)
+	if containerInspect.State != nil && !containerInspect.State.Running {
+		// 已经停止的容器无法执行 df，也不需要调整 /ballast
+		if dc.idempotentStartStop {
+			dc.logger.Infof("Container %s is already stopped", name)
+			return result, nil
 		}
-		return nil
+		return result, fmt.Errorf("failed to stop container %s: %w", name, ErrAlreadyStopped)
 	}
 
-	// 否则容器停止前，检查一下磁盘使用情况
-	containerInspect, err := dc.cli.ContainerInspect(context.TODO(), name)
+	limits, err := dc.limitsOf(name, containerInspect.Config.Labels)
 	if err != nil {
-		return fmt.Errorf("failed to inspect container %s: %w", name, err)
+		return result, fmt.Errorf("failed to check container %s: %w", name, err)
 	}
 
-	dfOutput, err := dc.executeCommand(containerInspect.ID, []string{"df", "--block-size=1G", "/"})
-	if err != nil {
-		klog.Errorf("Failed to get disk usage for container %s: %v", name, err)
-		err = stopFn(name)
-		if err != nil {
-			return fmt.Errorf("failed to stop container %s: %w", name, err)
-		}
-		return nil
+	if !limits.limited {
+		// 如果容器没有被限制系统盘空间，直接停止容器
+		return result, stopFn(name)
 	}
 
-	// 解析 df 命令的输出
-	used, err := parseDfOutput(dfOutput)
-	if err != nil {
-		klog.Errorf("Failed to parse df output for container %s: %v", name, err)
-	} else if size-used <= 1 {
-		// 如果磁盘使用情况小于阈值，则调整 /ballast 文件
-		// 每次减少 0.5 GB
-		// 例如：容器购买时赠送的系统盘大小为 20G，那么实际进行限制的时候是 25G,
-		// 当用户使用到了 19G，这时候 df 显示的剩余空间为 1G，就会触发调整 /ballast 的操作
-		var reductionGB = 0.5
-		klog.Infof("Disk usage %dG >= threshold %dG for container %s, reducing /ballast by %fG", used, size, name, reductionGB)
-
-		if err := adjustBallast(dc, context.TODO(), containerInspect.ID, reductionGB); err != nil {
-			klog.Errorf("Failed to adjust /ballast for container %s: %v", name, err)
+	// 否则容器停止前，检查一下磁盘使用情况
+
+	if containerInspect.State != nil && containerInspect.State.Paused {
+		// 暂停的容器无法执行命令，跳过 /ballast 的调整
+		dc.logger.Infof("Container %s is paused, skipping /ballast adjustment", name)
+	} else {
+		used, reduced, err := dc.checkBallast(ctx, name, containerInspect.ID, limits.path, limits.limit)
+		if err != nil {
+			dc.logger.Errorf("Failed to check /ballast for container %s: %v", name, err)
+			result.AdjustError = err
+		}
+		if used > 0 {
+			result.UsedBytes = used
+			result.FreeBytes = limits.limit - used
 		}
+		result.Adjusted = reduced > 0
+		result.ReducedBytes = reduced
+		span.SetAttributes(Attribute{attrUsedBytes, used}, Attribute{attrFreedBytes, reduced})
 	}
 
 	// 停止容器
-	err = stopFn(name)
-	if err != nil {
-		return err
+	if err := stopFn(name); err != nil {
+		return result, err
 	}
 
-	klog.Infof("Successfully stopped container %s", name)
-
-	return nil
-}
+	dc.logger.Infof("Successfully stopped container %s", name)
 
-func (dc *DockerContainer) Close() error {
-	return dc.cli.Close()
+	return result, nil
 }
 
-func (dc *DockerContainer) hasStorageLimit(name string) (size int64, hasLimited bool, err error) {
-	containerInspect, err := dc.cli.ContainerInspect(context.TODO(), name)
-	if err != nil {
-		return 0, false, fmt.Errorf("failed to inspect container %s: %w", name, err)
+// stopOptions 将 stopTimeout 转换为 ContainerStop 的参数，不足 1 秒的部分向上取整，
+// 小于等于 0 时为 0，表示直接发送 SIGKILL
+func (dc *DockerContainer) stopOptions() container.StopOptions {
+	if dc.stopTimeout == nil {
+		return container.StopOptions{}
 	}
-
-	if v, ok := containerInspect.Config.Labels["threshold"]; !ok {
-		return 0, false, nil
-	} else {
-		size, _ = strconv.ParseInt(strings.Split(v, "GB")[0], 10, 64)
-		return size, true, nil
+	seconds := 0
+	if *dc.stopTimeout > 0 {
+		seconds = int((*dc.stopTimeout + time.Second - 1) / time.Second)
 	}
+	return container.StopOptions{Timeout: &seconds}
 }
 
-// executeCommand 在容器内执行命令并返回输出
-func (dc *DockerContainer) executeCommand(containerID string, cmd []string) (string, error) {
-	execConfig := types.ExecConfig{
-		AttachStdout: true,
-		AttachStderr: true,
-		Cmd:          cmd,
+// Restart 先按照 Stop 的逻辑调整 /ballast 并停止容器，再按照 Start 的逻辑启动容器并恢复 /ballast
+//
+// 停止失败时容器保持原来的状态；停止成功但启动失败时，容器处于停止状态，此时可以直接重试 Start。
+// 容器已经停止时直接启动。
+func (dc *DockerContainer) Restart(ctx context.Context, name string) (err error) {
+	defer wrapOp("restart", name, &err)
+
+	if _, err := dc.StopWithResult(ctx, name); err != nil && !errors.Is(err, ErrAlreadyStopped) {
+		return fmt.Errorf("failed to restart container %s: %w", name, err)
 	}
-	execIDResp, err := dc.cli.ContainerExecCreate(context.TODO(), containerID, execConfig)
-	if err != nil {
-		return "", fmt.Errorf("failed to create exec: %w", err)
+	if _, err := dc.StartWithID(ctx, name); err != nil {
+		return fmt.Errorf("failed to restart container %s, container is stopped: %w", name, err)
 	}
+	return nil
+}
 
-	execAttachResp, err := dc.cli.ContainerExecAttach(context.TODO(), execIDResp.ID, types.ExecStartCheck{})
-	if err != nil {
-		return "", fmt.Errorf("failed to attach exec: %w", err)
-	}
-	defer execAttachResp.Close()
+// Rename 重命名容器，容器本身和 /ballast 文件都不会改变，threshold 等标签会原样保留
+//
+// 新名称已经被占用时返回 ErrNameConflict，原容器不存在时返回 ErrContainerNotFound。
+func (dc *DockerContainer) Rename(ctx context.Context, oldName, newName string) (err error) {
+	defer wrapOp("rename", oldName, &err)
 
-	output, err := io.ReadAll(execAttachResp.Reader)
-	if err != nil {
-		return "", fmt.Errorf("failed to read exec output: %w", err)
+	if err := validateName(newName); err != nil {
+		return fmt.Errorf("failed to rename container %s: %w", oldName, err)
 	}
 
-	execInspect, err := dc.cli.ContainerExecInspect(context.TODO(), execIDResp.ID)
-	if err != nil {
-		return "", fmt.Errorf("failed to inspect exec: %w", err)
+	if dc.dryRunf("Would rename container %s to %s", oldName, newName) {
+		return nil
 	}
-	if execInspect.ExitCode != 0 {
-		return "", fmt.Errorf("command exited with code %d: %s", execInspect.ExitCode, string(output))
+	if err := dc.cli.ContainerRename(ctx, oldName, newName); err != nil {
+		if errdefs.IsConflict(err) {
+			return fmt.Errorf("failed to rename container %s to %s: %w: %v", oldName, newName, ErrNameConflict, err)
+		}
+		return fmt.Errorf("failed to rename container %s to %s: %w", oldName, newName, wrapNotFound(err))
 	}
+	// 缓存中记录的是旧的名称，下一次使用时重新读取
+	dc.forgetLabels(oldName)
+
+	dc.logger.Infof("Successfully renamed container %s to %s", oldName, newName)
+	return nil
+}
 
-	return string(output), nil
+// Pause 暂停容器内的所有进程
+//
+// 暂停期间无法在容器内执行命令，Stop 和 Monitor 都会跳过 /ballast 的调整。
+func (dc *DockerContainer) Pause(ctx context.Context, name string) (err error) {
+	defer wrapOp("pause", name, &err)
+
+	if dc.dryRunf("Would pause container %s", name) {
+		return nil
+	}
+	if err := dc.cli.ContainerPause(ctx, name); err != nil {
+		return fmt.Errorf("failed to pause container %s: %w", name, wrapNotFound(err))
+	}
+	return nil
 }
 
-// parseDfOutput 解析 df 命令的输出，返回已用空间（GB）
-func parseDfOutput(output string) (int64, error) {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) < 2 {
-		return 0, fmt.Errorf("unexpected df output format")
+// Unpause 恢复被暂停的容器
+func (dc *DockerContainer) Unpause(ctx context.Context, name string) (err error) {
+	defer wrapOp("unpause", name, &err)
+
+	if dc.dryRunf("Would unpause container %s", name) {
+		return nil
 	}
+	if err := dc.cli.ContainerUnpause(ctx, name); err != nil {
+		return fmt.Errorf("failed to unpause container %s: %w", name, wrapNotFound(err))
+	}
+	return nil
+}
 
-	fields := strings.Fields(lines[1])
-	if len(fields) < 3 {
-		return 0, fmt.Errorf("unexpected df output fields")
+// inspectContainer 获取容器详情，容器不存在时返回 ErrContainerNotFound
+func (dc *DockerContainer) inspectContainer(ctx context.Context, name string) (types.ContainerJSON, error) {
+	containerInspect, err := dc.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return types.ContainerJSON{}, fmt.Errorf("failed to inspect container %s: %w", name, wrapNotFound(err))
 	}
+	dc.rememberLabels(containerInspect.ID, containerInspect.Name, containerInspect.Config)
+	return containerInspect, nil
+}
 
-	usedStr := fields[2]
-	used, err := strconv.ParseInt(usedStr, 10, 64)
+func (dc *DockerContainer) hasStorageLimit(name string) (size int64, hasLimited bool, err error) {
+	_, limits, err := dc.inspectLimits(context.TODO(), name)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse used disk size: %w", err)
+		return 0, false, err
 	}
+	return limits.limit, limits.limited, nil
+}
 
-	return used, nil
+// storageLimits 汇总容器标签中记录的系统盘限制和 /ballast 的信息，避免调用方重复 inspect 容器和读取标签
+type storageLimits struct {
+	// limit threshold 标签中系统盘的限制大小，单位为字节，limited 为 false 时其他字段没有意义
+	limit   int64
+	limited bool
+	// ceiling ballast 标签中记录的 /ballast 大小，不包括 SetQuota 的调整，需要时使用 ballastCeilingOf
+	ceiling int64
+	// path /ballast 文件的路径
+	path string
 }
 
-// adjustBallast 调整 /ballast 文件的大小，减少指定的 GB 数量
-func adjustBallast(dc *DockerContainer, ctx context.Context, containerID string, reductionGB float64) error {
-	// 获取当前 ballast 文件大小
-	statOutput, err := dc.executeCommand(containerID, []string{"stat", "-c", "%s", ballastPath})
+// inspectLimits 获取容器详情，同时从标签中读取 storageLimits，只 inspect 一次容器
+func (dc *DockerContainer) inspectLimits(ctx context.Context, name string) (types.ContainerJSON, storageLimits, error) {
+	containerInspect, err := dc.inspectContainer(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to get ballast size: %w", err)
+		return types.ContainerJSON{}, storageLimits{}, err
 	}
-
-	cleanStatOutput := regexp.MustCompile("[^0-9]").ReplaceAllString(statOutput, "")
-	ballastSizeBytes, err := strconv.ParseInt(cleanStatOutput, 10, 64)
+	limits, err := dc.limitsOf(name, containerInspect.Config.Labels)
 	if err != nil {
-		return fmt.Errorf("failed to parse ballast size: %w", err)
+		return types.ContainerJSON{}, storageLimits{}, err
 	}
+	return containerInspect, limits, nil
+}
 
-	// 计算新的 ballast 大小（减少 reductionGB）
-	reductionBytes := int64(reductionGB * 1000 * 1000 * 1000)
-	newBallastSize := ballastSizeBytes - reductionBytes
-	if newBallastSize < 0 {
-		newBallastSize = 0
+// limitsOf 从容器 name 的标签中读取 storageLimits
+func (dc *DockerContainer) limitsOf(name string, labels map[string]string) (storageLimits, error) {
+	limit, limited, err := dc.storageLimit(name, labels)
+	if err != nil {
+		return storageLimits{}, err
 	}
+	return storageLimits{
+		limit:   limit,
+		limited: limited,
+		ceiling: dc.ballastCeiling(labels),
+		path:    dc.ballastPathOf(labels),
+	}, nil
+}
 
-	// 删除现有 ballast 文件
-	if _, err := dc.executeCommand(containerID, []string{"rm", "-f", ballastPath}); err != nil {
-		return fmt.Errorf("failed to remove ballast file: %w", err)
+// storageLimit 从容器 name 的 threshold 标签中读取系统盘的限制大小，没有该标签时 hasLimited 为 false
+//
+// 新创建的容器标签中直接保存字节数，旧版本创建的容器保存的是 humanize 格式（例如 25GB、1.2TB），
+// 两种格式都使用 parseHumanSize 解析。
+func (dc *DockerContainer) storageLimit(name string, labels map[string]string) (size int64, hasLimited bool, err error) {
+	v, ok := dc.labelValue(labels, labelThreshold)
+	if !ok {
+		return 0, false, nil
 	}
-
-	// 创建新的 ballast 文件（如果新的大小大于 0）
-	if newBallastSize > 0 {
-		cmd := fmt.Sprintf("fallocate -l %d %s", newBallastSize, ballastPath)
-		if _, err := dc.executeCommand(containerID, []string{"/bin/bash", "-c", cmd}); err != nil {
-			return fmt.Errorf("failed to create new ballast file: %w", err)
-		}
-		klog.Infof("Reduced /ballast size to %d bytes", newBallastSize)
-	} else {
-		klog.Infof("/ballast file removed as new size is %d bytes", newBallastSize)
+	size, err = parseHumanSize(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid threshold label %q on container %s: %w", v, name, err)
 	}
-
-	return nil
+	return size, true, nil
 }
@@ -1,6 +1,18 @@
 package container
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
 
 func TestDockerContainerRun(t *testing.T) {
 	dc, err := NewDockerContainer()
@@ -11,7 +23,7 @@ func TestDockerContainerRun(t *testing.T) {
 		dc.Close()
 	}()
 
-	_ = dc.Remove("test")
+	_ = dc.ForceRemove("test")
 
 	id, err := dc.Run("test")
 	if err != nil {
@@ -30,7 +42,7 @@ func TestDockerContainerRemove(t *testing.T) {
 		dc.Close()
 	}()
 
-	err = dc.Remove("test")
+	err = dc.ForceRemove("test")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,3 +76,1005 @@ func TestDockerContainerStart(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDockerContainerStartRestoresBallast(t *testing.T) {
+	c, err := NewDockerContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		c.Close()
+	}()
+	dc := c.(*DockerContainer)
+
+	_ = dc.ForceRemove("test-restore")
+	defer func() {
+		_ = dc.ForceRemove("test-restore")
+	}()
+
+	id, err := dc.Run("test-restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟 Stop 时 /ballast 被删除
+	if _, err := dc.executeCommand(context.Background(), id, []string{"rm", "-f", ballastPath}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Stop("test-restore"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Start("test-restore"); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := dc.executeCommand(context.Background(), id, []string{"stat", "-c", "%s", ballastPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(ballastSize) {
+		t.Fatalf("ballast size = %d, want %d", size, ballastSize)
+	}
+}
+
+func TestBuildContainerConfig(t *testing.T) {
+	dc := &DockerContainer{image: defaultImage, labelPrefix: defaultLabelPrefix}
+	opts := dc.withDefaults(RunOptions{Name: "test"})
+	config, hostConfig := dc.buildContainerConfig(opts)
+
+	threshold, err := parseHumanSize(config.Labels["io.ballast/threshold"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := strconv.ParseInt(hostConfig.StorageOpt["size"], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold != size {
+		t.Fatalf("threshold label %d does not match storage-opt size %d", threshold, size)
+	}
+	if want := int64(defaultStorageSize.Add(ballastSize)); size != want {
+		t.Fatalf("storage-opt size = %d, want %d", size, want)
+	}
+	if got := dc.ballastCeiling(config.Labels); got != int64(ballastSize) {
+		t.Fatalf("ballast label = %d, want %d", got, ballastSize)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	api := newFakeDockerAPI()
+	ctx := context.Background()
+
+	// 默认的命令不能在一段时间后自己退出（之前的 sleep 3600 会在一个小时后退出）
+	if _, err := newTestContainer(api).Run("default"); err != nil {
+		t.Fatal(err)
+	}
+	config := api.container("default").json.Config
+	if strings.Join(config.Cmd, " ") != "sleep infinity" || len(config.Entrypoint) != 0 {
+		t.Fatalf("default cmd = %q, entrypoint = %q, want sleep infinity", config.Cmd, config.Entrypoint)
+	}
+
+	dc := newTestContainer(api, WithEntrypoint("/docker-entrypoint.sh"))
+	if _, err := dc.Run("entrypoint"); err != nil {
+		t.Fatal(err)
+	}
+	config = api.container("entrypoint").json.Config
+	if strings.Join(config.Entrypoint, " ") != "/docker-entrypoint.sh" || len(config.Cmd) != 0 {
+		t.Fatalf("cmd = %q, entrypoint = %q, want the image cmd", config.Cmd, config.Entrypoint)
+	}
+
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "workload", Cmd: []string{"nginx", "-g", "daemon off;"}}); err != nil {
+		t.Fatal(err)
+	}
+	config = api.container("workload").json.Config
+	if strings.Join(config.Cmd, " ") != "nginx -g daemon off;" || strings.Join(config.Entrypoint, " ") != "/docker-entrypoint.sh" {
+		t.Fatalf("cmd = %q, entrypoint = %q", config.Cmd, config.Entrypoint)
+	}
+}
+
+func TestRunStoresStorageLabels(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	_, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", StorageSize: 30 * gigabyte, BallastSize: 3 * gigabyte})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := api.container("test").json.Config.Labels
+	base, err := strconv.ParseInt(labels[dc.labelKey(labelBaseStorage)], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base != 30*gigabyte {
+		t.Fatalf("base_storage label = %d, want %d", base, int64(30*gigabyte))
+	}
+	if got := dc.ballastCeiling(labels); got != 3*gigabyte {
+		t.Fatalf("ballast label = %d, want %d", got, int64(3*gigabyte))
+	}
+	threshold, err := parseHumanSize(labels[dc.labelKey(labelThreshold)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold != base+dc.ballastCeiling(labels) {
+		t.Fatalf("threshold label %d != base_storage %d + ballast %d", threshold, base, dc.ballastCeiling(labels))
+	}
+}
+
+func TestRunStorageSize(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "plan-50", StorageSize: 50 * gigabyte}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.Run("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	// threshold 标签和 storage-opt size 都是 StorageSize 加上 5GB 的 /ballast
+	for name, want := range map[string]int64{"plan-50": 55 * gigabyte, "default": 25 * gigabyte} {
+		c := api.container(name)
+		if got := c.json.Config.Labels[dc.labelKey(labelThreshold)]; got != strconv.FormatInt(want, 10) {
+			t.Errorf("threshold label of %s = %s, want %d", name, got, want)
+		}
+		if got := c.hostConfig.StorageOpt["size"]; got != strconv.FormatInt(want, 10) {
+			t.Errorf("storage-opt size of %s = %s, want %d", name, got, want)
+		}
+	}
+}
+
+func TestRunResourceLimits(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	opts := RunOptions{Name: "test", Memory: 2 * gigabyte, NanoCPUs: 1500000000, PidsLimit: 512}
+	if _, err := dc.RunWithOptions(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	hostConfig := api.container("test").hostConfig
+	if hostConfig.Memory != 2*gigabyte || hostConfig.NanoCPUs != 1500000000 {
+		t.Fatalf("memory = %d, nano cpus = %d", hostConfig.Memory, hostConfig.NanoCPUs)
+	}
+	if hostConfig.PidsLimit == nil || *hostConfig.PidsLimit != 512 {
+		t.Fatalf("pids limit = %v, want 512", hostConfig.PidsLimit)
+	}
+
+	// 没有设置时不限制
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "unlimited"}); err != nil {
+		t.Fatal(err)
+	}
+	if hostConfig := api.container("unlimited").hostConfig; hostConfig.Memory != 0 || hostConfig.NanoCPUs != 0 || hostConfig.PidsLimit != nil {
+		t.Fatalf("unexpected resources %+v", hostConfig.Resources)
+	}
+
+	for _, invalid := range []RunOptions{
+		{Name: "invalid", Memory: -1},
+		{Name: "invalid", NanoCPUs: -1},
+		{Name: "invalid", PidsLimit: -1},
+	} {
+		if _, err := dc.RunWithOptions(context.Background(), invalid); err == nil {
+			t.Fatalf("expected validation error for %+v", invalid)
+		}
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	dc := newTestContainer(newFakeDockerAPI())
+	tests := []struct {
+		label string
+		want  int64
+	}{
+		{"25000000000", 25000000000},
+		{"500MB", 500 * 1000 * 1000},
+		{"25GB", 25 * 1000 * 1000 * 1000},
+		{"1.2TB", 1200 * 1000 * 1000 * 1000},
+		{"23GiB", 23 << 30},
+	}
+
+	for _, tt := range tests {
+		got, limited, err := dc.storageLimit("test", map[string]string{labelThreshold: tt.label})
+		if err != nil || !limited {
+			t.Fatalf("storageLimit(%q) = %d, %v, %v", tt.label, got, limited, err)
+		}
+		if got != tt.want {
+			t.Fatalf("storageLimit(%q) = %d, want %d", tt.label, got, tt.want)
+		}
+	}
+
+	if _, _, err := dc.storageLimit("test", map[string]string{labelThreshold: "abc"}); err == nil {
+		t.Fatal("expected error for invalid label")
+	}
+}
+
+func TestNewDockerContainerValidate(t *testing.T) {
+	if _, err := newDockerContainer(WithReductionStep(2), WithFreeMargin(100*1000*1000)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := [][]Option{
+		{WithReductionStep(0)},
+		{WithReductionStep(-0.5)},
+		{WithFreeMargin(0)},
+		{WithTargetFreeSpace(-1)},
+		{WithBallastPath("ballast")},
+		{WithBallastPath("/")},
+		{WithBallastPath("/var/lib/../ballast")},
+	}
+	for _, opts := range invalid {
+		if _, err := newDockerContainer(opts...); err == nil {
+			t.Fatal("expected validation error")
+		}
+	}
+}
+
+func TestGigabytesToBytes(t *testing.T) {
+	tests := []struct {
+		gb   float64
+		want int64
+	}{
+		{0.3, 300000000},
+		{0.5, 500000000},
+		{1.1, 1100000000},
+		{2.675, 2675000000},
+	}
+	for _, tt := range tests {
+		if got := gigabytesToBytes(tt.gb); got != tt.want {
+			t.Errorf("gigabytesToBytes(%v) = %d, want %d", tt.gb, got, tt.want)
+		}
+	}
+
+	dc, err := newDockerContainer(WithReductionStep(0.3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.reductionPolicy != FixedReduction(300*1000*1000) {
+		t.Fatalf("reductionPolicy = %#v, want FixedReduction(300000000)", dc.reductionPolicy)
+	}
+}
+
+func TestRunAllocatesBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := api.container("test")
+	if c == nil || c.json.ID != id {
+		t.Fatalf("container test was not created")
+	}
+	if !c.json.State.Running {
+		t.Fatal("container test is not running")
+	}
+
+	want := "/bin/bash -c fallocate -l 5000000000 /ballast"
+	var found bool
+	for _, cmd := range api.commands {
+		if strings.Join(cmd, " ") == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("commands = %q, want %q", api.commands, want)
+	}
+	if c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+}
+
+func TestStopAdjustsBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 剩余 0.8GB，小于默认的 1GB
+	c := api.container("test")
+	c.dataUsed = int64(defaultStorageSize) - 800*1000*1000
+
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 缺口为 0.2GB，加上 0.1GB 的余量后剩余 1.1GB，大于 1GB
+	if c.ballast != 4700000000 {
+		t.Fatalf("ballast = %d, want 4700000000", c.ballast)
+	}
+	if c.json.State.Running {
+		t.Fatal("container test is still running")
+	}
+}
+
+func TestBallastPath(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithBallastPath("/var/lib/ballast"))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	if got := c.json.Config.Labels[dc.labelKey(labelBallastPath)]; got != "/var/lib/ballast" {
+		t.Fatalf("ballast_path label = %q, want /var/lib/ballast", got)
+	}
+	if got := strings.Join(api.commands[0], " "); got != "mkdir -p /var/lib" {
+		t.Fatalf("first command = %q, want mkdir -p /var/lib", got)
+	}
+	if c.ballast != -1 || c.fileSize("/var/lib/ballast") != int64(ballastSize) {
+		t.Fatalf("ballast = %d, /var/lib/ballast = %d", c.ballast, c.fileSize("/var/lib/ballast"))
+	}
+
+	// 使用默认路径的实例按照标签调整已经创建的容器
+	c.dataUsed = int64(defaultStorageSize) - 800*1000*1000
+	if err := newTestContainer(api).Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.fileSize("/var/lib/ballast"); got != 4700000000 {
+		t.Fatalf("/var/lib/ballast = %d, want 4700000000", got)
+	}
+}
+
+func TestRunNameConflict(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dc.Run("test"); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict, got %v", err)
+	}
+
+	reused, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", OnConflict: ConflictReuse})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != id {
+		t.Fatalf("reused id = %s, want %s", reused, id)
+	}
+
+	replaced, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", OnConflict: ConflictReplace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced == id {
+		t.Fatal("expected a new container id after replace")
+	}
+	if c := api.container("test"); c == nil || c.json.ID != replaced || c.ballast != int64(ballastSize) {
+		t.Fatal("replaced container was not recreated with a ballast")
+	}
+}
+
+func TestStopWithResult(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.dataUsed = int64(defaultStorageSize) - 800*1000*1000
+
+	result, err := dc.StopWithResult(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StopResult{
+		ID:           id,
+		Adjusted:     true,
+		ReducedBytes: 300 * 1000 * 1000,
+		UsedBytes:    int64(defaultStorageSize.Add(ballastSize)) - 800*1000*1000,
+		FreeBytes:    800 * 1000 * 1000,
+	}
+	if result != want {
+		t.Fatalf("StopWithResult() = %+v, want %+v", result, want)
+	}
+
+	// 剩余空间充足时不调整 /ballast
+	startedID, err := dc.StartWithID(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if startedID != id {
+		t.Fatalf("StartWithID() = %q, want %q", startedID, id)
+	}
+	c.dataUsed = 0
+	result, err = dc.StopWithResult(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Adjusted || result.ReducedBytes != 0 || result.AdjustError != nil {
+		t.Fatalf("unexpected adjustment %+v", result)
+	}
+}
+
+func TestRestartRestoresBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.dataUsed = int64(defaultStorageSize) - 800*1000*1000
+
+	if err := dc.Restart(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.json.State.Running {
+		t.Fatal("container test is not running after restart")
+	}
+	if len(api.stopped) != 1 {
+		t.Fatalf("stopped = %v, want [test]", api.stopped)
+	}
+
+	// 用户释放空间后，重启会把 /ballast 恢复到创建时的大小
+	c.dataUsed = 0
+	if err := dc.Restart(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+
+	if err := dc.Restart(context.Background(), "missing"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestPausedContainerSkipsBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.dataUsed = int64(defaultStorageSize) - 800*1000*1000
+
+	if err := dc.Pause(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.monitorOnce(ctx, "test"); err != nil {
+		t.Fatalf("monitor should skip paused container, got %v", err)
+	}
+	if _, _, _, err := dc.DiskUsage(ctx, "test"); !errors.Is(err, ErrContainerNotRunning) {
+		t.Fatalf("expected ErrContainerNotRunning, got %v", err)
+	}
+
+	result, err := dc.StopWithResult(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Adjusted || result.AdjustError != nil {
+		t.Fatalf("unexpected adjustment for paused container %+v", result)
+	}
+	if c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+}
+
+func TestRename(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	for _, name := range []string{"blue", "green"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dc.Rename(ctx, "blue", "green"); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict, got %v", err)
+	}
+	if err := dc.Rename(ctx, "missing", "other"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+	if err := dc.Rename(ctx, "blue", "bad name"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+
+	if err := dc.Rename(ctx, "blue", "blue-old"); err != nil {
+		t.Fatal(err)
+	}
+	size, limited, err := dc.hasStorageLimit("blue-old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !limited || size != 25*gigabyte {
+		t.Fatalf("expected threshold label to survive rename, got %d, %v", size, limited)
+	}
+	if _, err := dc.BallastSize(ctx, "blue-old"); err != nil {
+		t.Fatalf("expected /ballast to survive rename: %v", err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	tests := []struct {
+		timeout *time.Duration
+		want    *int
+	}{
+		{timeout: nil, want: nil},
+		{timeout: durationPtr(30 * time.Second), want: intPtr(30)},
+		{timeout: durationPtr(1500 * time.Millisecond), want: intPtr(2)},
+		{timeout: durationPtr(0), want: intPtr(0)},
+		{timeout: durationPtr(-time.Second), want: intPtr(0)},
+	}
+	for _, tt := range tests {
+		got := (&DockerContainer{stopTimeout: tt.timeout}).stopOptions().Timeout
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("stopOptions(%v).Timeout = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithStopTimeout(time.Minute))
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.stopOptions) != 1 || api.stopOptions[0].Timeout == nil || *api.stopOptions[0].Timeout != 60 {
+		t.Fatalf("unexpected stop options %+v", api.stopOptions)
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration { return &d }
+
+func intPtr(i int) *int { return &i }
+
+func TestRemoveRunningContainer(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dc.Remove("test"); !errors.Is(err, ErrContainerRunning) {
+		t.Fatalf("expected ErrContainerRunning, got %v", err)
+	}
+	if _, ok := api.containers["test"]; !ok {
+		t.Fatal("running container should not be removed")
+	}
+
+	if err := dc.ForceRemove("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := api.containers["test"]; ok {
+		t.Fatal("container should be force removed")
+	}
+	if err := dc.Remove("test"); err != nil {
+		t.Fatalf("removing a missing container should succeed, got %v", err)
+	}
+}
+
+// slowExecAPI 在每次 exec 前等待一段时间，让并发的调用有机会交错执行
+type slowExecAPI struct {
+	*fakeDockerAPI
+	delay time.Duration
+}
+
+func (s slowExecAPI) ContainerExecCreate(ctx context.Context, name string, options container.ExecOptions) (types.IDResponse, error) {
+	time.Sleep(s.delay)
+	return s.fakeDockerAPI.ContainerExecCreate(ctx, name, options)
+}
+
+// slowStateAPI 在 inspect 和启动容器前等待一段时间，让并发的 Start 和 Stop 有机会在检查状态之后交错执行
+type slowStateAPI struct {
+	slowExecAPI
+}
+
+func (s slowStateAPI) ContainerInspect(ctx context.Context, name string) (types.ContainerJSON, error) {
+	time.Sleep(s.delay)
+	return s.fakeDockerAPI.ContainerInspect(ctx, name)
+}
+
+func (s slowStateAPI) ContainerStart(ctx context.Context, name string, options container.StartOptions) error {
+	time.Sleep(s.delay)
+	return s.fakeDockerAPI.ContainerStart(ctx, name, options)
+}
+
+func TestStartStopTransitions(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// running -> running
+	if _, err := dc.StartWithID(ctx, "test"); !errors.Is(err, ErrAlreadyRunning) || KindOf(err) != KindConflict {
+		t.Fatalf("Start on running container expected ErrAlreadyRunning, got %v", err)
+	}
+	// running -> stopped
+	if _, err := dc.StopWithResult(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	// stopped -> stopped，不会在容器内执行 df
+	commands := len(api.commands)
+	result, err := dc.StopWithResult(ctx, "test")
+	if !errors.Is(err, ErrAlreadyStopped) || KindOf(err) != KindConflict {
+		t.Fatalf("Stop on stopped container expected ErrAlreadyStopped, got %v", err)
+	}
+	if result.ID != id || result.Adjusted {
+		t.Fatalf("StopWithResult() = %+v, want only ID %s", result, id)
+	}
+	if len(api.commands) != commands {
+		t.Fatalf("Stop on stopped container executed %v", api.commands[commands:])
+	}
+	// stopped -> running，Restart 也可以启动已经停止的容器
+	if err := dc.Restart(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !api.container("test").json.State.Running {
+		t.Fatal("container should be running after Restart")
+	}
+}
+
+func TestIdempotentStartStop(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithIdempotentStartStop(true))
+	ctx := context.Background()
+
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := len(api.commands)
+	startedID, err := dc.StartWithID(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if startedID != id {
+		t.Fatalf("StartWithID() = %q, want %q", startedID, id)
+	}
+	if len(api.commands) != commands {
+		t.Fatalf("Start on running container executed %v", api.commands[commands:])
+	}
+
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	commands = len(api.commands)
+	result, err := dc.StopWithResult(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (StopResult{ID: id}) {
+		t.Fatalf("StopWithResult() = %+v, want only ID %s", result, id)
+	}
+	if len(api.commands) != commands {
+		t.Fatalf("Stop on stopped container executed %v", api.commands[commands:])
+	}
+}
+
+func TestConcurrentStop(t *testing.T) {
+	api := newFakeDockerAPI()
+	c, err := NewWithClient(slowExecAPI{fakeDockerAPI: api, delay: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := c.(*DockerContainer)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	fc := api.container("test")
+	fc.dataUsed = int64(defaultStorageSize) - 800*megabyte
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		reduced int64
+		stopped int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := dc.StopWithResult(context.Background(), "test")
+			if errors.Is(err, ErrAlreadyStopped) {
+				mu.Lock()
+				stopped++
+				mu.Unlock()
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			reduced += result.ReducedBytes
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// 只有第一个 Stop 会调整 /ballast，之后容器已经停止
+	if stopped != 7 {
+		t.Fatalf("%d stops returned ErrAlreadyStopped, want 7", stopped)
+	}
+	if reduced != 300*megabyte {
+		t.Fatalf("total reduced = %d, want %d", reduced, 300*megabyte)
+	}
+	if fc.ballast != 4700*megabyte {
+		t.Fatalf("ballast = %d, want %d", fc.ballast, 4700*megabyte)
+	}
+}
+
+func TestConcurrentStartStop(t *testing.T) {
+	api := newFakeDockerAPI()
+	c, err := NewWithClient(slowStateAPI{slowExecAPI{fakeDockerAPI: api, delay: time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := c.(*DockerContainer)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg               sync.WaitGroup
+		mu               sync.Mutex
+		started, stopped int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(start bool) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				var err error
+				if start {
+					_, err = dc.StartWithID(context.Background(), "test")
+				} else {
+					_, err = dc.StopWithResult(context.Background(), "test")
+				}
+				switch {
+				case err == nil:
+					mu.Lock()
+					if start {
+						started++
+					} else {
+						stopped++
+					}
+					mu.Unlock()
+				case !errors.Is(err, ErrAlreadyRunning) && !errors.Is(err, ErrAlreadyStopped):
+					t.Error(err)
+					return
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+
+	// 状态检查和状态变化在同一个锁内完成，成功的 Stop 和 Start 一定交替发生
+	running := api.container("test").json.State.Running
+	if stopped-started != 0 && stopped-started != 1 {
+		t.Fatalf("%d successful stops and %d successful starts, transitions must alternate", stopped, started)
+	}
+	if running != (stopped == started) {
+		t.Fatalf("running = %v after %d stops and %d starts", running, stopped, started)
+	}
+}
+
+func TestRunDisableBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	// overlay2 on extfs 不支持 storage-opt，不创建 /ballast 时不需要检查
+	api.info = system.Info{Driver: "overlay2", DriverStatus: [][2]string{{"Backing Filesystem", "extfs"}}}
+	dc := newTestContainer(api, WithDisableBallast(true))
+
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", Labels: map[string]string{"app": "web"}}); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	if _, ok := c.hostConfig.StorageOpt["size"]; ok {
+		t.Fatalf("storage-opt = %v, want no size", c.hostConfig.StorageOpt)
+	}
+	if labels := c.json.Config.Labels; len(labels) != 1 || labels["app"] != "web" {
+		t.Fatalf("labels = %v, want only user labels", labels)
+	}
+	if c.ballast != -1 || len(api.commands) != 0 {
+		t.Fatalf("ballast = %d, commands = %v, want no ballast", c.ballast, api.commands)
+	}
+	if _, limited, err := dc.hasStorageLimit("test"); err != nil || limited {
+		t.Fatalf("hasStorageLimit() = %v, %v, want false", limited, err)
+	}
+
+	// Stop 和 Start 不会在容器内执行任何命令
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.commands) != 0 {
+		t.Fatalf("commands = %v, want none", api.commands)
+	}
+}
+
+func TestRunKeepOnFailure(t *testing.T) {
+	failAlloc := func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if strings.Contains(strings.Join(cmd, " "), "fallocate") || strings.Contains(strings.Join(cmd, " "), "dd ") {
+			return fakeExecResult{stderr: "fallocate: fallocate failed: Operation not supported\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+
+	// 默认删除容器
+	api := newFakeDockerAPI()
+	api.execFn = failAlloc
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err == nil {
+		t.Fatal("expected an error when allocation fails")
+	}
+	if api.container("test") != nil {
+		t.Fatal("container should be removed after a failed run")
+	}
+
+	api = newFakeDockerAPI()
+	api.execFn = failAlloc
+	dc = newTestContainer(api)
+	_, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", KeepOnFailure: true})
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("RunWithOptions() error = %v, want an OpError", err)
+	}
+	c := api.container("test")
+	if c == nil {
+		t.Fatal("container should be kept after a failed run")
+	}
+	if opErr.ID != c.json.ID || opErr.Op != "run" || opErr.Container != "test" {
+		t.Fatalf("OpError = %+v, want the kept container ID %s", opErr, c.json.ID)
+	}
+	if c.json.State.Running {
+		t.Fatal("kept container should be stopped")
+	}
+
+	// WithKeepOnFailure 对所有容器生效
+	dc = newTestContainer(api, WithKeepOnFailure(true))
+	if _, err := dc.Run("another"); err == nil || api.container("another") == nil {
+		t.Fatalf("Run() error = %v, container should be kept", err)
+	}
+}
+
+func TestRunBallastOptional(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if strings.Contains(strings.Join(cmd, " "), "fallocate") || strings.Contains(strings.Join(cmd, " "), "dd ") {
+			return fakeExecResult{stderr: "fallocate: fallocate failed: Operation not supported\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+	dc := newTestContainer(api)
+
+	// 创建 /ballast 失败时容器继续运行，返回容器 ID 和 BallastWarning
+	id, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", BallastOptional: true})
+	var warning *BallastWarning
+	if !errors.As(err, &warning) {
+		t.Fatalf("RunWithOptions() error = %v, want a BallastWarning", err)
+	}
+	c := api.container("test")
+	if c == nil || !c.json.State.Running {
+		t.Fatal("container should keep running when the ballast cannot be created")
+	}
+	if id == "" || id != c.json.ID || warning.ID != id || warning.Container != "test" {
+		t.Fatalf("id = %q, warning = %+v, want container ID %s", id, warning, c.json.ID)
+	}
+
+	// 启动失败仍然删除容器，不返回 BallastWarning
+	api = newFakeDockerAPI()
+	api.startErr = errors.New("OCI runtime create failed")
+	dc = newTestContainer(api, WithBallastOptional(true))
+	id, err = dc.Run("test")
+	if err == nil || errors.As(err, &warning) || id != "" {
+		t.Fatalf("Run() = %q, %v, want a start failure", id, err)
+	}
+	if api.container("test") != nil {
+		t.Fatal("container should be removed when it fails to start")
+	}
+}
+
+func TestRunNetwork(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	opts := RunOptions{
+		Name:        "test",
+		NetworkMode: "backend",
+		DNS:         []string{"10.0.0.2", "8.8.8.8"},
+		ExtraHosts:  []string{"db:10.0.0.10", "gateway:host-gateway"},
+	}
+	if _, err := dc.RunWithOptions(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	hostConfig := api.container("test").hostConfig
+	if hostConfig.NetworkMode != "backend" {
+		t.Fatalf("network mode = %q, want backend", hostConfig.NetworkMode)
+	}
+	if strings.Join(hostConfig.DNS, ",") != "10.0.0.2,8.8.8.8" || strings.Join(hostConfig.ExtraHosts, ",") != "db:10.0.0.10,gateway:host-gateway" {
+		t.Fatalf("dns = %v, extra hosts = %v", hostConfig.DNS, hostConfig.ExtraHosts)
+	}
+
+	tests := []struct {
+		name string
+		opts RunOptions
+	}{
+		{"dns with host network", RunOptions{NetworkMode: "host", DNS: []string{"8.8.8.8"}}},
+		{"dns with container network", RunOptions{NetworkMode: "container:web", DNS: []string{"8.8.8.8"}}},
+		{"extra hosts with container network", RunOptions{NetworkMode: "container:web", ExtraHosts: []string{"db:10.0.0.10"}}},
+		{"invalid dns", RunOptions{DNS: []string{"dns.example.com"}}},
+		{"invalid extra host", RunOptions{ExtraHosts: []string{"db"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateNetwork(tt.opts); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	// host 网络模式下仍然可以添加 /etc/hosts 的记录
+	if err := validateNetwork(RunOptions{NetworkMode: "host", ExtraHosts: []string{"db:10.0.0.10"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "conflict", NetworkMode: "host", DNS: []string{"8.8.8.8"}}); err == nil || api.container("conflict") != nil {
+		t.Fatalf("RunWithOptions() error = %v, want a conflict before creating the container", err)
+	}
+}
+
+func TestRunWithResult(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	result, err := dc.RunWithResult(context.Background(), RunOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("RunWithResult() error = %v", err)
+	}
+	want := RunResult{ID: api.container("test").json.ID, BallastBytes: int64(ballastSize), AllocMethod: AllocFallocate, QuotaApplied: true}
+	if result != want {
+		t.Fatalf("result = %+v, want %+v", result, want)
+	}
+
+	// fallocate 不可用时记录实际使用的 dd
+	api = newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if len(cmd) == 3 && strings.HasPrefix(cmd[2], "fallocate") {
+			return fakeExecResult{stderr: "/bin/sh: fallocate: not found\n", exitCode: 127}, true
+		}
+		return fakeExecResult{}, false
+	}
+	dc = newTestContainer(api)
+	if result, err := dc.RunWithResult(context.Background(), RunOptions{Name: "test"}); err != nil || result.AllocMethod != AllocDD {
+		t.Fatalf("RunWithResult() = %+v, %v, want dd", result, err)
+	}
+
+	// 不创建 /ballast 时没有限制系统盘大小
+	result, err = dc.RunWithResult(context.Background(), RunOptions{Name: "plain", DisableBallast: true})
+	if err != nil || result.ID == "" || result.QuotaApplied || result.BallastBytes != 0 || result.AllocMethod != "" {
+		t.Fatalf("RunWithResult() without ballast = %+v, %v", result, err)
+	}
+
+	// 创建 /ballast 失败的容器仍然记录了 ID 和系统盘限制
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if joined := strings.Join(cmd, " "); strings.Contains(joined, "fallocate") || strings.Contains(joined, "dd ") {
+			return fakeExecResult{stderr: "fallocate: fallocate failed: Operation not supported\n", exitCode: 1}, true
+		}
+		return fakeExecResult{}, false
+	}
+	result, err = dc.RunWithResult(context.Background(), RunOptions{Name: "optional", BallastOptional: true})
+	var warning *BallastWarning
+	if !errors.As(err, &warning) || result.ID == "" || !result.QuotaApplied || result.BallastBytes != 0 {
+		t.Fatalf("RunWithResult() = %+v, %v, want a BallastWarning", result, err)
+	}
+}
@@ -0,0 +1,108 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// CopyTo 将 content 写入容器内的 dstPath 文件，文件已经存在时会被覆盖，权限为 0644
+//
+// Docker 只接受 tar 格式的内容，这里会把 content 打包后再上传，content 会被完整读入内存。
+func (dc *DockerContainer) CopyTo(ctx context.Context, name, dstPath string, content io.Reader) (err error) {
+	defer wrapOp("copy", name, &err)
+
+	if !path.IsAbs(dstPath) || path.Base(dstPath) == "/" {
+		return fmt.Errorf("failed to copy to container %s: %w: invalid destination path %q", name, ErrInvalidArgument, dstPath)
+	}
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("failed to read content for %s: %w", dstPath, err)
+	}
+
+	archive, err := packFile(dstPath, data)
+	if err != nil {
+		return err
+	}
+
+	// 容器内的路径不存在时 Docker 同样返回 NotFound，先确认容器存在
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return err
+	}
+	if dc.dryRunf("Would copy %d bytes to %s in container %s", len(data), dstPath, name) {
+		return nil
+	}
+	err = dc.cli.CopyToContainer(ctx, containerInspect.ID, path.Dir(dstPath), archive, container.CopyToContainerOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to copy to %s in container %s: %w", dstPath, name, err)
+	}
+	return nil
+}
+
+// packFile 将 data 打包为只包含 dstPath 一个文件的 tar，权限为 0644
+func packFile(dstPath string, data []byte) (*bytes.Buffer, error) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: path.Base(dstPath),
+		Mode: 0o644,
+		Size: int64(len(data)),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", dstPath, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", dstPath, err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", dstPath, err)
+	}
+	return &archive, nil
+}
+
+// CopyFrom 读取容器内 srcPath 文件的内容，调用方需要关闭返回的 reader，srcPath 不能是目录
+func (dc *DockerContainer) CopyFrom(ctx context.Context, name, srcPath string) (_ io.ReadCloser, err error) {
+	defer wrapOp("copy", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	rc, _, err := dc.cli.CopyFromContainer(ctx, containerInspect.ID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container %s: %w", srcPath, name, err)
+	}
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		_ = rc.Close()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to unpack %s from container %s: %w", srcPath, name, err)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		_ = rc.Close()
+		return nil, fmt.Errorf("failed to copy %s from container %s: not a regular file", srcPath, name)
+	}
+
+	return &tarFileReader{Reader: tr, closer: rc}, nil
+}
+
+// tarFileReader 是 tar 中单个文件的内容，关闭时会关闭整个 tar 流
+type tarFileReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *tarFileReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,48 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCopyToAndFrom(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dc.CopyTo(ctx, "test", "/etc/app/config.yaml", strings.NewReader("key: value\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(api.container("test").files["/etc/app/config.yaml"]); got != "key: value\n" {
+		t.Fatalf("copied content = %q", got)
+	}
+
+	rc, err := dc.CopyFrom(ctx, "test", "/etc/app/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "key: value\n" {
+		t.Fatalf("CopyFrom = %q", data)
+	}
+
+	if err := dc.CopyTo(ctx, "test", "relative.txt", strings.NewReader("x")); err == nil {
+		t.Fatal("expected error for relative destination path")
+	}
+	if _, err := dc.CopyFrom(ctx, "test", "/etc/missing"); err == nil || errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("a missing file should not be reported as a missing container, got %v", err)
+	}
+	if err := dc.CopyTo(ctx, "missing", "/tmp/x", strings.NewReader("x")); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dfUsage 是 df 输出中的一行，单位与 df 的块大小一致
+type dfUsage struct {
+	total     int64
+	used      int64
+	available int64
+}
+
+// parseDfOutput 解析 df 命令的输出，返回总空间、已用空间和可用空间（单位与 df 的块大小一致）
+//
+// 调用方应使用 df -P，保证每个文件系统只输出一行。为了兼容不支持 -P 的 df 实现，
+// 当设备名称过长被折行时，会把表头之后的所有行拼接起来再解析。
+// 各列的位置从表头中识别，兼容 GNU coreutils 和 busybox，表头无法识别时（例如本地化的输出）
+// 按照 POSIX 规定的顺序读取。
+func parseDfOutput(output string) (dfUsage, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return dfUsage{}, fmt.Errorf("unexpected df output format")
+	}
+
+	columns := parseDfHeader(lines[0])
+
+	var fields []string
+	for _, line := range lines[1:] {
+		fields = append(fields, strings.Fields(line)...)
+	}
+	// Filesystem Size Used Avail Use% Mounted on
+	if len(fields) < 6 || len(fields) <= max(columns.total, columns.used, columns.available) {
+		return dfUsage{}, fmt.Errorf("unexpected df output fields")
+	}
+
+	total, err := strconv.ParseInt(fields[columns.total], 10, 64)
+	if err != nil {
+		return dfUsage{}, fmt.Errorf("failed to parse total disk size: %w", err)
+	}
+	used, err := strconv.ParseInt(fields[columns.used], 10, 64)
+	if err != nil {
+		return dfUsage{}, fmt.Errorf("failed to parse used disk size: %w", err)
+	}
+	available, err := strconv.ParseInt(fields[columns.available], 10, 64)
+	if err != nil {
+		return dfUsage{}, fmt.Errorf("failed to parse available disk size: %w", err)
+	}
+
+	return dfUsage{total: total, used: used, available: available}, nil
+}
+
+// dfColumns 记录 df 输出中总空间、已用空间和可用空间所在的列
+type dfColumns struct {
+	total     int
+	used      int
+	available int
+}
+
+// defaultDfColumns 是 POSIX 规定的列顺序：Filesystem 1024-blocks Used Available Capacity Mounted on
+var defaultDfColumns = dfColumns{total: 1, used: 2, available: 3}
+
+// parseDfHeader 从表头中识别各列的位置，只要有一列无法识别就使用 defaultDfColumns
+func parseDfHeader(header string) dfColumns {
+	columns := dfColumns{total: -1, used: -1, available: -1}
+	for i, name := range strings.Fields(header) {
+		name = strings.ToLower(name)
+		switch {
+		case name == "size" || strings.HasSuffix(name, "-blocks"):
+			columns.total = i
+		case name == "used":
+			columns.used = i
+		case name == "available" || name == "avail" || name == "free":
+			columns.available = i
+		}
+	}
+	if columns.total < 0 || columns.used < 0 || columns.available < 0 {
+		return defaultDfColumns
+	}
+	return columns
+}
+
+// DiskUsage 返回 /ballast 所在文件系统的已用空间、总空间和剩余空间，单位为字节，默认为容器的系统盘
+//
+// 使用 df -B1 获取精确到字节的结果。容器必须处于运行状态并且没有被暂停，
+// 否则返回 ErrContainerNotRunning。
+func (dc *DockerContainer) DiskUsage(ctx context.Context, name string) (used, total, free int64, err error) {
+	defer wrapOp("disk_usage", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		return 0, 0, 0, fmt.Errorf("failed to get disk usage of container %s: %w", name, ErrContainerNotRunning)
+	}
+
+	dfOutput, err := dc.executeCommand(ctx, containerInspect.ID, []string{"df", "-P", "-B1", dc.dfTarget(containerInspect.Config.Labels)})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get disk usage of container %s: %w", name, err)
+	}
+	usage, err := parseDfOutput(dfOutput)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse df output of container %s: %w", name, err)
+	}
+	return usage.used, usage.total, usage.available, nil
+}
@@ -0,0 +1,85 @@
+package container
+
+import "testing"
+
+func TestParseDfOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   dfUsage
+	}{
+		{
+			name: "normal",
+			output: `Filesystem     1GB-blocks  Used Available Capacity Mounted on
+overlay                25    20         5      80% /
+`,
+			want: dfUsage{total: 25, used: 20, available: 5},
+		},
+		{
+			name: "bytes",
+			output: `Filesystem        1-blocks        Used   Available Capacity Mounted on
+overlay        25000000000 19512345600  5487654400      79% /
+`,
+			want: dfUsage{total: 25000000000, used: 19512345600, available: 5487654400},
+		},
+		{
+			name: "wrapped filesystem name",
+			output: `Filesystem     1GB-blocks  Used Available Use% Mounted on
+/dev/mapper/docker-253:0-1234567-0123456789abcdef0123456789abcdef
+                       25    24         2  93% /
+`,
+			want: dfUsage{total: 25, used: 24, available: 2},
+		},
+		{
+			name: "gnu coreutils",
+			output: `Filesystem        1-blocks        Used   Available Use% Mounted on
+overlay        25000000000 19512345600  5487654400  79% /
+`,
+			want: dfUsage{total: 25000000000, used: 19512345600, available: 5487654400},
+		},
+		{
+			name: "gnu coreutils human readable header",
+			output: `Filesystem      Size  Used Avail Use% Mounted on
+overlay           25    20     5  80% /
+`,
+			want: dfUsage{total: 25, used: 20, available: 5},
+		},
+		{
+			name: "busybox",
+			output: `Filesystem           1024-blocks    Used Available Capacity Mounted on
+overlay                 24414062  19055024   5359038  78% /
+`,
+			want: dfUsage{total: 24414062, used: 19055024, available: 5359038},
+		},
+		{
+			name: "reordered columns",
+			output: `Filesystem           1K-blocks      Free      Used Use% Mounted on
+overlay                 24414062   5359038  19055024  78% /
+`,
+			want: dfUsage{total: 24414062, used: 19055024, available: 5359038},
+		},
+		{
+			name: "localized header",
+			output: `Dateisystem    1-Blöcke    Benutzt   Verfügbar Verw% Eingehängt auf
+overlay     25000000000 19512345600 5487654400  79% /
+`,
+			want: dfUsage{total: 25000000000, used: 19512345600, available: 5487654400},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDfOutput(tt.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("parseDfOutput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseDfOutput("Filesystem 1GB-blocks Used Available Use% Mounted on\n"); err == nil {
+		t.Fatal("expected error for missing data line")
+	}
+}
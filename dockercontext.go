@@ -0,0 +1,188 @@
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// defaultDockerContext 是 Docker 命令行内置的上下文，连接 DOCKER_HOST 或者默认的 unix socket
+const defaultDockerContext = "default"
+
+// WithDockerContext 设置 NewDockerContainer 使用的 Docker 上下文，优先级高于 DOCKER_HOST、DOCKER_CONTEXT 和 Docker 配置中的 currentContext
+//
+// 上下文从 DOCKER_CONFIG（默认为 ~/.docker）下的 contexts 目录读取，与 docker context create 创建的上下文相同。
+// 名称为 default 时和不使用上下文一样，通过 DOCKER_HOST 等环境变量连接。对 NewDockerContainerWithHost 无效。
+func WithDockerContext(name string) Option {
+	return func(dc *DockerContainer) {
+		dc.dockerContext = name
+	}
+}
+
+// dockerContextEndpoint 是 Docker 上下文中 docker 类型的端点
+type dockerContextEndpoint struct {
+	Host          string `json:"Host"`
+	SkipTLSVerify bool   `json:"SkipTLSVerify"`
+}
+
+// dockerContextMeta 是 Docker 上下文的 meta.json
+type dockerContextMeta struct {
+	Name      string                           `json:"Name"`
+	Endpoints map[string]dockerContextEndpoint `json:"Endpoints"`
+}
+
+// dockerConfigDir 返回 Docker 命令行的配置目录
+func dockerConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find docker config directory: %w", err)
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+// envClientOpts 返回 NewDockerContainer 创建 Docker 客户端的参数
+//
+// 按照 Docker 命令行的规则选择上下文：WithDockerContext、DOCKER_HOST、DOCKER_CONTEXT、配置中的 currentContext，
+// 设置了 DOCKER_HOST 或者最终选择 default 上下文时使用 client.FromEnv。
+func (dc *DockerContainer) envClientOpts() ([]client.Opt, error) {
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		if dc.dockerContext == "" || dc.dockerContext == defaultDockerContext {
+			// 没有指定上下文时找不到配置目录不影响通过环境变量连接
+			return []client.Opt{client.FromEnv}, nil
+		}
+		return nil, err
+	}
+
+	name, err := resolveDockerContext(dc.dockerContext, configDir)
+	if err != nil {
+		return nil, err
+	}
+	if name == defaultDockerContext {
+		return []client.Opt{client.FromEnv}, nil
+	}
+
+	endpoint, tlsDir, err := loadDockerContext(configDir, name)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := contextClientOpts(endpoint, tlsDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker context %s: %w", name, err)
+	}
+	// 使用上下文时忽略 DOCKER_HOST 和 DOCKER_CERT_PATH，只保留 DOCKER_API_VERSION
+	return append(opts, client.WithVersionFromEnv()), nil
+}
+
+// resolveDockerContext 返回要使用的上下文名称，没有配置上下文时返回 default
+func resolveDockerContext(explicit, configDir string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+	if os.Getenv(client.EnvOverrideHost) != "" {
+		return defaultDockerContext, nil
+	}
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+
+	configFile := filepath.Join(configDir, "config.json")
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultDockerContext, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker config %s: %w", configFile, err)
+	}
+	var config struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse docker config %s: %w", configFile, err)
+	}
+	if config.CurrentContext == "" {
+		return defaultDockerContext, nil
+	}
+	return config.CurrentContext, nil
+}
+
+// loadDockerContext 读取上下文 name 的 docker 端点，同时返回存放它的证书的目录
+//
+// Docker 命令行以名称的 sha256 作为上下文的目录名，端点保存在 contexts/meta/<id>/meta.json，
+// 证书保存在 contexts/tls/<id>/docker 下的 ca.pem、cert.pem 和 key.pem。
+func loadDockerContext(configDir, name string) (dockerContextEndpoint, string, error) {
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	metaFile := filepath.Join(configDir, "contexts", "meta", id, "meta.json")
+	data, err := os.ReadFile(metaFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dockerContextEndpoint{}, "", fmt.Errorf("docker context %s not found in %s", name, configDir)
+	}
+	if err != nil {
+		return dockerContextEndpoint{}, "", fmt.Errorf("failed to read docker context %s: %w", name, err)
+	}
+	var meta dockerContextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return dockerContextEndpoint{}, "", fmt.Errorf("failed to parse docker context %s: %w", name, err)
+	}
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return dockerContextEndpoint{}, "", fmt.Errorf("docker context %s has no docker endpoint", name)
+	}
+	return endpoint, filepath.Join(configDir, "contexts", "tls", id, "docker"), nil
+}
+
+// contextClientOpts 根据上下文的端点生成创建 Docker 客户端的参数，tlsDir 中的证书只对 tcp:// 地址生效
+func contextClientOpts(endpoint dockerContextEndpoint, tlsDir string) ([]client.Opt, error) {
+	opts, err := hostClientOpts(endpoint.Host, nil)
+	if err != nil {
+		return nil, err
+	}
+	if u, _ := url.Parse(endpoint.Host); u.Scheme != "tcp" {
+		return opts, nil
+	}
+
+	options := tlsconfig.Options{InsecureSkipVerify: endpoint.SkipTLSVerify}
+	for _, file := range []struct {
+		path *string
+		name string
+	}{
+		{&options.CAFile, "ca.pem"},
+		{&options.CertFile, "cert.pem"},
+		{&options.KeyFile, "key.pem"},
+	} {
+		path := filepath.Join(tlsDir, file.name)
+		if _, err := os.Stat(path); err == nil {
+			*file.path = path
+		}
+	}
+	if options.CAFile == "" && options.CertFile == "" && !options.InsecureSkipVerify {
+		// 没有证书并且没有跳过校验的上下文使用不加密的连接
+		return opts, nil
+	}
+
+	tlsConfig, err := tlsconfig.Client(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS config: %w", err)
+	}
+	// WithHTTPClient 必须在 WithHost 之前，WithHost 会按照地址配置它的 Transport
+	httpClient := &http.Client{
+		Transport:     &http.Transport{TLSClientConfig: tlsConfig},
+		CheckRedirect: client.CheckRedirect,
+	}
+	return append([]client.Opt{client.WithHTTPClient(httpClient)}, opts...), nil
+}
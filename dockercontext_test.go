@@ -0,0 +1,159 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// writeDockerContext 在 configDir 中按照 Docker 命令行的格式创建上下文 name
+func writeDockerContext(t *testing.T, configDir, name, meta string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(name))
+	dir := filepath.Join(configDir, "contexts", "meta", hex.EncodeToString(sum[:]))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setDockerEnv 使用临时的 Docker 配置目录并清空 DOCKER_HOST 和 DOCKER_CONTEXT，返回配置目录
+func setDockerEnv(t *testing.T, config string) string {
+	t.Helper()
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv(client.EnvOverrideHost, "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return configDir
+}
+
+func TestResolveDockerContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit string
+		host     string
+		context  string
+		config   string
+		want     string
+	}{
+		{name: "nothing configured", want: defaultDockerContext},
+		{name: "current context", config: `{"currentContext": "remote"}`, want: "remote"},
+		{name: "empty current context", config: `{}`, want: defaultDockerContext},
+		{name: "DOCKER_CONTEXT over config", context: "staging", config: `{"currentContext": "remote"}`, want: "staging"},
+		{name: "DOCKER_HOST over DOCKER_CONTEXT", host: "tcp://10.0.0.1:2375", context: "staging", want: defaultDockerContext},
+		{name: "explicit over DOCKER_HOST", explicit: "prod", host: "tcp://10.0.0.1:2375", context: "staging", want: "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDir := setDockerEnv(t, tt.config)
+			t.Setenv(client.EnvOverrideHost, tt.host)
+			t.Setenv("DOCKER_CONTEXT", tt.context)
+
+			got, err := resolveDockerContext(tt.explicit, configDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveDockerContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	configDir := setDockerEnv(t, `{"currentContext": `)
+	if _, err := resolveDockerContext("", configDir); err == nil {
+		t.Error("expected error for malformed config.json")
+	}
+}
+
+func TestEnvClientOpts(t *testing.T) {
+	newClient := func(t *testing.T, opts ...Option) (*client.Client, error) {
+		t.Helper()
+		dc, err := newDockerContainer(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clientOpts, err := dc.envClientOpts()
+		if err != nil {
+			return nil, err
+		}
+		return client.NewClientWithOpts(clientOpts...)
+	}
+
+	t.Run("current context", func(t *testing.T) {
+		configDir := setDockerEnv(t, `{"currentContext": "remote"}`)
+		writeDockerContext(t, configDir, "remote", `{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://10.0.0.1:2375"}}}`)
+
+		cli, err := newClient(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cli.DaemonHost(); got != "tcp://10.0.0.1:2375" {
+			t.Errorf("DaemonHost() = %q, want tcp://10.0.0.1:2375", got)
+		}
+	})
+
+	t.Run("skip TLS verify", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Api-Version", "1.47")
+			_, _ = w.Write([]byte("OK"))
+		}))
+		defer server.Close()
+
+		configDir := setDockerEnv(t, "")
+		host := "tcp://" + server.Listener.Addr().String()
+		writeDockerContext(t, configDir, "insecure", `{"Name":"insecure","Endpoints":{"docker":{"Host":"`+host+`","SkipTLSVerify":true}}}`)
+
+		cli, err := newClient(t, WithDockerContext("insecure"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 服务端使用自签名证书，只有跳过校验并且使用 TLS 连接时才能成功
+		if _, err := cli.Ping(context.Background()); err != nil {
+			t.Fatalf("failed to ping TLS server: %v", err)
+		}
+	})
+
+	t.Run("DOCKER_HOST", func(t *testing.T) {
+		configDir := setDockerEnv(t, `{"currentContext": "remote"}`)
+		writeDockerContext(t, configDir, "remote", `{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://10.0.0.1:2375"}}}`)
+		t.Setenv(client.EnvOverrideHost, "tcp://10.0.0.2:2375")
+
+		cli, err := newClient(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cli.DaemonHost(); got != "tcp://10.0.0.2:2375" {
+			t.Errorf("DaemonHost() = %q, want tcp://10.0.0.2:2375", got)
+		}
+	})
+
+	t.Run("missing context", func(t *testing.T) {
+		setDockerEnv(t, "")
+		if _, err := newClient(t, WithDockerContext("missing")); err == nil {
+			t.Error("expected error for missing context")
+		}
+	})
+
+	t.Run("unsupported host", func(t *testing.T) {
+		configDir := setDockerEnv(t, "")
+		writeDockerContext(t, configDir, "ftp", `{"Name":"ftp","Endpoints":{"docker":{"Host":"ftp://10.0.0.1"}}}`)
+		if _, err := newClient(t, WithDockerContext("ftp")); err == nil {
+			t.Error("expected error for unsupported host")
+		}
+	})
+}
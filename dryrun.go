@@ -0,0 +1,64 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// WithDryRun 开启后只在日志中输出将要执行的操作，不会创建、停止、删除容器，也不会修改 /ballast 文件
+//
+// 查询类的操作（inspect、df、stat）仍然会真正执行，用于在真实的数据上验证调整 /ballast 的计算逻辑。
+// dry-run 模式下 Run 不会创建容器，返回的 ID 为空。
+func WithDryRun(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.dryRun = enabled
+	}
+}
+
+// dryRunf 在 dry-run 模式下输出将要执行的操作并返回 true，调用方需要跳过真正的操作
+func (dc *DockerContainer) dryRunf(format string, args ...interface{}) bool {
+	if !dc.dryRun {
+		return false
+	}
+	dc.logger.Infof("[dry-run] "+format, args...)
+	return true
+}
+
+// dryRunAdjust 按照 adjustBallast 的逻辑计算每一步将要执行的命令，返回 /ballast 将会减少的字节数
+//
+// 由于不会真正删除 /ballast，已用空间按照每一步减少的大小推算。
+func dryRunAdjust(dc *DockerContainer, ctx context.Context, name, containerID, path string, limit, targetFree, shortfall int64) (int64, error) {
+	current, err := statBallast(dc, ctx, containerID, path)
+	if errors.Is(err, ErrBallastNotFound) {
+		dc.dryRunf("%s not found in container %s, nothing to reduce", path, name)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	used, err := dc.usedSpace(ctx, containerID)
+	if err != nil {
+		return 0, err
+	}
+
+	var reduced int64
+	for current > 0 {
+		newBallastSize := shrunkBallastSize(current, dc.reduction(current, shortfall))
+		paths, kept := dc.chunksToRemove(path, current, newBallastSize)
+		dc.dryRunf("Would run in container %s: %s", name, strings.Join(removeCommand(paths), " "))
+		for _, chunk := range dc.chunksToAllocate(path, kept, newBallastSize) {
+			dc.dryRunf("Would run in container %s: %s", name, allocCommand(dc.initialStrategy(), dc.fillPattern, chunk.path, chunk.size))
+		}
+		reduced += current - newBallastSize
+		used -= current - newBallastSize
+		current = newBallastSize
+
+		if free := limit - used; free > targetFree {
+			break
+		}
+		shortfall = deficit(limit, used, targetFree)
+	}
+	dc.dryRunf("Would reduce %s of container %s by %d bytes to %d bytes", path, name, reduced, current)
+	return reduced, nil
+}
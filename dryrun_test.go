@@ -0,0 +1,60 @@
+package container
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 剩余 0.3GB，缺口为 0.7GB，加上余量需要减少 0.8GB
+	c := api.container("test")
+	c.dataUsed = int64(defaultStorageSize) - 300*megabyte
+
+	logger := &recordLogger{}
+	dry := newTestContainer(api, WithDryRun(true), WithLogger(logger))
+	commands := len(api.commands)
+
+	result, err := dry.StopWithResult(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReducedBytes != 800*megabyte {
+		t.Fatalf("expected dry-run to plan a 0.8GB reduction, got %d", result.ReducedBytes)
+	}
+	if !c.json.State.Running {
+		t.Fatal("dry-run should not stop the container")
+	}
+	if c.ballast != 5*gigabyte {
+		t.Fatalf("dry-run should not change /ballast, got %d", c.ballast)
+	}
+	for _, cmd := range api.commands[commands:] {
+		if cmd[0] != "df" && cmd[0] != "stat" {
+			t.Fatalf("dry-run executed mutating command %v", cmd)
+		}
+	}
+	for _, want := range []string{"rm -f /ballast", "fallocate -l 4200000000 /ballast", "Would stop container test"} {
+		if !logger.contains(want) {
+			t.Errorf("expected dry-run log containing %q, got %q", want, logger.infos)
+		}
+	}
+
+	if err := dry.Remove("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := api.containers["test"]; !ok {
+		t.Fatal("dry-run should not remove the container")
+	}
+
+	id, err := dry.Run("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := api.containers["other"]; ok || id != "" {
+		t.Fatal("dry-run should not create the container")
+	}
+}
@@ -0,0 +1,246 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+)
+
+var (
+	// ErrContainerNotFound 表示容器不存在
+	ErrContainerNotFound = errors.New("container not found")
+
+	// ErrStorageOptUnsupported 表示 Docker 的存储驱动不支持 --storage-opt size
+	ErrStorageOptUnsupported = errors.New("storage driver does not support storage-opt size")
+
+	// ErrBallastNotFound 表示容器内不存在 /ballast 文件
+	ErrBallastNotFound = errors.New("ballast file not found")
+
+	// ErrContainerNotRunning 表示容器没有处于运行状态，无法在容器内执行命令
+	ErrContainerNotRunning = errors.New("container is not running")
+
+	// ErrContainerRunning 表示容器正在运行，需要先停止容器或者使用 ForceRemove
+	ErrContainerRunning = errors.New("container is running")
+
+	// ErrNameConflict 表示同名的容器已经存在
+	ErrNameConflict = errors.New("container name already in use")
+
+	// ErrUnsupportedHost 表示 Docker daemon 的地址格式不受支持
+	ErrUnsupportedHost = errors.New("unsupported docker host")
+
+	// ErrInvalidName 表示容器名称不符合 Docker 的要求 [a-zA-Z0-9][a-zA-Z0-9_.-]+
+	ErrInvalidName = errors.New("invalid container name")
+
+	// ErrMonitorRunning 表示该容器已经有一个正在运行的 Monitor
+	ErrMonitorRunning = errors.New("monitor is already running")
+
+	// ErrManagerRunning 表示 Manager 已经启动
+	ErrManagerRunning = errors.New("manager is already running")
+
+	// ErrNoHealthcheck 表示容器没有配置健康检查
+	ErrNoHealthcheck = errors.New("container has no health check")
+
+	// ErrUnhealthy 表示容器的健康检查失败
+	ErrUnhealthy = errors.New("container is unhealthy")
+
+	// ErrDiskUsageUnsupported 表示协商后的 Docker API 版本不支持 DiskUsage 接口
+	ErrDiskUsageUnsupported = errors.New("docker API does not support disk usage")
+
+	// ErrDiskInfoUnavailable 表示无法获取宿主机存储的容量，存储驱动没有在 Info 中报告容量，并且 Docker daemon 不在本机
+	ErrDiskInfoUnavailable = errors.New("host disk info unavailable")
+
+	// ErrRecreateRequired 表示新的配额超过了创建容器时设置的 storage-opt size，只能重新创建容器
+	ErrRecreateRequired = errors.New("container must be recreated to change its storage limit")
+
+	// ErrInsufficientSpace 表示磁盘的剩余空间不足以创建 /ballast，具体的大小见 InsufficientSpaceError
+	ErrInsufficientSpace = errors.New("insufficient disk space")
+
+	// ErrInvalidArgument 表示调用方传入的参数不合法，例如负数的内存限制、保留的标签或者相对路径的 ballast_path
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrInvalidSpec 表示 FromSpec 的描述无法解析或者不合法
+	ErrInvalidSpec = errors.New("invalid container spec")
+
+	// ErrRegistryAuth 表示拉取镜像时镜像仓库的认证失败，与镜像不存在区分开
+	ErrRegistryAuth = errors.New("registry authentication failed")
+
+	// ErrBallastIneffective 表示 /ballast 创建成功但是 df 的已用空间没有增加预期的大小，例如 fallocate 创建了稀疏文件，
+	// 这样的 /ballast 无法保护容器
+	ErrBallastIneffective = errors.New("ballast does not reserve space")
+
+	// ErrUpperDirUnavailable 表示存储驱动没有提供容器可写层在宿主机上的路径
+	ErrUpperDirUnavailable = errors.New("container upperdir unavailable")
+
+	// ErrPermissionDenied 表示容器内的命令因为 exec 用户没有权限而失败，通常需要通过 WithExecUser 或者 RunOptions.ExecUser 修改用户
+	ErrPermissionDenied = errors.New("permission denied in container")
+
+	// ErrNetworkNotFound 表示 RunOptions.Networks 中的网络不存在，并且没有开启 WithNetworkAutoCreate
+	ErrNetworkNotFound = errors.New("network not found")
+
+	// ErrUnsupportedPlatform 表示 Docker daemon 运行的不是 Linux 容器，/ballast 依赖的 fallocate、df 等命令在 Windows 容器中不存在
+	ErrUnsupportedPlatform = errors.New("unsupported container platform")
+
+	// ErrImmutable 表示 Docker 不支持修改已经创建的容器的标签，只能重新创建容器
+	ErrImmutable = errors.New("container labels are immutable")
+
+	// ErrAlreadyRunning 表示 Start 的容器已经在运行，可以使用 WithIdempotentStartStop 改为直接返回
+	ErrAlreadyRunning = errors.New("container is already running")
+
+	// ErrAlreadyStopped 表示 Stop 的容器已经停止，可以使用 WithIdempotentStartStop 改为直接返回
+	ErrAlreadyStopped = errors.New("container is already stopped")
+
+	// ErrClosed 表示 DockerContainer 已经被 Close，不能再使用
+	ErrClosed = errors.New("container client is closed")
+)
+
+// InsufficientSpaceError 表示创建 /ballast 时磁盘空间不足（ENOSPC），通常是宿主机的存储已经快满了，
+// 调度方可以据此选择其他宿主机。使用 errors.Is(err, ErrInsufficientSpace) 判断
+type InsufficientSpaceError struct {
+	// Requested 还需要为 /ballast 分配的字节数
+	Requested int64
+	// Available 分配失败后 df 显示的剩余空间，单位为字节
+	Available int64
+	// Err 分配命令返回的原始错误
+	Err error
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("%v: requested %d bytes, available %d bytes", ErrInsufficientSpace, e.Requested, e.Available)
+}
+
+func (e *InsufficientSpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+func (e *InsufficientSpaceError) Unwrap() error {
+	return e.Err
+}
+
+// BallastWarning 表示容器已经创建并且正在运行，只是 /ballast 创建失败，只在 RunOptions.BallastOptional 时返回
+//
+// 此时 Run 同时返回容器 ID，调用方使用 errors.As 判断后可以继续使用容器，
+// 之后可以通过 Reconcile 或者重新 Start 再次创建 /ballast。
+type BallastWarning struct {
+	Container string
+	ID        string
+	// Err 创建 /ballast 失败的原因
+	Err error
+}
+
+func (w *BallastWarning) Error() string {
+	return fmt.Sprintf("container %s is running without ballast: %v", w.Container, w.Err)
+}
+
+func (w *BallastWarning) Unwrap() error {
+	return w.Err
+}
+
+// wrapNotFound 将 Docker 返回的容器不存在错误转换为 ErrContainerNotFound，其他错误原样返回
+func wrapNotFound(err error) error {
+	if errdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrContainerNotFound, err)
+	}
+	return err
+}
+
+// Kind 是错误的类别，用于 RPC、HTTP 等接口将错误转换为状态码
+type Kind string
+
+const (
+	// KindNotFound 容器或者 /ballast 不存在
+	KindNotFound Kind = "notfound"
+	// KindConflict 容器的状态不允许该操作，例如名称冲突、容器正在运行或者没有运行
+	KindConflict Kind = "conflict"
+	// KindNoSpace 磁盘空间不足
+	KindNoSpace Kind = "nospace"
+	// KindInvalid 参数不合法，例如容器名称不符合 Docker 的要求
+	KindInvalid Kind = "invalid"
+	// KindUnauthorized 镜像仓库的认证失败
+	KindUnauthorized Kind = "unauthorized"
+	// KindTransient 临时错误，例如连接 Docker daemon 失败、超时，可以稍后重试
+	KindTransient Kind = "transient"
+	// KindInternal 其他错误
+	KindInternal Kind = "internal"
+)
+
+// OpError 是 DockerContainer 的方法返回的错误，记录了失败的操作、容器名称和错误类别，
+// 可以通过 errors.As 获取，errors.Is 仍然可以判断 ErrContainerNotFound 等具体的错误
+//
+// 一个方法内部调用了另一个方法时（例如 Restart 调用 Stop），保留最内层的 OpError。
+type OpError struct {
+	// Op 失败的操作，例如 run、stop
+	Op string
+	// Container 容器名称，与容器无关的操作为空
+	Container string
+	// ID 失败后仍然保留的容器 ID，只有 RunOptions.KeepOnFailure 时 run 会设置
+	ID string
+	// Kind 错误的类别
+	Kind Kind
+	// Err 原始的错误
+	Err error
+}
+
+// Error 返回原始错误的信息，原始错误中已经包含了操作和容器名称
+func (e *OpError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON 将 OpError 序列化为 {"op": ..., "container": ..., "id": ..., "kind": ..., "error": ...}
+func (e *OpError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Op        string `json:"op"`
+		Container string `json:"container,omitempty"`
+		ID        string `json:"id,omitempty"`
+		Kind      Kind   `json:"kind"`
+		Error     string `json:"error"`
+	}{e.Op, e.Container, e.ID, e.Kind, e.Error()})
+}
+
+// KindOf 返回 err 的类别，err 是 OpError 时直接返回其 Kind，为 nil 时返回空字符串
+func KindOf(err error) Kind {
+	var opErr *OpError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &opErr):
+		return opErr.Kind
+	case errors.Is(err, ErrRegistryAuth):
+		return KindUnauthorized
+	case errors.Is(err, ErrContainerNotFound), errors.Is(err, ErrBallastNotFound), errors.Is(err, ErrNetworkNotFound),
+		errdefs.IsNotFound(err):
+		return KindNotFound
+	case errors.Is(err, ErrNameConflict), errors.Is(err, ErrContainerRunning), errors.Is(err, ErrContainerNotRunning),
+		errors.Is(err, ErrMonitorRunning), errors.Is(err, ErrRecreateRequired), errors.Is(err, ErrImmutable),
+		errors.Is(err, ErrAlreadyRunning), errors.Is(err, ErrAlreadyStopped), errdefs.IsConflict(err):
+		return KindConflict
+	case errors.Is(err, ErrInsufficientSpace):
+		return KindNoSpace
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidSpec), errors.Is(err, ErrInvalidArgument),
+		errdefs.IsInvalidParameter(err):
+		return KindInvalid
+	case errors.Is(err, context.DeadlineExceeded), isTransient(err):
+		return KindTransient
+	}
+	return KindInternal
+}
+
+// newOpError 将 err 包装为 OpError，err 为 nil 或者已经包含 OpError 时原样返回
+func newOpError(op, name string, err error) error {
+	var opErr *OpError
+	if err == nil || errors.As(err, &opErr) {
+		return err
+	}
+	return &OpError{Op: op, Container: name, Kind: KindOf(err), Err: err}
+}
+
+// wrapOp 在 defer 中使用，将方法返回的 *errp 包装为 OpError
+func wrapOp(op, name string, errp *error) {
+	*errp = newOpError(op, name, *errp)
+}
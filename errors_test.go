@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestWrapNotFound(t *testing.T) {
+	notFound := errdefs.NotFound(errors.New("No such container: test"))
+	err := fmt.Errorf("failed to inspect container test: %w", wrapNotFound(notFound))
+	if !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+
+	other := errors.New("connection refused")
+	if err := wrapNotFound(other); errors.Is(err, ErrContainerNotFound) || err != other {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Kind
+	}{
+		{nil, ""},
+		{fmt.Errorf("failed to stop container test: %w", ErrContainerNotFound), KindNotFound},
+		{errdefs.NotFound(errors.New("No such container: test")), KindNotFound},
+		{ErrNameConflict, KindConflict},
+		{ErrContainerNotRunning, KindConflict},
+		{&InsufficientSpaceError{Requested: 1, Err: errors.New("No space left on device")}, KindNoSpace},
+		{ErrInvalidName, KindInvalid},
+		{ErrInvalidSpec, KindInvalid},
+		{ErrInvalidArgument, KindInvalid},
+		{fmt.Errorf("failed to pull image app: %w", ErrRegistryAuth), KindUnauthorized},
+		{context.DeadlineExceeded, KindTransient},
+		{errdefs.Unavailable(errors.New("daemon is restarting")), KindTransient},
+		{errors.New("boom"), KindInternal},
+		{&OpError{Op: "run", Kind: KindConflict, Err: errors.New("boom")}, KindConflict},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.err); got != tt.want {
+			t.Errorf("KindOf(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestOpError(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	err := dc.Stop("missing")
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("Stop() error = %v, want *OpError", err)
+	}
+	if opErr.Op != "stop" || opErr.Container != "missing" || opErr.Kind != KindNotFound {
+		t.Fatalf("OpError = %+v", opErr)
+	}
+	if !errors.Is(err, ErrContainerNotFound) {
+		t.Fatal("errors.Is should see ErrContainerNotFound through OpError")
+	}
+
+	data, err := json.Marshal(opErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["op"] != "stop" || got["container"] != "missing" || got["kind"] != "notfound" || got["error"] != opErr.Error() {
+		t.Fatalf("MarshalJSON() = %s", data)
+	}
+
+	// 嵌套调用时保留最内层的 OpError
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Rename(context.Background(), "test", "bad name"); !errors.As(err, &opErr) || opErr.Op != "rename" || opErr.Kind != KindInvalid {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if err := dc.Restart(context.Background(), "missing"); !errors.As(err, &opErr) || opErr.Op != "stop" {
+		t.Fatalf("Restart() error = %v", err)
+	}
+
+	// 批量操作同样返回 OpError
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dc.PruneStopped(ctx, 0); !errors.As(err, &opErr) || opErr.Op != "prune_stopped" || !errors.Is(err, context.Canceled) {
+		t.Fatalf("PruneStopped() error = %v", err)
+	}
+	if _, err := dc.ReconcileAll(ctx); !errors.As(err, &opErr) || opErr.Op != "reconcile_all" || !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+}
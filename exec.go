@@ -0,0 +1,201 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Exec 在容器内执行命令，分别返回标准输出、标准错误和退出码
+//
+// 与内部使用的 executeCommand 不同，命令以非 0 状态码退出不会被当作错误，
+// err 只表示 exec 本身失败，例如容器不存在或者没有运行。命令以容器主进程的用户执行，不使用 WithExecUser 设置的用户。
+func (dc *DockerContainer) Exec(ctx context.Context, name string, cmd []string) (stdout, stderr string, exitCode int, err error) {
+	defer wrapOp("exec", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	stdout, stderr, exitCode, err = dc.exec(ctx, containerInspect.ID, cmd, execOptions{})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to exec in container %s: %w", name, err)
+	}
+	return stdout, stderr, exitCode, nil
+}
+
+// ExecStream 与 Exec 相同，但命令的标准输出和标准错误会在读取的同时写入 stdout 和 stderr，不会缓存在内存中，
+// 适用于输出很多的命令，stdout 或者 stderr 为 nil 时丢弃对应的输出
+func (dc *DockerContainer) ExecStream(ctx context.Context, name string, cmd []string, stdout, stderr io.Writer) (exitCode int, err error) {
+	defer wrapOp("exec", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	exitCode, err = dc.execStream(ctx, containerInspect.ID, cmd, execOptions{}, stdout, stderr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to exec in container %s: %w", name, err)
+	}
+	return exitCode, nil
+}
+
+// executeCommand 以 exec 用户在容器内执行命令并返回标准输出，命令以非 0 状态码退出时返回 *exitError
+func (dc *DockerContainer) executeCommand(ctx context.Context, containerID string, cmd []string) (string, error) {
+	user := dc.execUserOf(ctx, containerID)
+	stdout, stderr, exitCode, err := dc.exec(ctx, containerID, cmd, execOptions{user: user})
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", &exitError{code: exitCode, user: user, stdout: stdout, stderr: stderr}
+	}
+	return stdout, nil
+}
+
+// exec 在容器内执行命令，返回标准输出、标准错误和退出码，输出会被完整读入内存，只用于输出较少的命令
+func (dc *DockerContainer) exec(ctx context.Context, containerID string, cmd []string, opts execOptions) (stdout, stderr string, exitCode int, err error) {
+	var outBuf, errBuf bytes.Buffer
+	exitCode, err = dc.execStream(ctx, containerID, cmd, opts, &outBuf, &errBuf)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return outBuf.String(), errBuf.String(), exitCode, nil
+}
+
+// execOptions 是 exec 的参数
+type execOptions struct {
+	// tty 是否为 exec 分配 TTY，容器本身以 Tty: true 创建，但 exec 是否使用 TTY 需要单独设置。
+	// df、stat 等需要解析输出的命令必须为 false，否则输出中会混入 \r\n，并且无法区分标准输出和标准错误
+	tty bool
+	// user 执行命令的用户，为空时使用容器主进程的用户
+	user string
+}
+
+// execStream 在容器内执行命令，将标准输出和标准错误分别写入 stdout 和 stderr，返回退出码
+//
+// 使用 TTY 时 Docker 返回的是原始的字节流，标准错误同样会写入 stdout。
+func (dc *DockerContainer) execStream(ctx context.Context, containerID string, cmd []string, opts execOptions, stdout, stderr io.Writer) (exitCode int, err error) {
+	defer dc.observeDuration("exec", dc.containerName(containerID))()
+	ctx, span := dc.startSpan(ctx, "ballast.exec", Attribute{attrContainerID, containerID}, Attribute{attrExecCommand, strings.Join(cmd, " ")})
+	defer endSpan(span, &err)
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if debugEnabled(dc.logger) {
+		outBuf, errBuf := &truncatedBuffer{limit: maxDebugOutput}, &truncatedBuffer{limit: maxDebugOutput}
+		stdout, stderr = io.MultiWriter(stdout, outBuf), io.MultiWriter(stderr, errBuf)
+		start := time.Now()
+		defer func() {
+			debugf(dc.logger, "Exec %q in container %.12s exited with code %d in %s, err: %v, stdout: %q, stderr: %q",
+				cmd, containerID, exitCode, time.Since(start), err, outBuf, errBuf)
+		}()
+	}
+
+	execConfig := types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          opts.tty,
+		User:         opts.user,
+		Cmd:          cmd,
+	}
+	execIDResp, err := dc.cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	execAttachResp, err := dc.cli.ContainerExecAttach(ctx, execIDResp.ID, types.ExecStartCheck{Tty: opts.tty})
+	if err != nil {
+		return 0, fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer execAttachResp.Close()
+
+	if err := copyExecOutput(stdout, stderr, execAttachResp.Reader, opts.tty); err != nil {
+		return 0, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	execInspect, err := dc.cli.ContainerExecInspect(ctx, execIDResp.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	span.SetAttributes(Attribute{attrExecExitCode, execInspect.ExitCode})
+	return execInspect.ExitCode, nil
+}
+
+// maxDebugOutput 调试日志中每条命令的标准输出和标准错误最多保留的字节数
+const maxDebugOutput = 512
+
+// truncatedBuffer 只保留写入的前 limit 个字节，用于在调试日志中输出命令的部分输出
+type truncatedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	dropped int
+}
+
+func (b *truncatedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.dropped += len(p) - max(room, 0)
+		p = p[:max(room, 0)]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *truncatedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.buf.String()
+	}
+	return fmt.Sprintf("%s...(%d more bytes)", b.buf.String(), b.dropped)
+}
+
+// exitError 表示容器内的命令以非 0 状态码退出，因为没有权限失败时 errors.Is(err, ErrPermissionDenied) 为 true
+type exitError struct {
+	code   int
+	user   string
+	stdout string
+	stderr string
+}
+
+func (e *exitError) Error() string {
+	output := strings.TrimSpace(e.stderr + e.stdout)
+	if e.permissionDenied() {
+		return fmt.Sprintf("%v: command run as user %q exited with code %d: %s", ErrPermissionDenied, e.user, e.code, output)
+	}
+	return fmt.Sprintf("command exited with code %d: %s", e.code, output)
+}
+
+func (e *exitError) Is(target error) bool {
+	return target == ErrPermissionDenied && e.permissionDenied()
+}
+
+// permissionDenied 根据标准错误判断命令是否因为 EACCES 或者 EPERM 失败
+func (e *exitError) permissionDenied() bool {
+	stderr := strings.ToLower(e.stderr)
+	return strings.Contains(stderr, "permission denied") || strings.Contains(stderr, "operation not permitted")
+}
+
+// copyExecOutput 将 exec 返回的输出流写入 stdout 和 stderr，读取后立即写入，不会缓存整个输出
+//
+// 非 TTY 模式下 Docker 会在每一帧数据前加上 8 字节的头部，需要使用 stdcopy 拆分，直接读取会把头部混入输出中；
+// TTY 模式下输出流没有多路复用，全部写入 stdout，此时使用 stdcopy 反而会把输出当作错误的头部解析。
+func copyExecOutput(stdout, stderr io.Writer, r io.Reader, tty bool) error {
+	if tty {
+		_, err := io.Copy(stdout, r)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	return err
+}
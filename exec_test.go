@@ -0,0 +1,211 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestCopyExecOutput(t *testing.T) {
+	var stream bytes.Buffer
+	stdoutWriter := stdcopy.NewStdWriter(&stream, stdcopy.Stdout)
+	stderrWriter := stdcopy.NewStdWriter(&stream, stdcopy.Stderr)
+
+	_, _ = stdoutWriter.Write([]byte("4500000000\n"))
+	_, _ = stderrWriter.Write([]byte("warning: something\n"))
+	_, _ = stdoutWriter.Write([]byte("done\n"))
+
+	var stdout, stderr bytes.Buffer
+	if err := copyExecOutput(&stdout, &stderr, &stream, false); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "4500000000\ndone\n" {
+		t.Fatalf("unexpected stdout %q", stdout.String())
+	}
+	if stderr.String() != "warning: something\n" {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+}
+
+func TestExec(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] != "probe" {
+			return fakeExecResult{}, false
+		}
+		return fakeExecResult{stdout: "partial\n", stderr: "probe failed\n", exitCode: 3}, true
+	}
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, exitCode, err := dc.Exec(context.Background(), "test", []string{"probe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "partial\n" || stderr != "probe failed\n" || exitCode != 3 {
+		t.Fatalf("Exec = %q, %q, %d", stdout, stderr, exitCode)
+	}
+
+	if _, _, _, err := dc.Exec(context.Background(), "missing", []string{"probe"}); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+// countingWriter 只记录写入的字节数，不保存内容
+type countingWriter struct {
+	bytes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.bytes += len(p)
+	return len(p), nil
+}
+
+func TestExecStream(t *testing.T) {
+	api := newFakeDockerAPI()
+	line := strings.Repeat("x", 1023) + "\n"
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] != "find" {
+			return fakeExecResult{}, false
+		}
+		return fakeExecResult{stdout: strings.Repeat(line, 4096), stderr: "permission denied\n", exitCode: 1}, true
+	}
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout countingWriter
+	exitCode, err := dc.ExecStream(context.Background(), "test", []string{"find", "/"}, &stdout, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 1 || stdout.bytes != 4096*1024 {
+		t.Fatalf("ExecStream = %d, %d bytes, want 1, %d bytes", exitCode, stdout.bytes, 4096*1024)
+	}
+
+	if _, err := dc.ExecStream(context.Background(), "missing", []string{"find", "/"}, nil, nil); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestExecTTY(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] != "probe" {
+			return fakeExecResult{}, false
+		}
+		return fakeExecResult{stdout: "4500000000\n", stderr: "warning\n"}, true
+	}
+	dc := newTestContainer(api)
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 内部执行的 df、stat 等命令必须显式关闭 TTY，输出是多路复用的
+	stdout, err := dc.executeCommand(ctx, id, []string{"probe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "4500000000\n" {
+		t.Fatalf("non-TTY stdout = %q", stdout)
+	}
+	for _, opts := range api.execOptions {
+		if opts.Tty {
+			t.Fatalf("exec %v should not use a TTY", opts.Cmd)
+		}
+	}
+
+	// TTY 模式下输出没有多路复用，标准错误同样写入 stdout
+	var out, errOut bytes.Buffer
+	if _, err := dc.execStream(ctx, id, []string{"probe"}, execOptions{tty: true}, &out, &errOut); err != nil {
+		t.Fatal(err)
+	}
+	if !api.execOptions[len(api.execOptions)-1].Tty {
+		t.Fatal("exec should use a TTY")
+	}
+	if out.String() != "4500000000\r\nwarning\r\n" || errOut.Len() != 0 {
+		t.Fatalf("TTY stdout = %q, stderr = %q", out.String(), errOut.String())
+	}
+}
+
+func TestExecUser(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	id, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", User: "1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	if c.json.Config.User != "1000" || c.json.Config.Labels[defaultLabelPrefix+labelExecUser] != defaultExecUser {
+		t.Fatalf("user = %q, labels = %v", c.json.Config.User, c.json.Config.Labels)
+	}
+	// /ballast 相关的命令默认以 root 执行，与容器主进程的用户无关
+	for _, opts := range api.execOptions {
+		if opts.User != defaultExecUser {
+			t.Fatalf("exec %v ran as user %q, want %q", opts.Cmd, opts.User, defaultExecUser)
+		}
+	}
+	// Exec 以容器主进程的用户执行
+	if _, _, _, err := dc.Exec(context.Background(), "test", []string{"id"}); err != nil {
+		t.Fatal(err)
+	}
+	if user := api.execOptions[len(api.execOptions)-1].User; user != "" {
+		t.Fatalf("Exec ran as user %q, want the container user", user)
+	}
+
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "other", ExecUser: "ballast"}); err != nil {
+		t.Fatal(err)
+	}
+	// 新的 DockerContainer 没有缓存，从 exec_user 标签中读取用户
+	api.execOptions = nil
+	other := newTestContainer(api, WithExecUser("nobody"))
+	if err := other.Stop("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.executeCommand(context.Background(), id, []string{"true"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.execOptions) < 2 {
+		t.Fatalf("expected commands to run, got %v", api.execOptions)
+	}
+	for _, opts := range api.execOptions[:len(api.execOptions)-1] {
+		if opts.User != "ballast" {
+			t.Fatalf("exec %v ran as user %q, want %q", opts.Cmd, opts.User, "ballast")
+		}
+	}
+	if user := api.execOptions[len(api.execOptions)-1].User; user != defaultExecUser {
+		t.Fatalf("exec in %s ran as user %q, want %q", id, user, defaultExecUser)
+	}
+}
+
+func TestExecPermissionDenied(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if !strings.Contains(strings.Join(cmd, " "), ballastPath) {
+			return fakeExecResult{}, false
+		}
+		return fakeExecResult{stderr: "sh: can't create /ballast: Permission denied\n", exitCode: 1}, true
+	}
+	dc := newTestContainer(api, WithExecUser("app"))
+	_, err := dc.Run("test")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `user "app"`) {
+		t.Fatalf("error %q should mention the exec user", err)
+	}
+
+	exitErr := &exitError{code: 1, stderr: "No such file or directory\n"}
+	if errors.Is(exitErr, ErrPermissionDenied) {
+		t.Fatal("a missing file is not a permission error")
+	}
+}
@@ -0,0 +1,902 @@
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/dustin/go-humanize"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeContainer 是 fakeDockerAPI 中的一个容器，模拟了容器内的磁盘使用情况
+type fakeContainer struct {
+	json       types.ContainerJSON
+	hostConfig *container.HostConfig
+	// starting 容器还需要多少次 ContainerInspect 才会进入运行状态
+	starting int
+	// health ContainerInspect 依次返回的健康检查结果，最后一个结果会一直保留
+	health []*types.Health
+	// files 通过 CopyToContainer 写入的文件
+	files map[string][]byte
+	// stats ContainerStats 依次返回的数据
+	stats []container.StatsResponse
+	// logs 容器的日志，非 TTY 容器会按照 Docker 的格式多路复用
+	logs []fakeExecResult
+	// dataUsed 除 /ballast 外已经使用的空间
+	dataUsed int64
+	// ballast /ballast 文件的大小，-1 表示文件不存在
+	ballast int64
+	// chunks 分片文件的大小
+	chunks map[string]int64
+	// sparse /ballast 是否是稀疏文件，稀疏文件不占用空间
+	sparse bool
+	// loops 通过 losetup 挂载的 loop 设备，键为设备路径
+	loops map[string]*fakeLoop
+}
+
+// fakeLoop 是一个 loop 设备，文件被删除后空间要等到设备卸载才会释放
+type fakeLoop struct {
+	path    string
+	size    int64
+	deleted bool
+}
+
+// fakeExecResult 是一次 exec 的输出
+type fakeExecResult struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	// tty 为 true 时 ContainerExecAttach 返回没有多路复用的原始输出，与 Docker 的行为一致
+	tty bool
+}
+
+// fakeDockerAPI 是不依赖 Docker daemon 的 DockerAPI 实现
+type fakeDockerAPI struct {
+	mu sync.Mutex
+
+	info       system.Info
+	images     map[string]bool
+	containers map[string]*fakeContainer
+	execs      map[string]fakeExecResult
+	nextID     int
+
+	// execFn 不为空时优先处理 exec，返回 false 时使用默认的模拟逻辑
+	execFn func(c *fakeContainer, cmd []string) (fakeExecResult, bool)
+
+	// commands 记录所有执行过的命令
+	commands [][]string
+	// execOptions 记录每次 exec 的参数
+	execOptions []container.ExecOptions
+	// startingInspects 不为 0 时，容器启动后第 startingInspects 次 ContainerInspect 才会进入运行状态
+	startingInspects int
+	// stopped 记录所有停止过的容器
+	stopped []string
+	// stopOptions 记录每次停止容器时的参数
+	stopOptions []container.StopOptions
+	// pulled 记录所有拉取过的镜像
+	pulled []string
+	// pullAuth 记录拉取镜像时使用的 RegistryAuth
+	pullAuth []string
+	// pullHang 为 true 时 ImagePull 返回的进度在被关闭前不会有任何输出
+	pullHang bool
+	// pullErr 不为空时 ImagePull 直接返回该错误
+	pullErr error
+	// startErr 不为空时 ContainerStart 直接返回该错误
+	startErr error
+	// inspects 记录 ContainerInspect 被调用的次数
+	inspects int
+	// networks 已经存在的网络
+	networks map[string]bool
+	// connects 记录 NetworkConnect 的调用，格式为 network/container
+	connects []string
+	// commits 记录每次 ContainerCommit 的参数以及当时 /ballast 的大小
+	commits []fakeCommit
+	// commitErr 不为空时 ContainerCommit 直接返回该错误
+	commitErr error
+	// exports 记录每次 ContainerExport 时 /ballast 的大小
+	exports []int64
+	// closed 记录 Close 被调用的次数
+	closed int
+	// version 和 host 分别为 ClientVersion 和 DaemonHost 的返回值，为空时使用默认值
+	version string
+	host    string
+}
+
+func newFakeDockerAPI() *fakeDockerAPI {
+	return &fakeDockerAPI{
+		info: system.Info{
+			Driver:       "overlay2",
+			DriverStatus: [][2]string{{"Backing Filesystem", "xfs"}},
+		},
+		images:     map[string]bool{defaultImage: true},
+		containers: make(map[string]*fakeContainer),
+		execs:      make(map[string]fakeExecResult),
+		networks:   map[string]bool{"bridge": true},
+	}
+}
+
+// newTestContainer 创建一个使用 fakeDockerAPI 的 DockerContainer
+func newTestContainer(api *fakeDockerAPI, opts ...Option) *DockerContainer {
+	c, err := NewWithClient(api, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c.(*DockerContainer)
+}
+
+// lookup 按名称或者 ID 查找容器，调用方需要持有锁
+func (f *fakeDockerAPI) lookup(nameOrID string) (*fakeContainer, error) {
+	if c, ok := f.containers[nameOrID]; ok {
+		return c, nil
+	}
+	for _, c := range f.containers {
+		if c.json.ID == nameOrID {
+			return c, nil
+		}
+	}
+	return nil, errdefs.NotFound(fmt.Errorf("No such container: %s", nameOrID))
+}
+
+// limit 返回容器的系统盘限制大小
+func (c *fakeContainer) limit() int64 {
+	if c.hostConfig != nil {
+		if size, err := strconv.ParseInt(c.hostConfig.StorageOpt["size"], 10, 64); err == nil {
+			return size
+		}
+	}
+	return int64(defaultStorageSize.Add(ballastSize))
+}
+
+func (c *fakeContainer) used() int64 {
+	used := c.dataUsed
+	if c.ballast > 0 && !c.sparse {
+		used += c.ballast
+	}
+	for _, size := range c.chunks {
+		used += size
+	}
+	for _, loop := range c.loops {
+		if loop.deleted {
+			used += loop.size
+		}
+	}
+	return used
+}
+
+// exec 模拟容器内 df、stat、test、rm、fallocate 和 losetup 命令
+func (c *fakeContainer) exec(cmd []string) fakeExecResult {
+	if len(cmd) == 3 && strings.HasSuffix(cmd[0], "sh") && cmd[1] == "-c" {
+		// 依次执行 ; 分隔的多条命令，退出码为最后一条命令的退出码
+		var result fakeExecResult
+		for _, part := range strings.Split(cmd[2], ";") {
+			fields := strings.Fields(part)
+			discardStderr := len(fields) > 0 && fields[len(fields)-1] == "2>/dev/null"
+			if discardStderr {
+				fields = fields[:len(fields)-1]
+			}
+			r := c.exec(fields)
+			result.stdout += r.stdout
+			if !discardStderr {
+				result.stderr += r.stderr
+			}
+			result.exitCode = r.exitCode
+		}
+		return result
+	}
+	if len(cmd) == 0 {
+		return fakeExecResult{}
+	}
+
+	switch cmd[0] {
+	case "echo":
+		return fakeExecResult{stdout: strings.Join(cmd[1:], " ") + "\n"}
+	case "df":
+		limit := c.limit()
+		return fakeExecResult{stdout: fmt.Sprintf("Filesystem 1-blocks Used Available Capacity Mounted on\noverlay %d %d %d 0%% /\n",
+			limit, c.used(), limit-c.used())}
+	case "stat":
+		path := cmd[len(cmd)-1]
+		if strings.Contains(path, "*") {
+			return c.statGlob(path)
+		}
+		size := c.fileSize(path)
+		if size < 0 {
+			return fakeExecResult{stderr: fmt.Sprintf("stat: cannot statx '%s': No such file or directory\n", path), exitCode: 1}
+		}
+		return fakeExecResult{stdout: fmt.Sprintf("%d\n", size)}
+	case "test":
+		if len(cmd) == 3 && cmd[1] == "-e" && c.fileSize(cmd[2]) < 0 {
+			return fakeExecResult{exitCode: 1}
+		}
+		return fakeExecResult{}
+	case "rm":
+		for _, path := range cmd[1:] {
+			for _, loop := range c.loops {
+				if loop.path == path {
+					loop.deleted = true
+				}
+			}
+			if path == ballastPath {
+				c.ballast = -1
+				c.sparse = false
+			} else if !strings.HasPrefix(path, "-") {
+				delete(c.chunks, path)
+			}
+		}
+		return fakeExecResult{}
+	case "losetup":
+		return c.losetup(cmd[1:])
+	case "fallocate", "truncate":
+		size, err := humanize.ParseBytes(cmd[2])
+		if err != nil {
+			return fakeExecResult{stderr: err.Error(), exitCode: 1}
+		}
+		return c.allocate(cmd[0], cmd[3], int64(size))
+	case "dd":
+		var (
+			bs, count int64
+			path      string
+		)
+		for _, arg := range cmd[1:] {
+			if v, ok := strings.CutPrefix(arg, "bs="); ok {
+				bs, _ = strconv.ParseInt(v, 10, 64)
+			}
+			if v, ok := strings.CutPrefix(arg, "count="); ok {
+				count, _ = strconv.ParseInt(v, 10, 64)
+			}
+			if v, ok := strings.CutPrefix(arg, "of="); ok {
+				path = v
+			}
+		}
+		// dd 会覆盖已经存在的文件
+		c.setFileSize(path, -1)
+		return c.allocate("dd", path, bs*count)
+	}
+	return fakeExecResult{}
+}
+
+// losetup 模拟 losetup -f、-j 和 -d
+func (c *fakeContainer) losetup(args []string) fakeExecResult {
+	if len(args) != 2 {
+		return fakeExecResult{stderr: "losetup: bad usage", exitCode: 1}
+	}
+	switch args[0] {
+	case "-f":
+		size := c.fileSize(args[1])
+		if size < 0 {
+			return fakeExecResult{stderr: fmt.Sprintf("losetup: %s: failed to set up loop device: No such file or directory", args[1]), exitCode: 1}
+		}
+		if c.loops == nil {
+			c.loops = make(map[string]*fakeLoop)
+		}
+		dev := fmt.Sprintf("/dev/loop%d", len(c.loops))
+		for i := 0; c.loops[dev] != nil; i++ {
+			dev = fmt.Sprintf("/dev/loop%d", i)
+		}
+		c.loops[dev] = &fakeLoop{path: args[1], size: size}
+	case "-j":
+		var result fakeExecResult
+		for dev, loop := range c.loops {
+			if loop.path == args[1] && !loop.deleted {
+				result.stdout += fmt.Sprintf("%s: []: (%s)\n", dev, loop.path)
+			}
+		}
+		return result
+	case "-d":
+		if c.loops[args[1]] == nil {
+			return fakeExecResult{stderr: fmt.Sprintf("losetup: %s: detach failed: No such device or address", args[1]), exitCode: 1}
+		}
+		delete(c.loops, args[1])
+	}
+	return fakeExecResult{}
+}
+
+// allocate 模拟创建 /ballast 文件或者分片，已经存在的文件只会被扩大，分片不会是稀疏文件
+func (c *fakeContainer) allocate(tool, path string, size int64) fakeExecResult {
+	current := c.fileSize(path)
+	if current < 0 {
+		current = 0
+	}
+	if size-current > c.limit()-c.used() {
+		return fakeExecResult{stderr: tool + ": No space left on device\n", exitCode: 1}
+	}
+	if size > current || c.fileSize(path) < 0 {
+		c.setFileSize(path, size)
+	}
+	if path == ballastPath {
+		c.sparse = tool == "truncate"
+	}
+	return fakeExecResult{}
+}
+
+// statGlob 模拟 shell 展开通配符后执行 stat -c %s
+func (c *fakeContainer) statGlob(pattern string) fakeExecResult {
+	var paths []string
+	for p := range c.chunks {
+		if ok, _ := path.Match(pattern, p); ok {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return fakeExecResult{stderr: fmt.Sprintf("stat: cannot statx '%s': No such file or directory\n", pattern), exitCode: 1}
+	}
+	sort.Strings(paths)
+
+	var result fakeExecResult
+	for _, p := range paths {
+		result.stdout += fmt.Sprintf("%d\n", c.chunks[p])
+	}
+	return result
+}
+
+// fileSize 返回 /ballast 或者分片的大小，-1 表示文件不存在
+func (c *fakeContainer) fileSize(path string) int64 {
+	if path == ballastPath {
+		return c.ballast
+	}
+	if size, ok := c.chunks[path]; ok {
+		return size
+	}
+	return -1
+}
+
+func (c *fakeContainer) setFileSize(path string, size int64) {
+	if path == ballastPath {
+		c.ballast = size
+		return
+	}
+	if size < 0 {
+		delete(c.chunks, path)
+		return
+	}
+	if c.chunks == nil {
+		c.chunks = make(map[string]int64)
+	}
+	c.chunks[path] = size
+}
+
+func (f *fakeDockerAPI) ContainerCreate(_ context.Context, config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.containers[containerName]; ok {
+		return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf("Conflict. The container name \"/%s\" is already in use", containerName))
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("id-%d", f.nextID)
+	f.containers[containerName] = &fakeContainer{
+		json: types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:         id,
+				Name:       "/" + containerName,
+				State:      &types.ContainerState{Status: "created"},
+				HostConfig: hostConfig,
+			},
+			Config: config,
+		},
+		hostConfig: hostConfig,
+		ballast:    -1,
+	}
+	return container.CreateResponse{ID: id}, nil
+}
+
+func (f *fakeDockerAPI) ContainerStart(_ context.Context, name string, _ container.StartOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.startErr != nil {
+		return f.startErr
+	}
+	if f.startingInspects > 0 {
+		c.starting = f.startingInspects
+		c.json.State.Status = "created"
+		return nil
+	}
+	c.json.State.Running = true
+	c.json.State.Status = "running"
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerStop(_ context.Context, name string, options container.StopOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	c.json.State.Running = false
+	c.json.State.Paused = false
+	c.json.State.Status = "exited"
+	c.json.State.FinishedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	f.stopped = append(f.stopped, name)
+	f.stopOptions = append(f.stopOptions, options)
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerPause(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	c.json.State.Paused = true
+	c.json.State.Status = "paused"
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerUnpause(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	c.json.State.Paused = false
+	c.json.State.Status = "running"
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerRename(_ context.Context, name, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.containers[newName]; ok {
+		return errdefs.Conflict(fmt.Errorf("Conflict. The container name \"/%s\" is already in use", newName))
+	}
+	delete(f.containers, strings.TrimPrefix(c.json.Name, "/"))
+	c.json.Name = "/" + newName
+	f.containers[newName] = c
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerRemove(_ context.Context, name string, options container.RemoveOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	if c.json.State.Running && !options.Force {
+		return errdefs.Conflict(errors.New("cannot remove a running container"))
+	}
+	delete(f.containers, strings.TrimPrefix(c.json.Name, "/"))
+	return nil
+}
+
+func (f *fakeDockerAPI) ContainerWait(ctx context.Context, name string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	respCh := make(chan container.WaitResponse, 1)
+	errCh := make(chan error, 1)
+
+	f.mu.Lock()
+	c, err := f.lookup(name)
+	f.mu.Unlock()
+	if err != nil {
+		errCh <- err
+		return respCh, errCh
+	}
+
+	// 轮询容器状态直到满足 condition
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			f.mu.Lock()
+			_, exists := f.containers[strings.TrimPrefix(c.json.Name, "/")]
+			running := c.json.State.Running
+			exitCode := c.json.State.ExitCode
+			f.mu.Unlock()
+
+			if (condition == container.WaitConditionRemoved && !exists) || (condition != container.WaitConditionRemoved && !running) {
+				respCh <- container.WaitResponse{StatusCode: int64(exitCode)}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return respCh, errCh
+}
+
+func (f *fakeDockerAPI) ContainerInspect(_ context.Context, name string) (types.ContainerJSON, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.inspects++
+	c, err := f.lookup(name)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	if c.starting > 0 {
+		c.starting--
+		if c.starting == 0 {
+			c.json.State.Running = true
+			c.json.State.Status = "running"
+		}
+	}
+	if len(c.health) > 0 {
+		c.json.State.Health = c.health[0]
+		if len(c.health) > 1 {
+			c.health = c.health[1:]
+		}
+	}
+	return c.json, nil
+}
+
+func (f *fakeDockerAPI) ContainerLogs(_ context.Context, name string, options container.LogsOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream bytes.Buffer
+	for _, entry := range c.logs {
+		if c.json.Config.Tty {
+			stream.WriteString(entry.stdout + entry.stderr)
+			continue
+		}
+		if entry.stdout != "" && options.ShowStdout {
+			_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte(entry.stdout))
+		}
+		if entry.stderr != "" && options.ShowStderr {
+			_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stderr).Write([]byte(entry.stderr))
+		}
+	}
+	return io.NopCloser(&stream), nil
+}
+
+func (f *fakeDockerAPI) CopyToContainer(_ context.Context, name, dstPath string, content io.Reader, _ container.CopyToContainerOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if c.files == nil {
+			c.files = make(map[string][]byte)
+		}
+		c.files[path.Join(dstPath, hdr.Name)] = data
+	}
+}
+
+func (f *fakeDockerAPI) CopyFromContainer(_ context.Context, name, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return nil, container.PathStat{}, err
+	}
+	data, ok := c.files[srcPath]
+	if !ok {
+		return nil, container.PathStat{}, errdefs.NotFound(fmt.Errorf("Could not find the file %s in container %s", srcPath, name))
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	_ = tw.WriteHeader(&tar.Header{Name: path.Base(srcPath), Mode: 0o644, Size: int64(len(data))})
+	_, _ = tw.Write(data)
+	_ = tw.Close()
+	return io.NopCloser(&archive), container.PathStat{Name: path.Base(srcPath), Size: int64(len(data))}, nil
+}
+
+// fakeCommit 是一次 ContainerCommit 的调用
+type fakeCommit struct {
+	container string
+	options   container.CommitOptions
+	// ballast 提交时 /ballast 的大小，-1 表示文件不存在
+	ballast int64
+}
+
+func (f *fakeDockerAPI) ContainerCommit(_ context.Context, name string, options container.CommitOptions) (types.IDResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return types.IDResponse{}, err
+	}
+	f.commits = append(f.commits, fakeCommit{container: name, options: options, ballast: c.ballast})
+	if f.commitErr != nil {
+		return types.IDResponse{}, f.commitErr
+	}
+	if options.Reference != "" {
+		f.images[options.Reference] = true
+	}
+	return types.IDResponse{ID: fmt.Sprintf("sha256:image%d", len(f.commits))}, nil
+}
+
+func (f *fakeDockerAPI) ContainerExport(_ context.Context, name string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	f.exports = append(f.exports, c.ballast)
+	return io.NopCloser(strings.NewReader("rootfs")), nil
+}
+
+func (f *fakeDockerAPI) ContainerStats(_ context.Context, name string, stream bool) (container.StatsResponseReader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return container.StatsResponseReader{}, err
+	}
+	stats := c.stats
+	if !stream && len(stats) > 1 {
+		stats = stats[:1]
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, s := range stats {
+		_ = encoder.Encode(s)
+	}
+	return container.StatsResponseReader{Body: io.NopCloser(&body), OSType: "linux"}, nil
+}
+
+func (f *fakeDockerAPI) ContainerList(_ context.Context, options container.ListOptions) ([]types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var list []types.Container
+	for _, c := range f.containers {
+		if !matchLabels(c.json.Config.Labels, options.Filters.Get("label")) {
+			continue
+		}
+		list = append(list, types.Container{
+			ID:     c.json.ID,
+			Names:  []string{c.json.Name},
+			State:  c.json.State.Status,
+			Labels: c.json.Config.Labels,
+		})
+	}
+	return list, nil
+}
+
+// matchLabels 模拟 Docker 的 label 过滤，支持 key 和 key=value 两种形式
+func matchLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		v, ok := labels[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeDockerAPI) ContainerExecCreate(_ context.Context, name string, options container.ExecOptions) (types.IDResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.lookup(name)
+	if err != nil {
+		return types.IDResponse{}, err
+	}
+	if !c.json.State.Running {
+		return types.IDResponse{}, errdefs.Conflict(fmt.Errorf("container %s is not running", name))
+	}
+	if c.json.State.Paused {
+		return types.IDResponse{}, errdefs.Conflict(fmt.Errorf("container %s is paused, unpause the container before exec", name))
+	}
+
+	f.commands = append(f.commands, options.Cmd)
+
+	result, ok := fakeExecResult{}, false
+	if f.execFn != nil {
+		result, ok = f.execFn(c, options.Cmd)
+	}
+	if !ok {
+		result = c.exec(options.Cmd)
+	}
+
+	result.tty = options.Tty
+	f.execOptions = append(f.execOptions, options)
+	f.nextID++
+	id := fmt.Sprintf("exec-%d", f.nextID)
+	f.execs[id] = result
+	return types.IDResponse{ID: id}, nil
+}
+
+func (f *fakeDockerAPI) ContainerExecAttach(_ context.Context, execID string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	result := f.execs[execID]
+	f.mu.Unlock()
+
+	var stream bytes.Buffer
+	if result.tty {
+		// TTY 模式下标准错误同样写入输出流，换行符被转换为 \r\n
+		stream.WriteString(strings.ReplaceAll(result.stdout+result.stderr, "\n", "\r\n"))
+	} else if result.stdout != "" {
+		_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte(result.stdout))
+	}
+	if !result.tty && result.stderr != "" {
+		_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stderr).Write([]byte(result.stderr))
+	}
+
+	conn, peer := net.Pipe()
+	_ = peer.Close()
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(&stream)}, nil
+}
+
+func (f *fakeDockerAPI) ContainerExecInspect(_ context.Context, execID string) (container.ExecInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return container.ExecInspect{ExecID: execID, ExitCode: f.execs[execID].exitCode}, nil
+}
+
+func (f *fakeDockerAPI) ImageInspectWithRaw(_ context.Context, ref string) (types.ImageInspect, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.images[ref] {
+		return types.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("No such image: %s", ref))
+	}
+	return types.ImageInspect{ID: "sha256:" + ref}, nil, nil
+}
+
+func (f *fakeDockerAPI) ImagePull(_ context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pulled = append(f.pulled, ref)
+	f.pullAuth = append(f.pullAuth, options.RegistryAuth)
+	if f.pullErr != nil {
+		return nil, f.pullErr
+	}
+	if f.pullHang {
+		r, _ := io.Pipe()
+		return r, nil
+	}
+	f.images[ref] = true
+	progress := `{"status":"Pulling from library/alpine","id":"latest"}
+{"status":"Downloading","progressDetail":{"current":1000000,"total":3000000},"id":"abc"}
+{"status":"Download complete","id":"abc"}
+{"status":"Digest: sha256:0123456789abcdef"}
+{"status":"Status: Downloaded newer image for ` + ref + `"}
+`
+	return io.NopCloser(strings.NewReader(progress)), nil
+}
+
+func (f *fakeDockerAPI) Info(_ context.Context) (system.Info, error) {
+	return f.info, nil
+}
+
+func (f *fakeDockerAPI) DiskUsage(_ context.Context, _ types.DiskUsageOptions) (types.DiskUsage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var du types.DiskUsage
+	for name, c := range f.containers {
+		du.Containers = append(du.Containers, &types.Container{
+			ID:     c.json.ID,
+			Names:  []string{"/" + name},
+			Labels: c.json.Config.Labels,
+			SizeRw: c.used(),
+		})
+	}
+	return du, nil
+}
+
+func (f *fakeDockerAPI) NetworkInspect(_ context.Context, networkID string, _ network.InspectOptions) (network.Inspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.networks[networkID] {
+		return network.Inspect{}, errdefs.NotFound(fmt.Errorf("network %s not found", networkID))
+	}
+	return network.Inspect{Name: networkID, ID: "net-" + networkID}, nil
+}
+
+func (f *fakeDockerAPI) NetworkCreate(_ context.Context, name string, _ network.CreateOptions) (network.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.networks[name] {
+		return network.CreateResponse{}, errdefs.Conflict(fmt.Errorf("network with name %s already exists", name))
+	}
+	f.networks[name] = true
+	return network.CreateResponse{ID: "net-" + name}, nil
+}
+
+func (f *fakeDockerAPI) NetworkConnect(_ context.Context, networkID, containerID string, _ *network.EndpointSettings) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.networks[networkID] {
+		return errdefs.NotFound(fmt.Errorf("network %s not found", networkID))
+	}
+	if _, err := f.lookup(containerID); err != nil {
+		return err
+	}
+	f.connects = append(f.connects, networkID+"/"+containerID)
+	return nil
+}
+
+func (f *fakeDockerAPI) ClientVersion() string {
+	if f.version == "" {
+		return "1.47"
+	}
+	return f.version
+}
+
+func (f *fakeDockerAPI) DaemonHost() string {
+	if f.host == "" {
+		return "tcp://fake:2375"
+	}
+	return f.host
+}
+
+func (f *fakeDockerAPI) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed++
+	return nil
+}
+
+// container 返回指定名称的容器
+func (f *fakeDockerAPI) container(name string) *fakeContainer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.containers[name]
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// healthConfig 根据 opts 生成容器的健康检查配置，没有设置 HealthCmd 时返回 nil，使用镜像中的配置
+func healthConfig(opts RunOptions) *container.HealthConfig {
+	if len(opts.HealthCmd) == 0 {
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:     append([]string{"CMD"}, opts.HealthCmd...),
+		Interval: opts.HealthInterval,
+		Timeout:  opts.HealthTimeout,
+		Retries:  opts.HealthRetries,
+	}
+}
+
+// validateHealthcheck 校验 opts 中的健康检查配置，不能为负数
+func validateHealthcheck(opts RunOptions) error {
+	if opts.HealthInterval < 0 || opts.HealthTimeout < 0 || opts.HealthRetries < 0 {
+		return fmt.Errorf("%w: invalid health check interval %s, timeout %s, retries %d, must not be negative", ErrInvalidArgument,
+			opts.HealthInterval, opts.HealthTimeout, opts.HealthRetries)
+	}
+	return nil
+}
+
+// WaitHealthy 阻塞直到容器的健康检查状态为 healthy
+//
+// 容器没有配置健康检查时返回 ErrNoHealthcheck，健康检查失败时返回 ErrUnhealthy，
+// 容器已经退出时返回 ErrContainerNotRunning，错误中包含最后一次健康检查的输出。
+func (dc *DockerContainer) WaitHealthy(ctx context.Context, name string) (err error) {
+	defer wrapOp("wait", name, &err)
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		containerInspect, err := dc.inspectContainer(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		state := containerInspect.State
+		if state == nil || state.Health == nil || state.Health.Status == types.NoHealthcheck {
+			return fmt.Errorf("failed to wait for container %s: %w", name, ErrNoHealthcheck)
+		}
+		if !state.Running {
+			return fmt.Errorf("failed to wait for container %s: %w, last health check: %s", name, ErrContainerNotRunning, lastHealthLog(state.Health))
+		}
+		switch state.Health.Status {
+		case types.Healthy:
+			return nil
+		case types.Unhealthy:
+			return fmt.Errorf("failed to wait for container %s: %w, last health check: %s", name, ErrUnhealthy, lastHealthLog(state.Health))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for container %s: %w, last health check: %s", name, ctx.Err(), lastHealthLog(state.Health))
+		case <-ticker.C:
+		}
+	}
+}
+
+// lastHealthLog 返回最后一次健康检查的退出码和输出
+func lastHealthLog(health *types.Health) string {
+	if len(health.Log) == 0 || health.Log[len(health.Log)-1] == nil {
+		return "none"
+	}
+	last := health.Log[len(health.Log)-1]
+	return fmt.Sprintf("exit code %d: %s", last.ExitCode, strings.TrimSpace(last.Output))
+}
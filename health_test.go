@@ -0,0 +1,78 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestRunHealthcheck(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	opts := RunOptions{
+		Name:           "test",
+		HealthCmd:      []string{"curl", "-f", "http://localhost/"},
+		HealthInterval: 5 * time.Second,
+		HealthTimeout:  time.Second,
+		HealthRetries:  2,
+	}
+	if _, err := dc.RunWithOptions(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	health := api.container("test").json.Config.Healthcheck
+	if health == nil || strings.Join(health.Test, " ") != "CMD curl -f http://localhost/" ||
+		health.Interval != 5*time.Second || health.Timeout != time.Second || health.Retries != 2 {
+		t.Fatalf("healthcheck = %+v", health)
+	}
+
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "invalid", HealthCmd: []string{"true"}, HealthRetries: -1}); err == nil {
+		t.Fatal("expected validation error")
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	for _, name := range []string{"healthy", "unhealthy", "none"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api.container("healthy").health = []*types.Health{
+		{Status: types.Starting},
+		{Status: types.Healthy},
+	}
+	api.container("unhealthy").health = []*types.Health{
+		{Status: types.Starting},
+		{Status: types.Unhealthy, Log: []*types.HealthcheckResult{{ExitCode: 1, Output: "connection refused\n"}}},
+	}
+
+	if err := dc.WaitHealthy(ctx, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	err := dc.WaitHealthy(ctx, "unhealthy")
+	if !errors.Is(err, ErrUnhealthy) || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("WaitHealthy() error = %v, want ErrUnhealthy with the last health log", err)
+	}
+	if err := dc.WaitHealthy(ctx, "none"); !errors.Is(err, ErrNoHealthcheck) {
+		t.Fatalf("WaitHealthy() error = %v, want ErrNoHealthcheck", err)
+	}
+	if err := dc.WaitHealthy(ctx, "missing"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("WaitHealthy() error = %v, want ErrContainerNotFound", err)
+	}
+
+	// 一直处于 starting 时等到 ctx 超时
+	api.container("healthy").health = []*types.Health{{Status: types.Starting}}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if err := dc.WaitHealthy(timeoutCtx, "healthy"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitHealthy() error = %v, want context.DeadlineExceeded", err)
+	}
+}
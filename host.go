@@ -0,0 +1,165 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// tlsFiles 连接 tcp:// 地址时使用的证书路径
+type tlsFiles struct {
+	caCert string
+	cert   string
+	key    string
+}
+
+// WithTLS 设置 NewDockerContainerWithHost 连接 tcp:// 地址时使用的 CA 证书、客户端证书和私钥路径，
+// 不设置时使用不加密的连接
+func WithTLS(caCertPath, certPath, keyPath string) Option {
+	return func(dc *DockerContainer) {
+		dc.tls = &tlsFiles{caCert: caCertPath, cert: certPath, key: keyPath}
+	}
+}
+
+// NewDockerContainerWithHost 创建一个连接到指定 Docker daemon 的 DockerContainer，
+// 用于在一个进程中管理多台主机上的容器
+//
+// 支持的地址格式如下：
+//   - unix:///var/run/docker.sock
+//   - tcp://host:2376，配合 WithTLS 使用 mTLS
+//   - ssh://user@host:22，要求本机可以免密登录，并且远端安装了 docker 命令行
+func NewDockerContainerWithHost(host string, opts ...Option) (Container, error) {
+	dc, err := newDockerContainer(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts, err := hostClientOpts(host, dc.tls)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.NewClientWithOpts(append(clientOpts, client.WithAPIVersionNegotiation())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client for %s: %w", host, err)
+	}
+	dc.setClient(cli)
+	return dc, nil
+}
+
+// hostClientOpts 根据地址的协议生成创建 Docker 客户端的参数
+func hostClientOpts(host string, tls *tlsFiles) ([]client.Opt, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnsupportedHost, host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		if tls != nil {
+			return nil, fmt.Errorf("%w: TLS is only supported for tcp:// hosts", ErrUnsupportedHost)
+		}
+		return []client.Opt{client.WithHost(host)}, nil
+	case "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("%w: missing address in %s", ErrUnsupportedHost, host)
+		}
+		opts := []client.Opt{client.WithHost(host)}
+		if tls != nil {
+			opts = append(opts, client.WithTLSClientConfig(tls.caCert, tls.cert, tls.key))
+		}
+		return opts, nil
+	case "ssh":
+		if tls != nil {
+			return nil, fmt.Errorf("%w: TLS is only supported for tcp:// hosts", ErrUnsupportedHost)
+		}
+		args, err := sshArgs(u)
+		if err != nil {
+			return nil, err
+		}
+		// 通过 ssh 执行远端的 docker system dial-stdio 转发请求，地址只用于生成请求，不会被真正连接
+		return []client.Opt{
+			client.WithHost("http://docker.example.com"),
+			client.WithDialContext(func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialCommand(ctx, "ssh", args...)
+			}),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHost, host)
+	}
+}
+
+// sshArgs 根据 ssh:// 地址生成 ssh 命令的参数
+func sshArgs(u *url.URL) ([]string, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: missing host in %s", ErrUnsupportedHost, u.Redacted())
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("%w: password is not supported in ssh host, use ssh keys instead", ErrUnsupportedHost)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("%w: path is not supported in ssh host %s", ErrUnsupportedHost, u.Redacted())
+	}
+
+	var args []string
+	if u.User != nil && u.User.Username() != "" {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// dialCommand 启动一个命令，并把它的标准输入输出当作连接使用
+func dialCommand(ctx context.Context, name string, args ...string) (net.Conn, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// commandConn 将命令的标准输入输出包装为 net.Conn，不支持超时设置
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *commandConn) Close() error {
+	_ = c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr               { return commandAddr{} }
+func (c *commandConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// commandAddr 是 commandConn 的地址
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
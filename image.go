@@ -0,0 +1,111 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// ensureImage 检查镜像是否存在，不存在时拉取镜像
+//
+// 关闭自动拉取后（WithAutoPull(false)），镜像不存在会直接返回错误，适用于离线环境。
+func (dc *DockerContainer) ensureImage(ctx context.Context, ref string) error {
+	_, _, err := dc.cli.ImageInspectWithRaw(ctx, ref)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+	if !dc.autoPull {
+		return fmt.Errorf("image %s not found locally and auto pull is disabled: %w", ref, err)
+	}
+	return dc.pullImage(ctx, ref, nil)
+}
+
+// PullImage 拉取镜像 ref，并将拉取进度逐行输出到 progress，progress 为 nil 时只输出到日志
+//
+// 每一行的格式为 "<layer>: <status> <current>/<total>"，拉取完成后最后一行为 "Digest: <digest>"。
+// ctx 被取消时会中断拉取并返回 ctx.Err()。使用 WithRegistryCredentials、WithCredentialHelper 或者 WithRegistryAuth
+// 设置私有仓库的认证信息，认证失败时返回 ErrRegistryAuth，镜像不存在时返回的错误满足 errdefs.IsNotFound。
+func (dc *DockerContainer) PullImage(ctx context.Context, ref string, progress io.Writer) (err error) {
+	defer wrapOp("pull", "", &err)
+	return dc.pullImage(ctx, ref, progress)
+}
+
+func (dc *DockerContainer) pullImage(ctx context.Context, ref string, progress io.Writer) error {
+	if dc.dryRunf("Would pull image %s", ref) {
+		return nil
+	}
+	auth, err := dc.registryAuthFor(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, err)
+	}
+	dc.logger.Infof("Pulling image %s", ref)
+	reader, err := dc.cli.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, wrapAuthError(err))
+	}
+	defer reader.Close()
+
+	// ImagePull 返回的 reader 在服务端没有输出时会一直阻塞，ctx 被取消时关闭 reader 中断读取
+	stop := context.AfterFunc(ctx, func() { reader.Close() })
+	defer stop()
+
+	digest, err := drainPullProgress(dc.logger, reader, ref, progress)
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, wrapAuthError(err))
+	}
+	dc.logger.Infof("Successfully pulled image %s with digest %s", ref, digest)
+	return nil
+}
+
+// drainPullProgress 读取 ImagePull 返回的进度信息并输出到日志和 progress，返回镜像的 digest，拉取失败时返回错误
+func drainPullProgress(logger Logger, r io.Reader, ref string, progress io.Writer) (digest string, err error) {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return digest, nil
+			}
+			return digest, err
+		}
+		if msg.Error != nil {
+			return digest, msg.Error
+		}
+		if d, ok := strings.CutPrefix(msg.Status, "Digest: "); ok {
+			digest = d
+		}
+
+		line := formatPullMessage(msg)
+		debugf(logger, "Pulling image %s: %s", ref, line)
+		if progress != nil {
+			if _, err := fmt.Fprintln(progress, line); err != nil {
+				return digest, fmt.Errorf("failed to write pull progress: %w", err)
+			}
+		}
+	}
+}
+
+// formatPullMessage 将一条拉取进度转换为一行可读的文本
+func formatPullMessage(msg jsonmessage.JSONMessage) string {
+	line := msg.Status
+	if msg.ID != "" {
+		line = msg.ID + ": " + line
+	}
+	if p := msg.Progress; p != nil && p.Total > 0 {
+		line += fmt.Sprintf(" %s/%s", storageSize(p.Current), storageSize(p.Total))
+	}
+	return line
+}
@@ -0,0 +1,88 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnsureImagePullsMissingImage(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	if err := dc.ensureImage(context.Background(), "alpine:latest"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.pulled) != 1 || api.pulled[0] != "alpine:latest" {
+		t.Fatalf("pulled = %v, want [alpine:latest]", api.pulled)
+	}
+
+	// 镜像已经存在时不再拉取
+	if err := dc.ensureImage(context.Background(), "alpine:latest"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.pulled) != 1 {
+		t.Fatalf("pulled = %v, want [alpine:latest]", api.pulled)
+	}
+}
+
+func TestEnsureImageWithoutAutoPull(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithAutoPull(false))
+
+	if err := dc.ensureImage(context.Background(), "alpine:latest"); err == nil {
+		t.Fatal("expected error when auto pull is disabled")
+	}
+	if len(api.pulled) != 0 {
+		t.Fatalf("pulled = %v, want none", api.pulled)
+	}
+}
+
+func TestDrainPullProgressError(t *testing.T) {
+	progress := `{"status":"Pulling from library/private"}
+{"errorDetail":{"message":"pull access denied"},"error":"pull access denied"}
+`
+	_, err := drainPullProgress(KlogLogger{}, strings.NewReader(progress), "private:latest", nil)
+	if err == nil || !strings.Contains(err.Error(), "pull access denied") {
+		t.Fatalf("expected pull error, got %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected context error")
+	}
+}
+
+func TestPullImage(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithRegistryAuth("c2VjcmV0"))
+
+	var progress strings.Builder
+	if err := dc.PullImage(context.Background(), "private:latest", &progress); err != nil {
+		t.Fatal(err)
+	}
+	want := `latest: Pulling from library/alpine
+abc: Downloading 1.0MB/3.0MB
+abc: Download complete
+Digest: sha256:0123456789abcdef
+Status: Downloaded newer image for private:latest
+`
+	if progress.String() != want {
+		t.Fatalf("progress = %q, want %q", progress.String(), want)
+	}
+	if len(api.pullAuth) != 1 || api.pullAuth[0] != "c2VjcmV0" {
+		t.Fatalf("registry auth = %v, want [c2VjcmV0]", api.pullAuth)
+	}
+}
+
+func TestPullImageCanceled(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.pullHang = true
+	dc := newTestContainer(api)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := dc.PullImage(ctx, "alpine:latest", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PullImage() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,113 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Info 汇总了容器的状态、系统盘限制和 /ballast 的情况
+type Info struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Limited 容器是否由本包限制了系统盘大小，为 false 时 ThresholdBytes 和 BallastBytes 为 0
+	Limited bool `json:"limited"`
+	// ThresholdBytes 系统盘的实际限制大小（包括 /ballast）
+	ThresholdBytes int64 `json:"threshold_bytes"`
+	// BallastBytes /ballast 的大小，分片时为所有分片的和，文件不存在时为 0
+	BallastBytes int64 `json:"ballast_bytes"`
+	// DiskUsedBytes 和 DiskFreeBytes 系统盘的已用空间和剩余空间，容器没有运行时为 0
+	DiskUsedBytes int64 `json:"disk_used_bytes"`
+	DiskFreeBytes int64 `json:"disk_free_bytes"`
+}
+
+// inspectSeparator 分隔 Inspect 执行的命令中 df 和 stat 的输出
+const inspectSeparator = "--"
+
+// Inspect 返回容器的状态、系统盘限制、/ballast 大小和磁盘使用情况
+//
+// 容器运行时只会在容器内执行一次命令，同时获取 df 和 stat 的结果；
+// 容器没有运行或者被暂停时只返回标签中的信息。
+func (dc *DockerContainer) Inspect(ctx context.Context, name string) (_ Info, err error) {
+	defer wrapOp("inspect", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		ID:   containerInspect.ID,
+		Name: strings.TrimPrefix(containerInspect.Name, "/"),
+	}
+	if containerInspect.State != nil {
+		info.State = containerInspect.State.Status
+	}
+	if v, ok := dc.labelValue(containerInspect.Config.Labels, labelThreshold); ok {
+		info.ThresholdBytes, err = parseHumanSize(v)
+		if err != nil {
+			return info, fmt.Errorf("invalid threshold label %q on container %s: %w", v, name, err)
+		}
+		info.Limited = true
+	}
+
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		return info, nil
+	}
+
+	// stat 在 /ballast 不存在时会失败，这里不关心退出码，只解析输出
+	labels := containerInspect.Config.Labels
+	cmd, err := dc.shellCommand(ctx, containerInspect.ID, dc.inspectCommand(dc.ballastPathOf(labels), dc.dfTarget(labels)))
+	if err != nil {
+		return info, fmt.Errorf("failed to inspect disk usage of container %s: %w", name, err)
+	}
+	stdout, _, _, err := dc.exec(ctx, containerInspect.ID, cmd, execOptions{user: dc.execUserOf(ctx, containerInspect.ID)})
+	if err != nil {
+		return info, fmt.Errorf("failed to inspect disk usage of container %s: %w", name, err)
+	}
+	usage, ballast, err := parseInspectOutput(stdout)
+	if err != nil {
+		return info, fmt.Errorf("failed to parse disk usage of container %s: %w", name, err)
+	}
+	info.DiskUsedBytes = usage.used
+	info.DiskFreeBytes = usage.available
+	if info.Limited {
+		info.BallastBytes = ballast
+		// 与 Stop 的计算方式保持一致，剩余空间按照 threshold 计算
+		info.DiskFreeBytes = info.ThresholdBytes - usage.used
+	}
+	return info, nil
+}
+
+// inspectCommand 返回同时获取 dfTarget 的 df 和 path 文件大小的命令
+func (dc *DockerContainer) inspectCommand(path, dfTarget string) string {
+	if dc.chunkSize > 0 {
+		path += ".*"
+	}
+	return fmt.Sprintf("df -P -B1 %s; echo %s; stat -c %%s %s 2>/dev/null", dfTarget, inspectSeparator, path)
+}
+
+// parseInspectOutput 解析 inspectCommand 的输出，返回 df 的结果和所有 /ballast 文件大小的和
+func parseInspectOutput(output string) (dfUsage, int64, error) {
+	dfOutput, statOutput, ok := strings.Cut(output, "\n"+inspectSeparator+"\n")
+	if !ok {
+		return dfUsage{}, 0, fmt.Errorf("unexpected output %q", output)
+	}
+
+	usage, err := parseDfOutput(dfOutput)
+	if err != nil {
+		return dfUsage{}, 0, err
+	}
+
+	var ballast int64
+	for _, line := range strings.Fields(statOutput) {
+		size, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return dfUsage{}, 0, fmt.Errorf("failed to parse ballast size %q: %w", line, err)
+		}
+		ballast += size
+	}
+	return usage, ballast, nil
+}
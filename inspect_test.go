@@ -0,0 +1,72 @@
+package container
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.dataUsed = 12 * gigabyte
+
+	commands := len(api.commands)
+	info, err := dc.Inspect(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api.commands)-commands != 1 {
+		t.Fatalf("Inspect should exec once, got %d", len(api.commands)-commands)
+	}
+	want := Info{
+		ID:             c.json.ID,
+		Name:           "test",
+		State:          "running",
+		Limited:        true,
+		ThresholdBytes: 25 * gigabyte,
+		BallastBytes:   5 * gigabyte,
+		DiskUsedBytes:  17 * gigabyte,
+		DiskFreeBytes:  8 * gigabyte,
+	}
+	if info != want {
+		t.Fatalf("Inspect = %+v, want %+v", info, want)
+	}
+
+	// /ballast 不存在时大小为 0
+	c.ballast = -1
+	if info, err = dc.Inspect(ctx, "test"); err != nil || info.BallastBytes != 0 {
+		t.Fatalf("Inspect without /ballast = %+v, %v", info, err)
+	}
+
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	info, err = dc.Inspect(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != "exited" || info.DiskUsedBytes != 0 || !info.Limited {
+		t.Fatalf("Inspect of stopped container = %+v", info)
+	}
+}
+
+func TestInspectChunked(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithBallastChunkSize(2*gigabyte))
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := dc.Inspect(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BallastBytes != 5*gigabyte {
+		t.Fatalf("BallastBytes = %d, want %d", info.BallastBytes, int64(5*gigabyte))
+	}
+}
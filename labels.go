@@ -0,0 +1,165 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// labelKey 返回带命名空间的标签名称
+func (dc *DockerContainer) labelKey(name string) string {
+	return dc.labelPrefix + name
+}
+
+// labelValue 读取标签的值，带命名空间的标签不存在时读取旧版本创建的容器中没有命名空间的标签
+func (dc *DockerContainer) labelValue(labels map[string]string, name string) (string, bool) {
+	if v, ok := labels[dc.labelKey(name)]; ok {
+		return v, true
+	}
+	v, ok := labels[legacyLabel(name)]
+	return v, ok
+}
+
+// legacyLabel 返回旧版本创建的容器中没有命名空间的标签名称
+func legacyLabel(name string) string {
+	if name == labelBallastPath {
+		return legacyLabelBallastPath
+	}
+	return name
+}
+
+// managedLabels 是本包写入和读取的所有标签
+var managedLabels = []string{labelThreshold, labelBallast, labelBaseStorage, labelBallastPath, labelExecUser, labelMount}
+
+// validateLabels 检查 RunOptions.Labels 中没有本包保留的标签
+//
+// 保留的标签包括 WithLabelPrefix 命名空间下的所有标签，以及 labelValue 会兼容读取的没有命名空间的旧标签，
+// 否则用户的标签可能覆盖 threshold 等标签，或者让没有 /ballast 的容器被当作受限容器。
+func (dc *DockerContainer) validateLabels(labels map[string]string) error {
+	for key := range labels {
+		reserved := dc.labelPrefix != "" && strings.HasPrefix(key, dc.labelPrefix)
+		for _, name := range managedLabels {
+			reserved = reserved || key == dc.labelKey(name) || key == legacyLabel(name)
+		}
+		if reserved {
+			return fmt.Errorf("%w: invalid label %q, it is reserved for ballast", ErrInvalidArgument, key)
+		}
+	}
+	return nil
+}
+
+// GetLabel 返回容器 name 的标签 key 的值，标签不存在时第二个返回值为 false
+//
+// key 为 threshold、ballast、base_storage、path、exec_user 或者 mount 时读取本包管理的标签，
+// 与 labelValue 一样优先使用 WithLabelPrefix 命名空间下的标签，并兼容旧版本没有命名空间的标签；
+// 其他的 key 按照原样读取，例如通过 RunOptions.Labels 设置的标签。
+func (dc *DockerContainer) GetLabel(ctx context.Context, name, key string) (_ string, _ bool, err error) {
+	defer wrapOp("get_label", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return "", false, err
+	}
+	labels := containerInspect.Config.Labels
+	for _, managed := range managedLabels {
+		if key == managed {
+			v, ok := dc.labelValue(labels, key)
+			return v, ok, nil
+		}
+	}
+	v, ok := labels[key]
+	return v, ok, nil
+}
+
+// SetLabel 将容器 name 的标签 key 设置为 value，key 的含义与 GetLabel 相同
+//
+// Docker 不支持修改已经创建的容器的标签，只能重新创建容器，而重新创建会丢失容器可写层中的数据，
+// 因此 SetLabel 不会重新创建容器：标签已经是 value 时直接返回 nil，否则返回 ErrImmutable。
+// 套餐变化需要调整系统盘大小时使用 SetQuota，它不依赖修改 threshold 标签。
+func (dc *DockerContainer) SetLabel(ctx context.Context, name, key, value string) (err error) {
+	defer wrapOp("set_label", name, &err)
+
+	current, ok, err := dc.GetLabel(ctx, name, key)
+	if err != nil {
+		return err
+	}
+	if ok && current == value {
+		return nil
+	}
+	return fmt.Errorf("failed to set label %s of container %s to %q: %w, the container must be recreated", key, name, value, ErrImmutable)
+}
+
+// containerLabels 返回容器 containerID 的标签，容器的标签在创建后不会改变，读取一次后就会被缓存
+//
+// Run 和 inspectContainer 会顺便写入缓存，通常不需要额外 inspect 容器；inspect 失败时返回 nil，不会缓存。
+func (dc *DockerContainer) containerLabels(ctx context.Context, containerID string) map[string]string {
+	if entry, ok := dc.labelCache.Load(containerID); ok {
+		return entry.(cachedLabels).labels
+	}
+	containerInspect, err := dc.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil
+	}
+	return dc.rememberLabels(containerInspect.ID, containerInspect.Name, containerInspect.Config)
+}
+
+// containerName 返回容器 containerID 的名称，labelCache 中没有该容器时返回 containerID，不会 inspect 容器
+func (dc *DockerContainer) containerName(containerID string) string {
+	if entry, ok := dc.labelCache.Load(containerID); ok {
+		return entry.(cachedLabels).name
+	}
+	return containerID
+}
+
+// containerID 返回名称为 name 的容器的 ID，labelCache 中没有该容器时只在设置了 WithQuotaDir 时 inspect 容器，
+// 找不到时返回空字符串
+func (dc *DockerContainer) containerID(ctx context.Context, name string) string {
+	name = strings.TrimPrefix(name, "/")
+	var id string
+	dc.labelCache.Range(func(key, value any) bool {
+		if key == name || value.(cachedLabels).name == name {
+			id = key.(string)
+			return false
+		}
+		return true
+	})
+	if id != "" || dc.quotaDir == "" {
+		return id
+	}
+	containerInspect, err := dc.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return ""
+	}
+	return containerInspect.ID
+}
+
+// cachedLabels 是 labelCache 中缓存的容器名称和标签，名称用于在删除容器时清理缓存
+type cachedLabels struct {
+	name   string
+	labels map[string]string
+}
+
+// rememberLabels 缓存并返回容器 containerID 的标签
+func (dc *DockerContainer) rememberLabels(containerID, name string, config *container.Config) map[string]string {
+	var labels map[string]string
+	if config != nil {
+		labels = config.Labels
+	}
+	dc.labelCache.Store(containerID, cachedLabels{name: strings.TrimPrefix(name, "/"), labels: labels})
+	return labels
+}
+
+// forgetLabels 删除名称或者 ID 为 nameOrID 的容器的缓存（包括检查到的 shell），容器被删除后调用
+func (dc *DockerContainer) forgetLabels(nameOrID string) {
+	nameOrID = strings.TrimPrefix(nameOrID, "/")
+	dc.shellCache.Delete(nameOrID)
+	dc.labelCache.Range(func(key, value any) bool {
+		if key == nameOrID || value.(cachedLabels).name == nameOrID {
+			dc.labelCache.Delete(key)
+			dc.shellCache.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,43 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetSetLabel(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", Labels: map[string]string{"tenant": "a"}}); err != nil {
+		t.Fatalf("RunWithOptions() error = %v", err)
+	}
+
+	// 本包管理的标签按照名称读取，不需要带命名空间
+	threshold, ok, err := dc.GetLabel(ctx, "test", labelThreshold)
+	if err != nil || !ok || threshold != api.container("test").json.Config.Labels[defaultLabelPrefix+labelThreshold] {
+		t.Fatalf("GetLabel(threshold) = %q, %v, %v", threshold, ok, err)
+	}
+	if v, ok, err := dc.GetLabel(ctx, "test", "tenant"); err != nil || !ok || v != "a" {
+		t.Fatalf("GetLabel(tenant) = %q, %v, %v", v, ok, err)
+	}
+	if _, ok, err := dc.GetLabel(ctx, "test", "missing"); err != nil || ok {
+		t.Fatalf("GetLabel(missing) = %v, %v, want not found", ok, err)
+	}
+	if _, _, err := dc.GetLabel(ctx, "other", "tenant"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("GetLabel() on a missing container error = %v, want ErrContainerNotFound", err)
+	}
+
+	// 标签不能修改，值相同时不报错
+	if err := dc.SetLabel(ctx, "test", "tenant", "a"); err != nil {
+		t.Fatalf("SetLabel() with the same value error = %v", err)
+	}
+	err = dc.SetLabel(ctx, "test", labelThreshold, "1")
+	if !errors.Is(err, ErrImmutable) || KindOf(err) != KindConflict {
+		t.Fatalf("SetLabel(threshold) error = %v, want ErrImmutable", err)
+	}
+	if err := dc.SetLabel(ctx, "test", "tenant", "b"); !errors.Is(err, ErrImmutable) {
+		t.Fatalf("SetLabel(tenant) error = %v, want ErrImmutable", err)
+	}
+}
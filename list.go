@@ -0,0 +1,98 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ContainerInfo 描述一个由本包管理的容器
+type ContainerInfo struct {
+	Name  string
+	ID    string
+	State string
+	// Threshold 容器系统盘的实际限制大小，单位为字节
+	Threshold int64
+}
+
+// List 列出所有带有 threshold 标签的容器，包括已经停止的容器和旧版本创建的没有命名空间的容器
+//
+// threshold 标签无法解析的容器会被跳过并记录日志。
+//
+// filters 中的每一项都会作为 label 过滤条件交给 Docker，只返回同时带有所有这些标签的容器，
+// 值为空时只要求存在该标签，为 nil 时返回所有容器。
+func (dc *DockerContainer) List(ctx context.Context, filters map[string]string) (_ []ContainerInfo, err error) {
+	defer wrapOp("list", "", &err)
+
+	// Docker 的多个 label 过滤条件之间是与的关系，旧版本创建的容器需要单独查询
+	keys := []string{dc.labelKey(labelThreshold)}
+	if keys[0] != labelThreshold {
+		keys = append(keys, labelThreshold)
+	}
+	var containers []types.Container
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		args := labelFilters(filters)
+		args.Add("label", key)
+		list, err := dc.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, c := range list {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				containers = append(containers, c)
+			}
+		}
+	}
+
+	infos := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		info, err := dc.toContainerInfo(c)
+		if err != nil {
+			// 没有命名空间的 threshold 标签可能由其他工具设置，跳过这个容器，不影响其他容器的管理
+			dc.logger.Errorf("Failed to list container %s: %v", c.ID, err)
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// labelFilters 将 List 的 filters 转换为 Docker 的 label 过滤条件
+func labelFilters(labels map[string]string) filters.Args {
+	args := filters.NewArgs()
+	for key, value := range labels {
+		if value == "" {
+			args.Add("label", key)
+		} else {
+			args.Add("label", key+"="+value)
+		}
+	}
+	return args
+}
+
+// toContainerInfo 将 ContainerList 返回的容器转换为 ContainerInfo
+func (dc *DockerContainer) toContainerInfo(c types.Container) (ContainerInfo, error) {
+	var name string
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	v, _ := dc.labelValue(c.Labels, labelThreshold)
+	threshold, err := parseHumanSize(v)
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("invalid threshold label %q on container %s: %w", v, name, err)
+	}
+
+	return ContainerInfo{
+		Name:      name,
+		ID:        c.ID,
+		State:     c.State,
+		Threshold: threshold,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package container
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestToContainerInfo(t *testing.T) {
+	dc := newTestContainer(newFakeDockerAPI())
+	for _, labels := range []map[string]string{
+		{"io.ballast/threshold": "25000000000"},
+		// 旧版本创建的容器没有命名空间
+		{"threshold": "25000000000"},
+	} {
+		info, err := dc.toContainerInfo(types.Container{
+			ID:     "abc",
+			Names:  []string{"/test"},
+			State:  "running",
+			Labels: labels,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := ContainerInfo{Name: "test", ID: "abc", State: "running", Threshold: 25000000000}
+		if info != want {
+			t.Fatalf("toContainerInfo(%v) = %+v, want %+v", labels, info, want)
+		}
+	}
+}
+
+func TestListSkipsMalformedThreshold(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	if _, err := dc.Run("valid"); err != nil {
+		t.Fatal(err)
+	}
+	// 其他工具创建的容器也可能带有没有命名空间的 threshold 标签
+	foreign := map[string]string{"threshold": "lots"}
+	if _, err := api.ContainerCreate(ctx, &container.Config{Image: defaultImage, Labels: foreign}, &container.HostConfig{}, &network.NetworkingConfig{}, &ocispec.Platform{}, "foreign"); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := dc.List(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "valid" {
+		t.Fatalf("List() = %+v, want only the valid container", infos)
+	}
+}
+
+func TestLegacyLabels(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	if _, err := dc.Run("new"); err != nil {
+		t.Fatal(err)
+	}
+	labels := api.container("new").json.Config.Labels
+	for _, key := range []string{"io.ballast/threshold", "io.ballast/ballast", "io.ballast/base_storage", "io.ballast/path"} {
+		if _, ok := labels[key]; !ok {
+			t.Errorf("label %s is missing, labels = %v", key, labels)
+		}
+	}
+	if _, ok := labels["threshold"]; ok {
+		t.Error("bare threshold label should not be written")
+	}
+
+	// 模拟旧版本创建的容器
+	legacy := map[string]string{"threshold": "25000000000", "ballast": "5000000000", "ballast_path": "/data/ballast"}
+	resp, err := api.ContainerCreate(ctx, &container.Config{Image: defaultImage, Labels: legacy}, &container.HostConfig{}, &network.NetworkingConfig{}, &ocispec.Platform{}, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	limit, limited, err := dc.hasStorageLimit("old")
+	if err != nil || !limited || limit != 25*gigabyte {
+		t.Fatalf("hasStorageLimit(old) = %d, %v, %v", limit, limited, err)
+	}
+	if path := dc.ballastPathOf(legacy); path != "/data/ballast" {
+		t.Fatalf("ballastPathOf(old) = %s, want /data/ballast", path)
+	}
+
+	infos, err := dc.List(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("List() = %+v, want both containers", infos)
+	}
+
+	// 不使用命名空间时与旧版本的标签一致
+	bare := newTestContainer(api, WithLabelPrefix(""))
+	if infos, err := bare.List(ctx, nil); err != nil || len(infos) != 1 || infos[0].Name != "old" {
+		t.Fatalf("List() without prefix = %+v, %v", infos, err)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	for name, tenant := range map[string]string{"app-a": "tenant-a", "app-b": "tenant-b"} {
+		if _, err := dc.RunWithOptions(ctx, RunOptions{Name: name, Labels: map[string]string{"tenant": tenant, "team": "infra"}}); err != nil {
+			t.Fatalf("RunWithOptions(%s) error = %v", name, err)
+		}
+	}
+
+	// 用户的标签与 ballast 的标签一起写入
+	labels := api.container("app-a").json.Config.Labels
+	if labels["tenant"] != "tenant-a" || labels[defaultLabelPrefix+labelThreshold] == "" {
+		t.Fatalf("labels = %v, want both user and ballast labels", labels)
+	}
+
+	infos, err := dc.List(ctx, map[string]string{"tenant": "tenant-b"})
+	if err != nil || len(infos) != 1 || infos[0].Name != "app-b" {
+		t.Fatalf("List(tenant=tenant-b) = %+v, %v, want only app-b", infos, err)
+	}
+	if infos, err := dc.List(ctx, map[string]string{"team": "infra", "tenant": ""}); err != nil || len(infos) != 2 {
+		t.Fatalf("List(team=infra, tenant) = %+v, %v, want both containers", infos, err)
+	}
+	if infos, err := dc.List(ctx, map[string]string{"tenant": "tenant-c"}); err != nil || len(infos) != 0 {
+		t.Fatalf("List(tenant=tenant-c) = %+v, %v, want none", infos, err)
+	}
+
+	// 不能覆盖保留的标签
+	for _, key := range []string{defaultLabelPrefix + labelThreshold, defaultLabelPrefix + "custom", labelThreshold, legacyLabelBallastPath} {
+		_, err := dc.RunWithOptions(ctx, RunOptions{Name: "reserved", Labels: map[string]string{key: "1"}})
+		if err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Fatalf("RunWithOptions() with label %s error = %v, want reserved label error", key, err)
+		}
+	}
+	if api.container("reserved") != nil {
+		t.Fatal("container with reserved labels should not be created")
+	}
+}
@@ -0,0 +1,39 @@
+package container
+
+import "sync"
+
+// nameLock 是一个容器名称对应的锁，refs 为持有或者正在等待该锁的调用方数量
+type nameLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock 获取容器名称 name 对应的锁，返回解锁的函数
+//
+// 锁只按照调用方传入的名称区分，使用容器 ID 和名称同时操作同一个容器时不会互斥。
+// 没有调用方持有或者等待时锁会被删除，长期运行、管理大量容器的实例不会一直保留已经删除的容器的锁。
+func (dc *DockerContainer) lock(name string) func() {
+	dc.locksMu.Lock()
+	if dc.locks == nil {
+		dc.locks = make(map[string]*nameLock)
+	}
+	l, ok := dc.locks[name]
+	if !ok {
+		l = &nameLock{}
+		dc.locks[name] = l
+	}
+	l.refs++
+	dc.locksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		dc.locksMu.Lock()
+		defer dc.locksMu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(dc.locks, name)
+		}
+	}
+}
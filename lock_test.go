@@ -0,0 +1,119 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	dc := newTestContainer(newFakeDockerAPI())
+
+	unlock := dc.lock("test")
+	acquired := make(chan struct{})
+	go func() {
+		defer dc.lock("test")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock should block while it is held")
+	default:
+	}
+	// 其他名称不受影响
+	dc.lock("other")()
+	unlock()
+	<-acquired
+
+	dc.locksMu.Lock()
+	defer dc.locksMu.Unlock()
+	if len(dc.locks) != 0 {
+		t.Fatalf("unused locks should be deleted, got %v", dc.locks)
+	}
+}
+
+func TestConcurrentRun(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("test-%d", i)
+			if _, err := dc.RunWithOptions(ctx, RunOptions{Name: name}); err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := dc.StopWithResult(ctx, name); err != nil {
+				t.Error(err)
+			}
+			if err := dc.Remove(name); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 同名的 Run 串行执行，只有一个能创建成功
+	var created, conflicts atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := dc.Run("same")
+			switch {
+			case err == nil:
+				created.Add(1)
+			case errors.Is(err, ErrNameConflict):
+				conflicts.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created.Load() != 1 || conflicts.Load() != 7 {
+		t.Fatalf("created %d, conflicts %d, want 1 and 7", created.Load(), conflicts.Load())
+	}
+
+	// 删除的容器不再保留缓存的标签
+	if err := dc.ForceRemove("same"); err != nil {
+		t.Fatal(err)
+	}
+	dc.labelCache.Range(func(key, _ any) bool {
+		t.Errorf("labels of container %v should be forgotten", key)
+		return true
+	})
+}
+
+// discardLogger 丢弃所有日志，避免日志输出影响 benchmark 的结果
+type discardLogger struct{}
+
+func (discardLogger) Infof(string, ...interface{})  {}
+func (discardLogger) Errorf(string, ...interface{}) {}
+
+// BenchmarkRunConcurrent 在同一个 DockerContainer 上并发创建容器
+func BenchmarkRunConcurrent(b *testing.B) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithLogger(discardLogger{}))
+	ctx := context.Background()
+
+	var n atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			name := fmt.Sprintf("bench-%d", n.Add(1))
+			if _, err := dc.RunWithOptions(ctx, RunOptions{Name: name}); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
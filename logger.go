@@ -0,0 +1,69 @@
+package container
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+)
+
+// Logger 是 DockerContainer 输出日志使用的接口，可以通过 WithLogger 接入 zap、slog 等日志库
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// DebugLogger 是可选的接口，Logger 同时实现了该接口时会输出调试日志，例如镜像的拉取进度和容器内执行的每一条命令
+//
+// 同时实现了 DebugEnabled 时，只有返回 true 才会记录调试日志需要的命令输出，避免正常运行时的额外开销。
+type DebugLogger interface {
+	Debugf(format string, args ...interface{})
+}
+
+// KlogLogger 使用 k8s.io/klog 输出日志，是默认的 Logger，调试日志对应 klog 的 -v=2
+type KlogLogger struct{}
+
+func (KlogLogger) Infof(format string, args ...interface{}) {
+	klog.InfoDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (KlogLogger) Errorf(format string, args ...interface{}) {
+	klog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (KlogLogger) Debugf(format string, args ...interface{}) {
+	if klog.V(2) {
+		klog.InfoDepth(1, fmt.Sprintf(format, args...))
+	}
+}
+
+// DebugEnabled 返回是否开启了 klog 的 -v=2
+func (KlogLogger) DebugEnabled() bool {
+	return bool(klog.V(2))
+}
+
+// WithLogger 设置输出日志使用的 Logger，默认为 KlogLogger
+func WithLogger(logger Logger) Option {
+	return func(dc *DockerContainer) {
+		if logger != nil {
+			dc.logger = logger
+		}
+	}
+}
+
+// debugf 在 Logger 实现了 DebugLogger 时输出调试日志
+func debugf(logger Logger, format string, args ...interface{}) {
+	if l, ok := logger.(DebugLogger); ok {
+		l.Debugf(format, args...)
+	}
+}
+
+// debugEnabled 判断 logger 是否会输出调试日志，实现了 DebugLogger 但没有实现 DebugEnabled 时总是返回 true
+func debugEnabled(logger Logger) bool {
+	if _, ok := logger.(DebugLogger); !ok {
+		return false
+	}
+	if l, ok := logger.(interface{ DebugEnabled() bool }); ok {
+		return l.DebugEnabled()
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordLogger 记录所有输出的日志
+type recordLogger struct {
+	mu    sync.Mutex
+	infos []string
+	errs  []string
+}
+
+func (l *recordLogger) Infof(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Errorf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range append(l.infos, l.errs...) {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := &recordLogger{}
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithLogger(logger))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	api.container("test").dataUsed = int64(defaultStorageSize) - 800*megabyte
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Successfully ran container test", "Reduced /ballast size to 4700000000 bytes", "Successfully stopped container test"} {
+		if !logger.contains(want) {
+			t.Errorf("expected log %q, got %q", want, logger.infos)
+		}
+	}
+}
+
+// debugLogger 在 recordLogger 的基础上记录调试日志，enabled 为 false 时 DebugEnabled 返回 false
+type debugLogger struct {
+	recordLogger
+	enabled bool
+	debugs  []string
+}
+
+func (l *debugLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *debugLogger) DebugEnabled() bool {
+	return l.enabled
+}
+
+func TestDebugExecLog(t *testing.T) {
+	api := newFakeDockerAPI()
+	logger := &debugLogger{enabled: true}
+	dc := newTestContainer(api, WithLogger(logger))
+	id, err := dc.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.debugs = nil
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		return fakeExecResult{stdout: strings.Repeat("x", 600), stderr: "warning\n", exitCode: 3}, true
+	}
+	if _, _, _, err := dc.Exec(context.Background(), "test", []string{"cat", "/data/big file"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.debugs) != 1 {
+		t.Fatalf("debug logs = %q, want one line for the exec", logger.debugs)
+	}
+	line := logger.debugs[0]
+	for _, want := range []string{`["cat" "/data/big file"]`, "in container " + id + " ", "exited with code 3", `stderr: "warning\n"`, strings.Repeat("x", maxDebugOutput) + "...(88 more bytes)"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("debug log %q does not contain %q", line, want)
+		}
+	}
+
+	// 没有开启调试日志时不记录
+	logger.enabled = false
+	logger.debugs = nil
+	if _, _, _, err := dc.Exec(context.Background(), "test", []string{"true"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.debugs) != 0 {
+		t.Fatalf("debug logs = %q, want none", logger.debugs)
+	}
+}
@@ -0,0 +1,58 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Logs 返回容器的标准输出和标准错误，follow 为 true 时会持续输出新的日志，直到 ctx 被取消或者调用方关闭 reader
+//
+// 使用 TTY 创建的容器（Run 默认开启 TTY）日志是原始字节流；没有 TTY 的容器日志是多路复用的，
+// 这里会使用 stdcopy 拆分后按顺序合并为一个流，调用方不需要关心两者的区别。
+func (dc *DockerContainer) Logs(ctx context.Context, name string, follow bool) (_ io.ReadCloser, err error) {
+	defer wrapOp("logs", name, &err)
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := dc.cli.ContainerLogs(ctx, containerInspect.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs of container %s: %w", name, wrapNotFound(err))
+	}
+
+	if containerInspect.Config != nil && containerInspect.Config.Tty {
+		return rc, nil
+	}
+	return demuxReader(rc), nil
+}
+
+// demuxedReader 是 demuxReader 返回的 reader，关闭时会同时关闭底层的日志流
+type demuxedReader struct {
+	*io.PipeReader
+	src io.Closer
+}
+
+func (r *demuxedReader) Close() error {
+	_ = r.PipeReader.Close()
+	return r.src.Close()
+}
+
+// demuxReader 将多路复用的日志流拆分后合并为一个普通的字节流
+func demuxReader(rc io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, rc)
+		_ = pw.CloseWithError(err)
+	}()
+	return &demuxedReader{PipeReader: pr, src: rc}
+}
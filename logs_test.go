@@ -0,0 +1,34 @@
+package container
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestLogs(t *testing.T) {
+	for _, tty := range []bool{true, false} {
+		api := newFakeDockerAPI()
+		dc := newTestContainer(api)
+
+		if _, err := dc.Run("test"); err != nil {
+			t.Fatal(err)
+		}
+		c := api.container("test")
+		c.json.Config.Tty = tty
+		c.logs = []fakeExecResult{{stdout: "hello\n"}, {stderr: "oops\n"}, {stdout: "bye\n"}}
+
+		rc, err := dc.Logs(context.Background(), "test", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != "hello\noops\nbye\n" {
+			t.Fatalf("tty=%v: logs = %q", tty, output)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BallastBackend 表示 /ballast 文件创建之后的使用方式
+type BallastBackend string
+
+const (
+	// BackendFile 只创建普通文件，默认的方式
+	BackendFile BallastBackend = "file"
+	// BackendLoop 创建文件后使用 losetup 将其挂载为 loop 块设备，调整大小时会先卸载再重新挂载
+	BackendLoop BallastBackend = "loop"
+)
+
+// WithBallastBackend 设置 /ballast 文件的使用方式，默认为 BackendFile
+//
+// BackendLoop 需要容器内有 losetup，并且容器能够创建 loop 设备：通常需要使用 RunOptions.Privileged 运行容器，
+// 或者至少具有 CAP_SYS_ADMIN 并且可以访问 /dev/loop-control 和 /dev/loopN。
+// 已经创建的容器不能修改该选项。
+func WithBallastBackend(backend BallastBackend) Option {
+	return func(dc *DockerContainer) {
+		dc.backend = backend
+	}
+}
+
+// attachLoop 将 path 挂载为 loop 设备，path 已经挂载时先卸载，保证 loop 设备的大小与文件一致
+func (dc *DockerContainer) attachLoop(ctx context.Context, containerID, path string) error {
+	if dc.backend != BackendLoop {
+		return nil
+	}
+	if err := dc.detachLoop(ctx, containerID, path); err != nil {
+		return err
+	}
+	if _, err := dc.executeCommand(ctx, containerID, []string{"losetup", "-f", path}); err != nil {
+		return fmt.Errorf("failed to attach loop device for %s: %w", path, err)
+	}
+	return nil
+}
+
+// detachLoop 卸载 path 对应的所有 loop 设备，删除仍然挂载为 loop 设备的文件不会释放空间
+func (dc *DockerContainer) detachLoop(ctx context.Context, containerID, path string) error {
+	if dc.backend != BackendLoop {
+		return nil
+	}
+	output, err := dc.executeCommand(ctx, containerID, []string{"losetup", "-j", path})
+	if err != nil {
+		return fmt.Errorf("failed to find loop devices for %s: %w", path, err)
+	}
+	for _, dev := range parseLosetupOutput(output) {
+		if _, err := dc.executeCommand(ctx, containerID, []string{"losetup", "-d", dev}); err != nil {
+			return fmt.Errorf("failed to detach loop device %s for %s: %w", dev, path, err)
+		}
+	}
+	return nil
+}
+
+// parseLosetupOutput 解析 losetup -j 的输出，返回 loop 设备的路径，
+// 每一行的格式为 /dev/loop0: [2049]:1234 (/ballast)
+func parseLosetupOutput(output string) []string {
+	var devs []string
+	for _, line := range strings.Split(output, "\n") {
+		dev, _, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && strings.HasPrefix(dev, "/dev/") {
+			devs = append(devs, dev)
+		}
+	}
+	return devs
+}
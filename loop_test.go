@@ -0,0 +1,61 @@
+package container
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseLosetupOutput(t *testing.T) {
+	output := "/dev/loop0: [2049]:1234 (/ballast)\n/dev/loop3: [2049]:1234 (/ballast)\n\n"
+	if got, want := parseLosetupOutput(output), []string{"/dev/loop0", "/dev/loop3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseLosetupOutput() = %v, want %v", got, want)
+	}
+	if got := parseLosetupOutput(""); got != nil {
+		t.Fatalf("parseLosetupOutput(\"\") = %v, want nil", got)
+	}
+}
+
+func TestLoopBackend(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithBallastBackend(BackendLoop))
+	ctx := context.Background()
+
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", Privileged: true}); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	if !c.hostConfig.Privileged {
+		t.Fatal("container should be privileged")
+	}
+	if len(c.loops) != 1 || c.loops["/dev/loop0"].path != ballastPath || c.loops["/dev/loop0"].size != int64(ballastSize) {
+		t.Fatalf("loops after Run = %+v", c.loops)
+	}
+
+	// 删除 /ballast 前会先卸载 loop 设备，空间被真正释放，缩小后的文件重新挂载
+	c.dataUsed = int64(defaultStorageSize) - 800*megabyte
+	result, err := dc.StopWithResult(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReducedBytes != 300*megabyte {
+		t.Fatalf("StopWithResult = %+v", result)
+	}
+	if free := c.limit() - c.used(); free <= defaultTargetFree {
+		t.Fatalf("free space after Stop = %d, want more than %d", free, defaultTargetFree)
+	}
+	if len(c.loops) != 1 {
+		t.Fatalf("loops after Stop = %+v, want 1", c.loops)
+	}
+	for _, loop := range c.loops {
+		if loop.deleted || loop.size != 4700*megabyte {
+			t.Fatalf("loop after Stop = %+v", loop)
+		}
+	}
+}
+
+func TestInvalidBallastBackend(t *testing.T) {
+	if _, err := NewWithClient(newFakeDockerAPI(), WithBallastBackend("block")); err == nil {
+		t.Fatal("expected an error for an invalid backend")
+	}
+}
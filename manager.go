@@ -0,0 +1,243 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultManagerInterval = time.Minute
+
+// ManagedStatus 记录 Manager 最后一次处理某个容器的结果
+type ManagedStatus struct {
+	Name string
+	// State 处理时容器的状态，例如 running、exited
+	State string
+	// LastRun 最后一次处理的时间
+	LastRun time.Time
+	// ReducedBytes 最后一次处理时 /ballast 减少的字节数
+	ReducedBytes int64
+	// Reconciled 最后一次处理时是否将 /ballast 恢复到了 ballast 标签记录的大小
+	Reconciled bool
+	// Err 最后一次处理失败的原因
+	Err error
+}
+
+// ManagerOption 用于定制 Manager 的行为
+type ManagerOption func(m *Manager)
+
+// WithManagerInterval 设置 Manager 两次处理之间的间隔，默认为 1 分钟，必须为正数
+func WithManagerInterval(interval time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if interval > 0 {
+			m.interval = interval
+		}
+	}
+}
+
+// WithManagerReconcile 开启后，停止过的容器再次运行时（例如直接通过 docker start 启动，没有经过 Start），
+// Manager 会调用一次 Reconcile 将 /ballast 恢复到 ballast 标签记录的大小，默认关闭
+//
+// 停止的容器无法执行命令，只能等到容器重新运行后再恢复。
+func WithManagerReconcile(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.reconcile = enabled
+	}
+}
+
+// WithManagerLogger 设置 Manager 输出日志使用的 Logger，默认为 KlogLogger
+func WithManagerLogger(logger Logger) ManagerOption {
+	return func(m *Manager) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+// Manager 是一个长期运行的控制循环，每隔一段时间通过 List 枚举所有被管理的容器，
+// 对运行中的容器调用 Adjust 在剩余空间不足时减小 /ballast
+//
+// 每一轮处理在同一个 goroutine 中串行执行，上一轮没有结束时不会开始新的一轮；
+// 同一轮中重复出现的容器只会处理一次，处理过程中被删除的容器会从 Status 中移除。
+type Manager struct {
+	c         Container
+	interval  time.Duration
+	reconcile bool
+	logger    Logger
+
+	mu     sync.Mutex
+	status map[string]ManagedStatus
+	// pending 停止过、需要在重新运行后 Reconcile 的容器
+	pending map[string]bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewManager 创建一个管理 c 中所有容器的 Manager
+func NewManager(c Container, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		c:        c,
+		interval: defaultManagerInterval,
+		logger:   KlogLogger{},
+		status:   make(map[string]ManagedStatus),
+		pending:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start 在后台启动控制循环，立即执行第一轮处理，直到 ctx 被取消或者调用 Stop，
+// 控制循环运行时重复调用会返回 ErrManagerRunning，ctx 被取消、循环退出后可以再次调用
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return ErrManagerRunning
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.loop(ctx, m.done)
+	return nil
+}
+
+// Stop 停止控制循环并等待正在进行的一轮处理结束，Manager 没有启动时直接返回
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Status 返回每个容器最后一次处理的结果
+func (m *Manager) Status() map[string]ManagedStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := make(map[string]ManagedStatus, len(m.status))
+	for name, s := range m.status {
+		status[name] = s
+	}
+	return status
+}
+
+func (m *Manager) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		// ctx 的父 context 被取消时没有调用 Stop，清理这一次启动的状态，之后可以再次 Start
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.done == done {
+			m.cancel()
+			m.cancel, m.done = nil, nil
+		}
+	}()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.runOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce 执行一轮处理
+func (m *Manager) runOnce(ctx context.Context) {
+	infos, err := m.c.List(ctx, nil)
+	if err != nil {
+		m.logger.Errorf("Failed to list containers for the manager: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if ctx.Err() != nil {
+			return
+		}
+		if seen[info.Name] {
+			continue
+		}
+		seen[info.Name] = true
+
+		status, ok := m.handle(ctx, info)
+		m.mu.Lock()
+		if ok {
+			m.status[info.Name] = status
+		} else {
+			delete(m.status, info.Name)
+			delete(m.pending, info.Name)
+		}
+		m.mu.Unlock()
+	}
+
+	// 已经不存在的容器不再保留状态
+	m.mu.Lock()
+	for name := range m.status {
+		if !seen[name] {
+			delete(m.status, name)
+			delete(m.pending, name)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// handle 处理一个容器，容器在处理过程中被删除时返回 false
+func (m *Manager) handle(ctx context.Context, info ContainerInfo) (ManagedStatus, bool) {
+	status := ManagedStatus{Name: info.Name, State: info.State, LastRun: time.Now()}
+
+	var err error
+	switch {
+	case info.State != "running":
+		if m.reconcile {
+			m.mu.Lock()
+			m.pending[info.Name] = true
+			m.mu.Unlock()
+		}
+	case m.isPending(info.Name):
+		var result ReconcileResult
+		result, err = m.c.Reconcile(ctx, info.Name)
+		if err == nil {
+			status.Reconciled = true
+			m.mu.Lock()
+			delete(m.pending, info.Name)
+			m.mu.Unlock()
+		}
+		if result.Changed() {
+			m.logger.Infof("Manager reconciled container %s from %d to %d bytes", info.Name, result.OldSize, result.NewSize)
+		}
+	default:
+		status.ReducedBytes, err = m.c.Adjust(ctx, info.Name)
+	}
+
+	switch {
+	case errors.Is(err, ErrContainerNotFound):
+		return status, false
+	case errors.Is(err, ErrContainerNotRunning):
+		// 容器在 List 之后被停止或者暂停，等到下一轮再处理
+		err = nil
+	case err != nil:
+		m.logger.Errorf("Manager failed to handle container %s: %v", info.Name, err)
+	}
+	status.Err = err
+	return status, true
+}
+
+func (m *Manager) isPending(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pending[name]
+}
@@ -0,0 +1,129 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestManagerRunOnce(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	for _, name := range []string{"full", "idle", "stopped"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api.container("full").dataUsed = int64(defaultStorageSize) - 800*megabyte
+	if err := dc.Stop("stopped"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(dc, WithManagerReconcile(true))
+	m.runOnce(ctx)
+
+	status := m.Status()
+	if len(status) != 3 {
+		t.Fatalf("status = %+v, want 3 containers", status)
+	}
+	if s := status["full"]; s.State != "running" || s.ReducedBytes != 300*megabyte || s.Err != nil {
+		t.Fatalf("status of full = %+v", s)
+	}
+	if got := api.container("full").ballast; got != 4700*megabyte {
+		t.Fatalf("ballast of full = %d, want %d", got, 4700*megabyte)
+	}
+	if s := status["idle"]; s.ReducedBytes != 0 || s.Err != nil {
+		t.Fatalf("status of idle = %+v", s)
+	}
+	if s := status["stopped"]; s.State != "exited" || s.Reconciled {
+		t.Fatalf("status of stopped = %+v", s)
+	}
+
+	// 绕过 Start 直接启动的容器会在下一轮恢复 /ballast
+	c := api.container("stopped")
+	c.json.State.Running = true
+	c.json.State.Status = "running"
+	c.ballast = 2 * gigabyte
+	if err := api.ContainerRemove(ctx, "idle", container.RemoveOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	m.runOnce(ctx)
+
+	status = m.Status()
+	if s := status["stopped"]; !s.Reconciled || s.Err != nil {
+		t.Fatalf("status of stopped = %+v, want reconciled", s)
+	}
+	if c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast of stopped = %d, want %d", c.ballast, ballastSize)
+	}
+	if _, ok := status["idle"]; ok {
+		t.Fatal("removed container should be dropped from the status")
+	}
+
+	// 只恢复一次
+	c.ballast = 2 * gigabyte
+	m.runOnce(ctx)
+	if s := m.Status()["stopped"]; s.Reconciled || c.ballast != 2*gigabyte {
+		t.Fatalf("status of stopped = %+v, ballast = %d, want no second reconcile", s, c.ballast)
+	}
+}
+
+func TestManagerStartStop(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(dc, WithManagerInterval(10*time.Millisecond))
+	m.Stop() // 没有启动时直接返回
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(context.Background()); !errors.Is(err, ErrManagerRunning) {
+		t.Fatalf("second Start() error = %v, want ErrManagerRunning", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.Status()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("manager did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	// 停止后可以再次启动
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.Stop()
+}
+
+func TestManagerRestartAfterCancel(t *testing.T) {
+	m := NewManager(newTestContainer(newFakeDockerAPI()), WithManagerInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// 控制循环退出后可以再次启动，不需要先调用 Stop
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := m.Start(context.Background())
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrManagerRunning) || time.Now().After(deadline) {
+			t.Fatalf("Start() after cancel error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+}
@@ -0,0 +1,175 @@
+// Package metrics 将 ballast 容器的磁盘使用情况导出为 Prometheus 指标
+//
+// 该包单独依赖 prometheus/client_golang，不使用指标的调用方不需要引入该依赖。
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+// defaultScrapeTimeout 每次采集时获取所有容器磁盘使用情况的超时时间
+const defaultScrapeTimeout = 10 * time.Second
+
+var (
+	ballastBytesDesc = prometheus.NewDesc(
+		"ballast_bytes",
+		"Size of the /ballast file in bytes.",
+		[]string{"name"}, nil,
+	)
+	diskUsedBytesDesc = prometheus.NewDesc(
+		"disk_used_bytes",
+		"Used space of the container root filesystem in bytes.",
+		[]string{"name"}, nil,
+	)
+	diskFreeBytesDesc = prometheus.NewDesc(
+		"disk_free_bytes",
+		"Free space of the container root filesystem in bytes.",
+		[]string{"name"}, nil,
+	)
+	scrapeErrorsDesc = prometheus.NewDesc(
+		"ballast_scrape_errors",
+		"Number of containers that failed to be scraped in the last collection.",
+		nil, nil,
+	)
+)
+
+// Collector 实现了 prometheus.Collector，每次采集时通过 List 枚举容器，
+// 并获取运行中容器的 /ballast 大小和磁盘使用情况
+//
+// ballast_adjustments_total 和 stop_failures_total 两个计数器需要调用方在 Stop 之后调用 ObserveStop 更新。
+// Collector 同时实现了 container.DurationObserver，通过 container.WithDurationObserver 设置后
+// 会在 ballast_operation_duration_seconds 中记录每个操作的耗时。
+type Collector struct {
+	c       container.Container
+	timeout time.Duration
+	logger  container.Logger
+
+	mu          sync.Mutex
+	adjustments *prometheus.CounterVec
+	stopErrors  *prometheus.CounterVec
+	durations   *prometheus.HistogramVec
+}
+
+// CollectorOption 用于定制 Collector 的行为
+type CollectorOption func(col *Collector)
+
+// WithLogger 设置采集失败时输出日志使用的 Logger，默认为 container.KlogLogger
+func WithLogger(logger container.Logger) CollectorOption {
+	return func(col *Collector) {
+		if logger != nil {
+			col.logger = logger
+		}
+	}
+}
+
+// NewCollector 创建一个采集 c 所管理容器的 Collector
+func NewCollector(c container.Container, opts ...CollectorOption) *Collector {
+	col := &Collector{
+		c:       c,
+		timeout: defaultScrapeTimeout,
+		logger:  container.KlogLogger{},
+		adjustments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ballast_adjustments_total",
+			Help: "Number of times the /ballast file was reduced on stop.",
+		}, []string{"name"}),
+		stopErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stop_failures_total",
+			Help: "Number of failed stops, including failed /ballast adjustments.",
+		}, []string{"name"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ballast_operation_duration_seconds",
+			Help:    "Duration of run, stop, exec and adjust operations in seconds.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"op", "name"}),
+	}
+	for _, opt := range opts {
+		opt(col)
+	}
+	return col
+}
+
+// ObserveStop 根据 StopWithResult 的返回值更新计数器
+func (col *Collector) ObserveStop(name string, result container.StopResult, err error) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	if result.Adjusted {
+		col.adjustments.WithLabelValues(name).Inc()
+	}
+	if err != nil || result.AdjustError != nil {
+		col.stopErrors.WithLabelValues(name).Inc()
+	}
+}
+
+// ObserveDuration 实现 container.DurationObserver，记录操作 op 的耗时
+func (col *Collector) ObserveDuration(op, name string, d time.Duration) {
+	col.durations.WithLabelValues(op, name).Observe(d.Seconds())
+}
+
+// Describe 实现 prometheus.Collector
+func (col *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ballastBytesDesc
+	ch <- diskUsedBytesDesc
+	ch <- diskFreeBytesDesc
+	ch <- scrapeErrorsDesc
+	col.adjustments.Describe(ch)
+	col.stopErrors.Describe(ch)
+	col.durations.Describe(ch)
+}
+
+// Collect 实现 prometheus.Collector
+func (col *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), col.timeout)
+	defer cancel()
+
+	var scrapeErrors float64
+	infos, err := col.c.List(ctx, nil)
+	if err != nil {
+		col.logger.Errorf("Failed to list containers for metrics: %v", err)
+		scrapeErrors++
+	}
+
+	for _, info := range infos {
+		if info.State != "running" {
+			continue
+		}
+
+		used, _, free, err := col.c.DiskUsage(ctx, info.Name)
+		if errors.Is(err, container.ErrContainerNotRunning) {
+			continue
+		}
+		if err != nil {
+			col.logger.Errorf("Failed to get disk usage of container %s for metrics: %v", info.Name, err)
+			scrapeErrors++
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(diskUsedBytesDesc, prometheus.GaugeValue, float64(used), info.Name)
+		ch <- prometheus.MustNewConstMetric(diskFreeBytesDesc, prometheus.GaugeValue, float64(free), info.Name)
+
+		size, err := col.c.BallastSize(ctx, info.Name)
+		if errors.Is(err, container.ErrBallastNotFound) {
+			size, err = 0, nil
+		}
+		if err != nil {
+			col.logger.Errorf("Failed to get ballast size of container %s for metrics: %v", info.Name, err)
+			scrapeErrors++
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(ballastBytesDesc, prometheus.GaugeValue, float64(size), info.Name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.GaugeValue, scrapeErrors)
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.adjustments.Collect(ch)
+	col.stopErrors.Collect(ch)
+	col.durations.Collect(ch)
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+// stubContainer 只实现 Collector 用到的方法
+type stubContainer struct {
+	container.Container
+	infos   []container.ContainerInfo
+	usage   map[string][2]int64
+	ballast map[string]int64
+}
+
+func (s *stubContainer) List(context.Context, map[string]string) ([]container.ContainerInfo, error) {
+	return s.infos, nil
+}
+
+func (s *stubContainer) DiskUsage(_ context.Context, name string) (used, total, free int64, err error) {
+	u, ok := s.usage[name]
+	if !ok {
+		return 0, 0, 0, container.ErrContainerNotRunning
+	}
+	return u[0], u[0] + u[1], u[1], nil
+}
+
+func (s *stubContainer) BallastSize(_ context.Context, name string) (int64, error) {
+	size, ok := s.ballast[name]
+	if !ok {
+		return 0, container.ErrBallastNotFound
+	}
+	return size, nil
+}
+
+func TestCollector(t *testing.T) {
+	stub := &stubContainer{
+		infos: []container.ContainerInfo{
+			{Name: "web", State: "running"},
+			{Name: "db", State: "running"},
+			{Name: "old", State: "exited"},
+		},
+		usage:   map[string][2]int64{"web": {19000000000, 6000000000}, "db": {24000000000, 1000000000}},
+		ballast: map[string]int64{"web": 5000000000},
+	}
+	col := NewCollector(stub)
+	col.ObserveStop("db", container.StopResult{Adjusted: true, ReducedBytes: 500000000}, nil)
+	col.ObserveStop("db", container.StopResult{}, errors.New("stop failed"))
+
+	expected := `
+# HELP ballast_bytes Size of the /ballast file in bytes.
+# TYPE ballast_bytes gauge
+ballast_bytes{name="db"} 0
+ballast_bytes{name="web"} 5e+09
+# HELP disk_free_bytes Free space of the container root filesystem in bytes.
+# TYPE disk_free_bytes gauge
+disk_free_bytes{name="db"} 1e+09
+disk_free_bytes{name="web"} 6e+09
+# HELP ballast_adjustments_total Number of times the /ballast file was reduced on stop.
+# TYPE ballast_adjustments_total counter
+ballast_adjustments_total{name="db"} 1
+# HELP stop_failures_total Number of failed stops, including failed /ballast adjustments.
+# TYPE stop_failures_total counter
+stop_failures_total{name="db"} 1
+`
+	err := testutil.CollectAndCompare(col, strings.NewReader(expected),
+		"ballast_bytes", "disk_free_bytes", "ballast_adjustments_total", "stop_failures_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorObserveDuration(t *testing.T) {
+	col := NewCollector(&stubContainer{})
+	var observer container.DurationObserver = col
+	observer.ObserveDuration("run", "web", 30*time.Millisecond)
+	observer.ObserveDuration("run", "web", 3*time.Second)
+	observer.ObserveDuration("exec", "web", 5*time.Millisecond)
+
+	if got := testutil.CollectAndCount(col, "ballast_operation_duration_seconds"); got != 2 {
+		t.Fatalf("got %d duration series, want 2", got)
+	}
+}
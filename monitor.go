@@ -0,0 +1,82 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Monitor 在容器运行期间每隔 interval 检查一次磁盘使用情况，剩余空间不足时调整 /ballast 文件，
+// 直到 ctx 被取消，返回值为 ctx.Err()
+//
+// 同一个容器同时只能有一个 Monitor，重复调用会返回 ErrMonitorRunning。
+// 容器没有运行或者被暂停时会跳过本次检查，单次检查失败只会记录日志，不会退出。
+func (dc *DockerContainer) Monitor(ctx context.Context, name string, interval time.Duration) (err error) {
+	defer wrapOp("monitor", name, &err)
+
+	if interval <= 0 {
+		return fmt.Errorf("%w: invalid monitor interval %s, must be positive", ErrInvalidArgument, interval)
+	}
+
+	dc.mu.Lock()
+	if _, ok := dc.monitors[name]; ok {
+		dc.mu.Unlock()
+		return fmt.Errorf("failed to monitor container %s: %w", name, ErrMonitorRunning)
+	}
+	dc.monitors[name] = struct{}{}
+	dc.mu.Unlock()
+
+	defer func() {
+		dc.mu.Lock()
+		delete(dc.monitors, name)
+		dc.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := dc.monitorOnce(ctx, name); err != nil {
+				dc.logger.Errorf("Failed to monitor container %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// monitorOnce 检查一次容器的磁盘使用情况，容器没有运行或者被暂停时跳过
+func (dc *DockerContainer) monitorOnce(ctx context.Context, name string) error {
+	_, err := dc.Adjust(ctx, name)
+	if errors.Is(err, ErrContainerNotRunning) {
+		return nil
+	}
+	return err
+}
+
+// Adjust 检查运行中容器的磁盘使用情况，剩余空间不足时按照与 Stop 相同的方式减小 /ballast，但不会停止容器，
+// 返回 /ballast 减少的字节数
+//
+// 容器没有限制系统盘大小时直接返回，容器没有运行或者被暂停时返回 ErrContainerNotRunning。
+func (dc *DockerContainer) Adjust(ctx context.Context, name string) (_ int64, err error) {
+	defer wrapOp("adjust", name, &err)
+	defer dc.lock(name)()
+
+	containerInspect, limits, err := dc.inspectLimits(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if !limits.limited {
+		return 0, nil
+	}
+	// 暂停的容器无法执行命令，等到恢复后再检查
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		return 0, fmt.Errorf("failed to adjust container %s: %w", name, ErrContainerNotRunning)
+	}
+
+	_, reduced, err := dc.checkBallast(ctx, name, containerInspect.ID, limits.path, limits.limit)
+	return reduced, err
+}
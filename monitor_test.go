@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMonitorPreventsOverlap(t *testing.T) {
+	dc, err := newDockerContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- dc.Monitor(ctx, "test", time.Hour)
+	}()
+
+	// 等待第一个 Monitor 注册完成
+	for i := 0; ; i++ {
+		dc.mu.Lock()
+		_, ok := dc.monitors["test"]
+		dc.mu.Unlock()
+		if ok {
+			break
+		}
+		if i > 100 {
+			t.Fatal("monitor did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := dc.Monitor(ctx, "test", time.Hour); !errors.Is(err, ErrMonitorRunning) {
+		t.Fatalf("expected ErrMonitorRunning, got %v", err)
+	}
+	if err := dc.Monitor(ctx, "test", 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// validName 是 Docker 对容器名称的要求，见 daemon/names 中的 RestrictedNamePattern
+var validName = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// validateName 校验容器名称是否符合 Docker 的要求，不符合时返回 ErrInvalidName 并指出第一个非法字符
+func validateName(name string) error {
+	if validName.MatchString(name) {
+		return nil
+	}
+
+	trimmed := name
+	if len(trimmed) > 0 && trimmed[0] == '/' {
+		trimmed = trimmed[1:]
+	}
+	switch {
+	case trimmed == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidName)
+	case len(trimmed) == 1:
+		return fmt.Errorf("%w: %q is too short, must be at least 2 characters", ErrInvalidName, name)
+	}
+	for i, r := range trimmed {
+		if isNameChar(r) && (i > 0 || (r != '_' && r != '.' && r != '-')) {
+			continue
+		}
+		if i == 0 {
+			return fmt.Errorf("%w: %q must start with a letter or digit, got %q", ErrInvalidName, name, r)
+		}
+		return fmt.Errorf("%w: %q contains invalid character %q at position %d", ErrInvalidName, name, r, i)
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidName, name)
+}
+
+func isNameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-'
+}
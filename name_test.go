@@ -0,0 +1,53 @@
+package container
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{name: "test"},
+		{name: "/test"},
+		{name: "web-1.blue_v2"},
+		{name: "", wantErr: "empty"},
+		{name: "a", wantErr: "too short"},
+		{name: "-test", wantErr: `'-'`},
+		{name: "my container", wantErr: `' '`},
+		{name: "test/1", wantErr: `'/'`},
+		{name: "测试", wantErr: `'测'`},
+	}
+
+	for _, tt := range tests {
+		err := validateName(tt.name)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateName(%q) unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("validateName(%q) expected ErrInvalidName, got %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validateName(%q) = %v, want message containing %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunInvalidName(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("bad name"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if len(api.containers) != 0 {
+		t.Fatal("no container should be created for an invalid name")
+	}
+}
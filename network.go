@@ -0,0 +1,77 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// WithNetworkAutoCreate 开启后 RunOptions.Networks 中不存在的网络会被自动创建为 bridge 网络，
+// 默认关闭，网络不存在时 Run 返回 ErrNetworkNotFound
+func WithNetworkAutoCreate(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.networkAutoCreate = enabled
+	}
+}
+
+// validateNetworks 校验 opts.Networks，网络名称不能为空或者重复，并且只能与 bridge 或者自定义网络的网络模式同时使用
+func validateNetworks(opts RunOptions) error {
+	if len(opts.Networks) == 0 {
+		return nil
+	}
+	mode := container.NetworkMode(opts.NetworkMode)
+	if mode.IsHost() || mode.IsNone() || mode.IsContainer() {
+		return fmt.Errorf("%w: conflicting network options: networks cannot be used with network mode %s", ErrInvalidArgument, opts.NetworkMode)
+	}
+	seen := make(map[string]bool, len(opts.Networks))
+	for _, name := range opts.Networks {
+		if name == "" || seen[name] {
+			return fmt.Errorf("%w: invalid networks %q, names must be non-empty and unique", ErrInvalidArgument, opts.Networks)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ensureNetworks 检查 names 中的网络是否存在，不存在时按照 WithNetworkAutoCreate 创建或者返回 ErrNetworkNotFound
+func (dc *DockerContainer) ensureNetworks(ctx context.Context, names []string) error {
+	for _, name := range names {
+		_, err := dc.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+		if err == nil {
+			continue
+		}
+		if !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to inspect network %s: %w", name, err)
+		}
+		if !dc.networkAutoCreate {
+			return fmt.Errorf("%w: %s", ErrNetworkNotFound, name)
+		}
+		if dc.dryRunf("Would create network %s", name) {
+			continue
+		}
+		// 其他调用方可能同时创建了同名的网络
+		if _, err := dc.cli.NetworkCreate(ctx, name, network.CreateOptions{Driver: "bridge"}); err != nil && !errdefs.IsConflict(err) {
+			return fmt.Errorf("failed to create network %s: %w", name, err)
+		}
+		dc.logger.Infof("Successfully created network %s", name)
+	}
+	return nil
+}
+
+// connectNetworks 将容器 id 连接到 opts.Networks 中创建容器时没有加入的网络
+//
+// 创建容器时只能加入 NetworkMode 对应的一个网络，其他网络需要在启动前通过 NetworkConnect 加入。
+func (dc *DockerContainer) connectNetworks(ctx context.Context, id string, opts RunOptions) error {
+	for _, name := range opts.Networks {
+		if name == opts.NetworkMode {
+			continue
+		}
+		if err := dc.cli.NetworkConnect(ctx, name, id, nil); err != nil {
+			return fmt.Errorf("failed to connect container %s to network %s: %w", opts.Name, name, err)
+		}
+	}
+	return nil
+}
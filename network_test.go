@@ -0,0 +1,51 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunNetworks(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.networks["sidecars"] = true
+	api.networks["metrics"] = true
+	dc := newTestContainer(api)
+
+	id, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", Networks: []string{"sidecars", "metrics"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 容器以第一个网络创建，其余的网络在启动前连接
+	if mode := api.container("test").hostConfig.NetworkMode; mode != "sidecars" {
+		t.Fatalf("network mode = %q, want sidecars", mode)
+	}
+	if got := strings.Join(api.connects, ","); got != "metrics/"+id {
+		t.Fatalf("connects = %s, want metrics/%s", got, id)
+	}
+
+	// 网络不存在时不创建容器
+	_, err = dc.RunWithOptions(context.Background(), RunOptions{Name: "missing", Networks: []string{"backend"}})
+	if !errors.Is(err, ErrNetworkNotFound) || KindOf(err) != KindNotFound {
+		t.Fatalf("expected ErrNetworkNotFound, got %v", err)
+	}
+	if api.container("missing") != nil {
+		t.Fatal("container should not be created when a network is missing")
+	}
+
+	// 开启 WithNetworkAutoCreate 后自动创建网络
+	dc = newTestContainer(api, WithNetworkAutoCreate(true))
+	api.connects = nil
+	id, err = dc.RunWithOptions(context.Background(), RunOptions{Name: "auto", NetworkMode: "bridge", Networks: []string{"backend"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !api.networks["backend"] || strings.Join(api.connects, ",") != "backend/"+id {
+		t.Fatalf("networks = %v, connects = %v", api.networks, api.connects)
+	}
+
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "host", NetworkMode: "host", Networks: []string{"backend"}}); err == nil {
+		t.Fatal("networks should conflict with the host network mode")
+	}
+}
@@ -0,0 +1,35 @@
+package container
+
+import "time"
+
+// DurationObserver 记录 Run、Stop、容器内执行的每一条命令以及 /ballast 的调整的耗时，默认不做任何事情
+//
+// 可以基于 Prometheus 的 histogram 或者 statsd 实现，子包 metrics 中的 Collector 实现了该接口。
+type DurationObserver interface {
+	// ObserveDuration 在操作结束后调用，op 为 run、stop、exec 或者 adjust，name 为容器名称，
+	// 失败的操作同样会被记录
+	ObserveDuration(op, name string, d time.Duration)
+}
+
+// WithDurationObserver 设置记录操作耗时使用的 DurationObserver，为 nil 时不记录
+func WithDurationObserver(observer DurationObserver) Option {
+	return func(dc *DockerContainer) {
+		if observer == nil {
+			observer = noopObserver{}
+		}
+		dc.observer = observer
+	}
+}
+
+// observeDuration 在 defer 中使用，返回的函数被调用时记录从现在开始操作 op 的耗时
+func (dc *DockerContainer) observeDuration(op, name string) func() {
+	start := time.Now()
+	return func() {
+		dc.observer.ObserveDuration(op, name, time.Since(start))
+	}
+}
+
+// noopObserver 是默认的 DurationObserver，不记录任何耗时
+type noopObserver struct{}
+
+func (noopObserver) ObserveDuration(string, string, time.Duration) {}
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver 记录每次 ObserveDuration 的操作和容器名称
+type recordingObserver struct {
+	mu  sync.Mutex
+	ops []string
+}
+
+func (o *recordingObserver) ObserveDuration(op, name string, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ops = append(o.ops, op+"/"+name)
+}
+
+func (o *recordingObserver) has(op string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, got := range o.ops {
+		if got == op {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDurationObserver(t *testing.T) {
+	api := newFakeDockerAPI()
+	observer := &recordingObserver{}
+	dc := newTestContainer(api, WithDurationObserver(observer))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// 剩余空间不足，Stop 时需要调整 /ballast
+	c := api.container("test")
+	api.mu.Lock()
+	c.dataUsed = c.limit() - int64(ballastSize) - 500*megabyte
+	api.mu.Unlock()
+	if err := dc.Stop("test"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	// exec 使用容器名称而不是 ID
+	for _, op := range []string{"run/test", "exec/test", "stop/test", "adjust/test"} {
+		if !observer.has(op) {
+			t.Fatalf("observed %v, want %s", observer.ops, op)
+		}
+	}
+
+	// 失败的操作同样被记录
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "x"}); err == nil {
+		t.Fatal("RunWithOptions() with an invalid name should fail")
+	}
+	if !observer.has("run/x") {
+		t.Fatalf("observed %v, want the failed run", observer.ops)
+	}
+
+	// nil 时不记录
+	if _, err := newTestContainer(api, WithDurationObserver(nil)).Run("other"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
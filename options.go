@@ -0,0 +1,379 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Option 用于定制 DockerContainer 的行为
+type Option func(dc *DockerContainer)
+
+// WithImage 设置创建容器时使用的镜像，默认为 ubuntu:latest
+func WithImage(image string) Option {
+	return func(dc *DockerContainer) {
+		if image != "" {
+			dc.image = image
+		}
+	}
+}
+
+// WithCmd 设置容器的启动命令，默认为 sleep infinity，设置了 WithEntrypoint 时默认使用镜像中的 CMD
+func WithCmd(cmd ...string) Option {
+	return func(dc *DockerContainer) {
+		if len(cmd) > 0 {
+			dc.cmd = cmd
+		}
+	}
+}
+
+// WithEntrypoint 设置容器的 entrypoint，默认使用镜像中的 ENTRYPOINT
+func WithEntrypoint(entrypoint ...string) Option {
+	return func(dc *DockerContainer) {
+		if len(entrypoint) > 0 {
+			dc.entrypoint = entrypoint
+		}
+	}
+}
+
+// WithAutoPull 设置镜像不存在时是否自动拉取，默认开启，离线环境可以关闭，此时镜像不存在会直接返回错误
+func WithAutoPull(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.autoPull = enabled
+	}
+}
+
+// WithRegistryAuth 设置拉取镜像时使用的认证信息，auth 为 base64 编码的 registry.AuthConfig，
+// 可以使用 registry.EncodeAuthConfig 生成
+func WithRegistryAuth(auth string) Option {
+	return func(dc *DockerContainer) {
+		dc.registryAuth = auth
+	}
+}
+
+// WithAllocStrategy 设置创建 /ballast 文件的方式，默认为 AllocAuto
+func WithAllocStrategy(strategy AllocStrategy) Option {
+	return func(dc *DockerContainer) {
+		dc.allocStrategy = strategy
+	}
+}
+
+// WithBallastMode 设置 /ballast 文件是否写入真实数据，默认为 BallastSparse，
+// 设置为 BallastDense 时会忽略 WithAllocStrategy，始终使用 dd
+func WithBallastMode(mode BallastMode) Option {
+	return func(dc *DockerContainer) {
+		dc.ballastMode = mode
+	}
+}
+
+// WithAllocTolerance 设置创建 /ballast 后 df 的已用空间的增量允许比 /ballast 的大小少的百分比，默认为 5，
+// 增量更少时 /ballast 被认为没有占用配额，返回 ErrBallastIneffective
+func WithAllocTolerance(percent int) Option {
+	return func(dc *DockerContainer) {
+		dc.allocTolerance = percent
+	}
+}
+
+// WithDenseRetry 开启后，fallocate 或者 truncate 创建的 /ballast 没有占用预期的空间时，会使用 dd 写入真实的数据重新创建一次（只支持 BackendFile），
+// 默认关闭，直接返回 ErrBallastIneffective
+func WithDenseRetry(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.denseRetry = enabled
+	}
+}
+
+// WithFillPattern 设置使用 dd 创建 /ballast 时写入的数据，默认为 FillZero
+//
+// 默认仍然优先使用开销最小的 fallocate，设置为 FillRandom 时与 BallastDense 一样忽略 WithAllocStrategy，
+// 始终使用 dd 写入 /dev/urandom 的随机数据，适用于会压缩 0 的文件系统。创建后同样会通过 df 检查已用空间的增量。
+func WithFillPattern(pattern FillPattern) Option {
+	return func(dc *DockerContainer) {
+		dc.fillPattern = pattern
+	}
+}
+
+// WithBallastPath 设置 /ballast 文件的绝对路径，默认为 /ballast，目录不存在时会自动创建
+//
+// 路径记录在容器的 ballast_path 标签中，修改该选项不会影响已经创建的容器。
+func WithBallastPath(path string) Option {
+	return func(dc *DockerContainer) {
+		dc.ballastPath = path
+	}
+}
+
+// WithBestEffortBallast 开启后创建 /ballast 遇到空间不足时不再返回 ErrInsufficientSpace，
+// 而是在保留 WithTargetFreeSpace 剩余空间的前提下创建尽可能大的 /ballast，默认关闭
+//
+// 不足的部分会在之后 Start 或者 GrowBallast 时按照 ballast 标签尽量补齐。
+func WithBestEffortBallast(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.bestEffort = enabled
+	}
+}
+
+// WithLabelPrefix 设置创建容器时写入的 threshold、ballast、base_storage、path 标签的命名空间，默认为 io.ballast/，
+// 为空时不使用命名空间
+//
+// 读取标签时优先使用带命名空间的标签，不存在时兼容旧版本创建的容器中没有命名空间的 threshold、ballast_path 等标签。
+// 修改命名空间后，之前使用其他命名空间创建的容器将无法被识别。
+func WithLabelPrefix(prefix string) Option {
+	return func(dc *DockerContainer) {
+		dc.labelPrefix = prefix
+	}
+}
+
+// WithDisableBallast 开启后所有容器都按照 RunOptions.DisableBallast 创建，不限制系统盘大小也不创建 /ballast，默认关闭
+func WithDisableBallast(disabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.disableBallast = disabled
+	}
+}
+
+// WithKeepOnFailure 开启后所有容器都按照 RunOptions.KeepOnFailure 创建，创建 /ballast 失败时保留容器，默认关闭
+func WithKeepOnFailure(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.keepOnFailure = enabled
+	}
+}
+
+// WithBallastOptional 开启后所有容器都按照 RunOptions.BallastOptional 创建，创建 /ballast 失败时容器继续运行，默认关闭
+func WithBallastOptional(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.ballastOptional = enabled
+	}
+}
+
+// WithStopTimeout 设置停止容器时发送 SIGTERM 后等待的时间，超时后发送 SIGKILL，默认使用 daemon 的配置（通常为 10s）
+//
+// Docker 只支持以秒为单位，不足 1 秒的部分向上取整；小于等于 0 时不等待，直接发送 SIGKILL。
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(dc *DockerContainer) {
+		dc.stopTimeout = &timeout
+	}
+}
+
+// WithOnAdjust 设置每次成功调整 /ballast 后调用的回调，参数为容器名称以及调整前后 /ballast 的大小
+//
+// 回调在 Stop 的过程中同步执行，耗时较长的回调会阻塞容器的停止。
+func WithOnAdjust(fn func(name string, oldSize, newSize int64)) Option {
+	return func(dc *DockerContainer) {
+		dc.onAdjust = fn
+	}
+}
+
+// WithOnAdjustError 设置调整 /ballast 失败时调用的回调，与 WithOnAdjust 一样在 Stop 的过程中同步执行
+func WithOnAdjustError(fn func(name string, err error)) Option {
+	return func(dc *DockerContainer) {
+		dc.onAdjustError = fn
+	}
+}
+
+// RunOptions 描述创建容器时的参数，零值字段会使用默认值填充
+type RunOptions struct {
+	// Name 容器名称
+	Name string
+	// Image 镜像，为空时使用 WithImage 设置的镜像
+	Image string
+	// Cmd 启动命令，为空时使用 WithCmd 设置的命令
+	Cmd []string
+	// Entrypoint 为空时使用 WithEntrypoint 设置的 entrypoint
+	Entrypoint []string
+	// Env 环境变量，格式为 KEY=VALUE
+	Env []string
+	// Labels 额外的容器标签，与 threshold 等标签一起写入容器，可以通过 List 的 filters 查询，
+	// 不能使用 WithLabelPrefix 命名空间下的标签以及 threshold 等旧版本的标签
+	Labels map[string]string
+	// StorageSize 用户可用的系统盘大小，单位为字节，默认 20GB
+	StorageSize int64
+	// BallastSize /ballast 文件大小，单位为字节，默认 5GB
+	BallastSize int64
+	// BallastPath /ballast 文件的绝对路径，为空时使用 WithBallastPath 设置的路径，
+	// 设置了 BallastMount 时为该挂载点下与之同名的文件
+	BallastPath string
+	// BallastMount /ballast 所在的挂载点，必须是 Mounts 中某个挂载的 Target，为空时为根目录，即保护容器的系统盘
+	//
+	// 设置后 /ballast 保护的是该卷的空间：Stop 等使用 df 检查该挂载点，不再设置 storage-opt size，
+	// StorageSize + BallastSize 应当与卷的容量一致。挂载点记录在 mount 标签中。
+	BallastMount string
+	// User 容器主进程使用的用户，格式与 docker run --user 相同，为空时使用镜像中的配置
+	User string
+	// ExecUser 在容器内执行 df、fallocate 等 /ballast 相关命令时使用的用户，与 User 相互独立，
+	// 为空时使用 WithExecUser 设置的用户（默认 root），记录在 exec_user 标签中
+	ExecUser string
+	// Mounts 挂载配置
+	Mounts []mount.Mount
+	// OnConflict 同名容器已经存在时的处理方式，默认返回 ErrNameConflict
+	OnConflict ConflictPolicy
+	// Memory 内存限制，单位为字节，0 表示不限制
+	Memory int64
+	// NanoCPUs CPU 限制，单位为 10^-9 个 CPU，例如 1.5 个 CPU 为 1500000000，0 表示不限制
+	NanoCPUs int64
+	// PidsLimit 容器内最大的进程数，0 表示不限制
+	PidsLimit int64
+	// NetworkMode 容器的网络模式，例如 bridge、host、none、container:<name> 或者自定义网络的名称，为空时使用默认的 bridge
+	NetworkMode string
+	// Networks 容器需要加入的已经存在的网络，例如 sidecar 所在的自定义网络，不存在时返回 ErrNetworkNotFound，
+	// 开启 WithNetworkAutoCreate 后自动创建。NetworkMode 为空时容器以第一个网络创建，不再加入默认的 bridge
+	Networks []string
+	// DNS 容器使用的 DNS 服务器地址，不能与 host 和 container: 网络模式同时使用
+	DNS []string
+	// ExtraHosts 额外写入 /etc/hosts 的记录，格式为 host:ip，不能与 container: 网络模式同时使用
+	ExtraHosts []string
+	// Privileged 是否以特权模式运行容器，使用 BackendLoop 时通常需要开启
+	Privileged bool
+	// DisableBallast 为 true 时不设置 storage-opt size 和 threshold 等标签，也不创建 /ballast，
+	// 适用于宿主机已经通过其他方式限制了磁盘配额的场景，此时 Stop 和 Start 只会停止和启动容器，
+	// StorageSize、BallastSize 和 BallastPath 会被忽略
+	DisableBallast bool
+	// KeepOnFailure 为 true 时，容器启动或者创建 /ballast 失败后不删除容器，而是停止后保留，用于排查失败的原因，
+	// 返回的 OpError 中 ID 为保留的容器 ID。默认删除容器
+	KeepOnFailure bool
+	// BallastOptional 为 true 时 /ballast 只是尽力而为：容器启动后创建 /ballast 失败不会删除容器，
+	// 而是返回容器 ID 和 *BallastWarning。容器启动失败时仍然按照 KeepOnFailure 处理
+	BallastOptional bool
+	// HealthCmd 健康检查命令，以 exec 的形式执行，退出码为 0 表示健康，为空时使用镜像中的配置
+	HealthCmd []string
+	// HealthInterval 两次健康检查的间隔，0 表示使用 Docker 的默认值（30s）
+	HealthInterval time.Duration
+	// HealthTimeout 单次健康检查的超时时间，0 表示使用 Docker 的默认值（30s）
+	HealthTimeout time.Duration
+	// HealthRetries 连续失败多少次后认为容器不健康，0 表示使用 Docker 的默认值（3）
+	HealthRetries int
+}
+
+// validateResources 校验 opts 中的资源限制，不能为负数
+func validateResources(opts RunOptions) error {
+	if opts.Memory < 0 {
+		return fmt.Errorf("%w: invalid memory limit %d, must not be negative", ErrInvalidArgument, opts.Memory)
+	}
+	if opts.NanoCPUs < 0 {
+		return fmt.Errorf("%w: invalid nano cpus %d, must not be negative", ErrInvalidArgument, opts.NanoCPUs)
+	}
+	if opts.PidsLimit < 0 {
+		return fmt.Errorf("%w: invalid pids limit %d, must not be negative", ErrInvalidArgument, opts.PidsLimit)
+	}
+	return nil
+}
+
+// validateNetwork 校验 opts 中的网络配置，DNS 和 ExtraHosts 必须合法并且不能与网络模式冲突
+//
+// host 模式使用宿主机的 /etc/resolv.conf，container: 模式共享另一个容器的网络命名空间，
+// Docker 会拒绝这些模式下的 DNS，container: 模式下同样不能修改 /etc/hosts。
+func validateNetwork(opts RunOptions) error {
+	mode := container.NetworkMode(opts.NetworkMode)
+	for _, dns := range opts.DNS {
+		if net.ParseIP(dns) == nil {
+			return fmt.Errorf("%w: invalid dns server %q, must be an IP address", ErrInvalidArgument, dns)
+		}
+	}
+	if len(opts.DNS) > 0 && (mode.IsHost() || mode.IsContainer()) {
+		return fmt.Errorf("%w: conflicting network options: dns cannot be used with network mode %s", ErrInvalidArgument, opts.NetworkMode)
+	}
+	for _, host := range opts.ExtraHosts {
+		name, ip, ok := strings.Cut(host, ":")
+		if !ok || name == "" || (ip != "host-gateway" && net.ParseIP(ip) == nil) {
+			return fmt.Errorf("%w: invalid extra host %q, must be host:ip", ErrInvalidArgument, host)
+		}
+	}
+	if len(opts.ExtraHosts) > 0 && mode.IsContainer() {
+		return fmt.Errorf("%w: conflicting network options: extra hosts cannot be used with network mode %s", ErrInvalidArgument, opts.NetworkMode)
+	}
+	return nil
+}
+
+// ConflictPolicy 表示创建容器时遇到同名容器的处理方式
+type ConflictPolicy int
+
+const (
+	// ConflictError 返回 ErrNameConflict
+	ConflictError ConflictPolicy = iota
+	// ConflictReuse 返回已经存在的容器 ID，适用于崩溃恢复时重复调用 Run 的场景
+	ConflictReuse
+	// ConflictReplace 强制删除已经存在的容器后重新创建
+	ConflictReplace
+)
+
+// withDefaults 使用 DockerContainer 的默认配置填充 opts 中的零值字段
+func (dc *DockerContainer) withDefaults(opts RunOptions) RunOptions {
+	if opts.Image == "" {
+		opts.Image = dc.image
+	}
+	if len(opts.Entrypoint) == 0 {
+		opts.Entrypoint = dc.entrypoint
+	}
+	if len(opts.Cmd) == 0 {
+		opts.Cmd = dc.cmd
+	}
+	// 设置了 entrypoint 时 sleep infinity 会被当作 entrypoint 的参数，只有两者都为空时才使用 defaultCmd
+	if len(opts.Cmd) == 0 && len(opts.Entrypoint) == 0 {
+		opts.Cmd = defaultCmd
+	}
+	if opts.StorageSize <= 0 {
+		opts.StorageSize = int64(defaultStorageSize)
+	}
+	if opts.BallastSize <= 0 {
+		opts.BallastSize = int64(ballastSize)
+	}
+	if opts.BallastMount == "" {
+		opts.BallastMount = rootMount
+	}
+	if opts.BallastPath == "" {
+		opts.BallastPath = dc.ballastPath
+		if opts.BallastMount != rootMount {
+			opts.BallastPath = pathpkg.Join(opts.BallastMount, pathpkg.Base(dc.ballastPath))
+		}
+	}
+	if opts.NetworkMode == "" && len(opts.Networks) > 0 {
+		opts.NetworkMode = opts.Networks[0]
+	}
+	if opts.ExecUser == "" {
+		opts.ExecUser = dc.execUser
+	}
+	if dc.disableBallast {
+		opts.DisableBallast = true
+	}
+	if dc.keepOnFailure {
+		opts.KeepOnFailure = true
+	}
+	if dc.ballastOptional {
+		opts.BallastOptional = true
+	}
+	return opts
+}
+
+// WithTargetFreeSpace 设置 Stop 调整 /ballast 后期望的最小剩余空间，单位为字节，默认 1GB，必须为正数
+func WithTargetFreeSpace(bytes int64) Option {
+	return func(dc *DockerContainer) {
+		dc.targetFree = bytes
+	}
+}
+
+// WithIdempotentStartStop 设置 Start 已经运行的容器、Stop 已经停止的容器时是否直接返回，默认关闭
+//
+// 关闭时分别返回 ErrAlreadyRunning 和 ErrAlreadyStopped。开启后 Start 返回容器的 ID，不会再次恢复 /ballast；
+// Stop 返回只包含容器 ID 的 StopResult，不会检查磁盘使用情况。
+func WithIdempotentStartStop(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.idempotentStartStop = enabled
+	}
+}
+
+// WithFreeMargin 设置 Stop 时触发调整 /ballast 的剩余空间阈值，单位为字节，默认 1GB，必须为正数
+func WithFreeMargin(bytes int64) Option {
+	return func(dc *DockerContainer) {
+		dc.freeMargin = bytes
+	}
+}
+
+// WithReductionStep 设置 Stop 时每一轮减少 /ballast 的大小，单位为十进制的 GB，例如 0.1 表示 100MB，必须为正数
+//
+// Deprecated: 等同于 WithReductionPolicy(FixedReduction(step))，其中 step 为 gb 对应的字节数，请直接使用 WithReductionPolicy。
+// 不设置时默认使用 DeficitReduction，而不是固定的 0.5GB。
+func WithReductionStep(gb float64) Option {
+	return WithReductionPolicy(FixedReduction(gigabytesToBytes(gb)))
+}
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WatchPressure 检查剩余空间的间隔，剩余空间越接近阈值间隔越短
+const (
+	minPressureInterval = 500 * time.Millisecond
+	maxPressureInterval = 30 * time.Second
+)
+
+// PressureEvent 是 WatchPressure 在剩余空间越过阈值时发送的事件
+type PressureEvent struct {
+	Time time.Time
+	// Used、Total 和 Free 是事件发生时 /ballast 所在挂载点的已用空间、总空间和剩余空间，单位为字节
+	Used  int64
+	Total int64
+	Free  int64
+	// Below 为 true 表示剩余空间低于阈值，为 false 表示剩余空间恢复到阈值及以上
+	Below bool
+}
+
+// WatchPressure 持续检查容器 name 的剩余空间，剩余空间低于 freeThreshold 以及恢复到 freeThreshold 及以上时各发送一个 PressureEvent，
+// 开始时剩余空间已经低于阈值同样会发送事件
+//
+// 检查的间隔随剩余空间变化，低于阈值时为 500ms，剩余空间达到阈值的两倍时为 30s，两者之间线性变化，
+// 这样既能在接近阈值时及时发现，又不会在空间充足时频繁执行 df。容器停止或者被暂停期间每 30s 检查一次是否恢复运行。
+// ctx 被取消或者容器被删除后关闭返回的 channel，容器不存在时直接返回 ErrContainerNotFound。
+func (dc *DockerContainer) WatchPressure(ctx context.Context, name string, freeThreshold int64) (_ <-chan PressureEvent, err error) {
+	defer wrapOp("watch_pressure", name, &err)
+
+	if freeThreshold <= 0 {
+		return nil, fmt.Errorf("%w: invalid free threshold %d, must be positive", ErrInvalidArgument, freeThreshold)
+	}
+	if _, err := dc.inspectContainer(ctx, name); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan PressureEvent)
+	go func() {
+		defer close(ch)
+
+		below := false
+		for {
+			interval := maxPressureInterval
+			used, total, free, err := dc.DiskUsage(ctx, name)
+			switch {
+			case ctx.Err() != nil, errors.Is(err, ErrContainerNotFound):
+				return
+			case errors.Is(err, ErrContainerNotRunning):
+				// 容器停止后无法执行 df，等待容器重新运行
+			case err != nil:
+				dc.logger.Errorf("Failed to watch disk pressure of container %s: %v", name, err)
+			default:
+				interval = pressureInterval(free, freeThreshold)
+				if (free < freeThreshold) != below {
+					below = !below
+					event := PressureEvent{Time: time.Now(), Used: used, Total: total, Free: free, Below: below}
+					select {
+					case ch <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// pressureInterval 返回剩余空间为 free 时下一次检查的间隔
+func pressureInterval(free, freeThreshold int64) time.Duration {
+	if free <= freeThreshold {
+		return minPressureInterval
+	}
+	ratio := float64(free-freeThreshold) / float64(freeThreshold)
+	if ratio >= 1 {
+		return maxPressureInterval
+	}
+	return minPressureInterval + time.Duration(ratio*float64(maxPressureInterval-minPressureInterval))
+}
@@ -0,0 +1,89 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPressureInterval(t *testing.T) {
+	tests := []struct {
+		free int64
+		want time.Duration
+	}{
+		{free: 0, want: minPressureInterval},
+		{free: 100, want: minPressureInterval},
+		{free: 150, want: minPressureInterval + (maxPressureInterval-minPressureInterval)/2},
+		{free: 200, want: maxPressureInterval},
+		{free: 1000, want: maxPressureInterval},
+	}
+	for _, tt := range tests {
+		if got := pressureInterval(tt.free, 100); got != tt.want {
+			t.Errorf("pressureInterval(%d, 100) = %s, want %s", tt.free, got, tt.want)
+		}
+	}
+}
+
+func TestWatchPressure(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	_, _, free, err := dc.DiskUsage(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("DiskUsage() error = %v", err)
+	}
+
+	if _, err := dc.WatchPressure(context.Background(), "missing", free); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("WatchPressure() error = %v, want ErrContainerNotFound", err)
+	}
+
+	// 开始时已经低于阈值
+	events, err := dc.WatchPressure(context.Background(), "test", free+1)
+	if err != nil {
+		t.Fatalf("WatchPressure() error = %v", err)
+	}
+	event := receiveEvent(t, events)
+	if !event.Below || event.Free != free {
+		t.Fatalf("event = %+v, want below the threshold with %d bytes free", event, free)
+	}
+
+	// 释放空间后恢复到阈值以上
+	c := api.container("test")
+	api.mu.Lock()
+	c.dataUsed -= 2
+	api.mu.Unlock()
+	if event := receiveEvent(t, events); event.Below || event.Free != free+2 {
+		t.Fatalf("event = %+v, want above the threshold with %d bytes free", event, free+2)
+	}
+
+	// 删除容器后关闭 channel
+	if err := dc.ForceRemove("test"); err != nil {
+		t.Fatalf("ForceRemove() error = %v", err)
+	}
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event %+v after the container is removed", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel is not closed after the container is removed")
+	}
+}
+
+// receiveEvent 等待 events 中的下一个事件
+func receiveEvent(t *testing.T, events <-chan PressureEvent) PressureEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a pressure event")
+	}
+	return PressureEvent{}
+}
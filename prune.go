@@ -0,0 +1,75 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PruneStopped 删除 List 返回的容器中已经退出（exited）超过 olderThan 的容器，返回被删除的容器名称，
+// 用于清理进程崩溃后没有被删除的容器
+//
+// 退出的时间以容器的 State.FinishedAt 为准，删除前会重新检查容器的状态，并且不会强制删除，
+// 因此运行中的容器（包括检查之后被重新启动的容器）不会被删除。
+// 开启 WithDryRun 时只记录将要删除的容器，返回的名称为将要删除的容器。
+// 单个容器删除失败不会中断清理，所有失败的原因会合并后返回。
+func (dc *DockerContainer) PruneStopped(ctx context.Context, olderThan time.Duration) (_ []string, err error) {
+	defer wrapOp("prune_stopped", "", &err)
+
+	infos, err := dc.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var (
+		removed []string
+		errs    []error
+	)
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if info.State != "exited" {
+			continue
+		}
+
+		ok, err := dc.exitedBefore(ctx, info.Name, cutoff)
+		if err == nil && ok {
+			err = dc.Remove(info.Name)
+		}
+		if errors.Is(err, ErrContainerNotFound) || errors.Is(err, ErrContainerRunning) {
+			// 容器已经被删除或者重新启动
+			continue
+		}
+		if err != nil {
+			dc.logger.Errorf("Failed to prune container %s: %v", info.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			removed = append(removed, info.Name)
+		}
+	}
+	if len(removed) > 0 && !dc.dryRun {
+		dc.logger.Infof("Pruned %d stopped containers: %v", len(removed), removed)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// exitedBefore 返回容器 name 当前是否处于退出状态，并且退出时间早于 cutoff
+func (dc *DockerContainer) exitedBefore(ctx context.Context, name string, cutoff time.Time) (bool, error) {
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	if containerInspect.State == nil || containerInspect.State.Status != "exited" {
+		return false, nil
+	}
+	finishedAt, err := time.Parse(time.RFC3339Nano, containerInspect.State.FinishedAt)
+	if err != nil {
+		return false, fmt.Errorf("invalid finish time %q of container %s: %w", containerInspect.State.FinishedAt, name, err)
+	}
+	return finishedAt.Before(cutoff), nil
+}
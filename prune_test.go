@@ -0,0 +1,56 @@
+package container
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPruneStopped(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	for _, name := range []string{"old", "recent", "running"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatalf("Run(%s) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"old", "recent"} {
+		if err := dc.Stop(name); err != nil {
+			t.Fatalf("Stop(%s) error = %v", name, err)
+		}
+	}
+	old := api.container("old")
+	api.mu.Lock()
+	old.json.State.FinishedAt = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	api.mu.Unlock()
+
+	// 开启 dry-run 时只返回将要删除的容器
+	removed, err := newTestContainer(api, WithDryRun(true)).PruneStopped(context.Background(), time.Hour)
+	if err != nil || !reflect.DeepEqual(removed, []string{"old"}) {
+		t.Fatalf("dry-run PruneStopped() = %v, %v, want [old]", removed, err)
+	}
+	if api.container("old") == nil {
+		t.Fatal("container old should not be removed in dry-run mode")
+	}
+
+	removed, err = dc.PruneStopped(context.Background(), time.Hour)
+	if err != nil || !reflect.DeepEqual(removed, []string{"old"}) {
+		t.Fatalf("PruneStopped() = %v, %v, want [old]", removed, err)
+	}
+	if api.container("old") != nil {
+		t.Fatal("container old should be removed")
+	}
+	if api.container("recent") == nil || api.container("running") == nil {
+		t.Fatal("recently stopped and running containers should be kept")
+	}
+
+	// olderThan 为 0 时删除所有退出的容器，运行中的容器仍然保留
+	removed, err = dc.PruneStopped(context.Background(), 0)
+	if err != nil || !reflect.DeepEqual(removed, []string{"recent"}) {
+		t.Fatalf("PruneStopped(0) = %v, %v, want [recent]", removed, err)
+	}
+	if api.container("running") == nil {
+		t.Fatal("running container should never be pruned")
+	}
+}
@@ -0,0 +1,158 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// quota 是 SetQuota 调整后的配额，单位为字节
+type quota struct {
+	// BaseStorage 用户可用的系统盘大小，对应 base_storage 标签
+	BaseStorage int64 `json:"base_storage"`
+	// Ballast /ballast 的最大大小，对应 ballast 标签
+	Ballast int64 `json:"ballast"`
+}
+
+// WithQuotaDir 设置在宿主机上保存 SetQuota 配额的目录，每个容器一个以容器 ID 命名的文件，默认只保存在内存中
+//
+// 容器的标签在创建后无法修改，而容器内的文件可以被容器内的用户改写，把 /ballast 的上限改为 0 就能绕过保护，
+// 因此配额只保存在宿主机上，旧版本写入容器内 /.ballast-quota 的配额不再读取。
+// 没有设置时进程重启后配额丢失，/ballast 的上限恢复为 ballast 标签中记录的大小。
+func WithQuotaDir(dir string) Option {
+	return func(dc *DockerContainer) {
+		dc.quotaDir = dir
+	}
+}
+
+// SetQuota 将容器用户可用的系统盘大小调整为 newSize 字节，不需要重新创建容器
+//
+// Docker 不支持修改已经创建的容器的 storage-opt size：overlay2（xfs pquota）、devicemapper、btrfs、zfs
+// 都只能在创建时设置。overlay2 的 project quota 可以在宿主机上通过 xfs_quota 修改，但不在本包的能力范围内。
+// 因此 SetQuota 只在 threshold 标签记录的实际限制以内重新分配空间：新的 /ballast 上限为 threshold - newSize，
+// 记录在宿主机上（见 WithQuotaDir），Stop 的计算仍然使用不变的 threshold。
+//
+// newSize 超过 threshold 时返回 ErrRecreateRequired，只能重新创建一个更大的容器。
+// 容器正在运行时立即缩小或者扩大 /ballast；没有运行时只记录新的配额，在下一次 Start 时生效。
+func (dc *DockerContainer) SetQuota(ctx context.Context, name string, newSize int64) (err error) {
+	defer wrapOp("set_quota", name, &err)
+	defer dc.lock(name)()
+
+	if newSize <= 0 {
+		return fmt.Errorf("failed to set quota of container %s: %w: invalid size %d, must be positive", name, ErrInvalidArgument, newSize)
+	}
+
+	containerInspect, err := dc.inspectContainer(ctx, name)
+	if err != nil {
+		return err
+	}
+	limit, limited, err := dc.storageLimit(name, containerInspect.Config.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to check container %s: %w", name, err)
+	}
+	if !limited {
+		return fmt.Errorf("container %s has no storage limit", name)
+	}
+	if newSize > limit {
+		return fmt.Errorf("failed to set quota of container %s to %d bytes: %w: storage-opt size is %d bytes", name, newSize, ErrRecreateRequired, limit)
+	}
+
+	q := quota{BaseStorage: newSize, Ballast: limit - newSize}
+	if dc.dryRunf("Would set quota of container %s to %d bytes with %d bytes of ballast", name, q.BaseStorage, q.Ballast) {
+		return nil
+	}
+	if err := dc.saveQuota(containerInspect.ID, q); err != nil {
+		return fmt.Errorf("failed to set quota of container %s: %w", name, err)
+	}
+	dc.logger.Infof("Set quota of container %s to %d bytes with %d bytes of ballast", name, q.BaseStorage, q.Ballast)
+
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		return nil
+	}
+	if _, err := dc.reconcile(ctx, name); err != nil {
+		return fmt.Errorf("failed to apply quota of container %s: %w", name, err)
+	}
+	return nil
+}
+
+// quotaFile 返回 WithQuotaDir 中容器 containerID 的配额文件
+func (dc *DockerContainer) quotaFile(containerID string) string {
+	return filepath.Join(dc.quotaDir, containerID+".json")
+}
+
+// saveQuota 记录容器 containerID 的配额，设置了 WithQuotaDir 时先写入文件，写入失败时不修改内存中的配额
+func (dc *DockerContainer) saveQuota(containerID string, q quota) error {
+	if dc.quotaDir != "" {
+		data, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dc.quotaDir, 0o700); err != nil {
+			return fmt.Errorf("failed to create quota directory: %w", err)
+		}
+		// 先写入临时文件再重命名，避免进程退出时留下不完整的文件
+		tmp := dc.quotaFile(containerID) + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write quota file: %w", err)
+		}
+		if err := os.Rename(tmp, dc.quotaFile(containerID)); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("failed to write quota file: %w", err)
+		}
+	}
+	dc.quotas.Store(containerID, q)
+	return nil
+}
+
+// loadQuota 返回容器 containerID 的配额，没有调用过 SetQuota 时返回 false
+//
+// 配额文件无法读取或者解析时当作没有配额，只记录日志，不影响 Start、Stop 等操作。
+func (dc *DockerContainer) loadQuota(containerID string) (quota, bool) {
+	if q, ok := dc.quotas.Load(containerID); ok {
+		return q.(quota), true
+	}
+	if dc.quotaDir == "" {
+		return quota{}, false
+	}
+
+	data, err := os.ReadFile(dc.quotaFile(containerID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return quota{}, false
+	}
+	var q quota
+	if err == nil {
+		err = json.Unmarshal(data, &q)
+	}
+	if err != nil {
+		dc.logger.Errorf("Failed to load quota of container %s, using ballast label: %v", dc.containerName(containerID), err)
+		return quota{}, false
+	}
+	dc.quotas.Store(containerID, q)
+	return q, true
+}
+
+// forgetQuota 删除容器 containerID 的配额，容器被删除后调用
+func (dc *DockerContainer) forgetQuota(containerID string) {
+	dc.quotas.Delete(containerID)
+	if dc.quotaDir == "" {
+		return
+	}
+	if err := os.Remove(dc.quotaFile(containerID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		dc.logger.Errorf("Failed to remove quota file of container %s: %v", dc.containerName(containerID), err)
+	}
+}
+
+// ballastCeilingOf 返回容器 /ballast 的最大大小，调用过 SetQuota 时使用记录的配额，否则使用 ballast 标签
+//
+// 配额不会超过 threshold 标签记录的实际限制，WithQuotaDir 中的文件被修改时同样如此。
+func (dc *DockerContainer) ballastCeilingOf(containerID string, limits storageLimits) int64 {
+	q, ok := dc.loadQuota(containerID)
+	if !ok {
+		return limits.ceiling
+	}
+	return max(0, min(q.Ballast, limits.limit))
+}
@@ -0,0 +1,181 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetQuota(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+
+	// 20GB 升级到 23GB，/ballast 的上限从 5GB 变为 2GB
+	if err := dc.SetQuota(ctx, "test", 23*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 2*gigabyte {
+		t.Fatalf("ballast = %d, want %d", c.ballast, 2*gigabyte)
+	}
+	if q, ok := dc.loadQuota(c.json.ID); !ok || q != (quota{BaseStorage: 23 * gigabyte, Ballast: 2 * gigabyte}) {
+		t.Fatalf("quota = %+v, %v", q, ok)
+	}
+	if len(c.files) != 0 {
+		t.Fatalf("quota should not be written into the container, got %v", c.files)
+	}
+	// Start 和 GrowBallast 不会超过新的上限
+	if err := dc.Restart(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.GrowBallast(ctx, "test", 5*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 2*gigabyte {
+		t.Fatalf("ballast after Restart = %d, want %d", c.ballast, 2*gigabyte)
+	}
+
+	// 停止的容器只记录配额，下次 Start 时生效
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.SetQuota(ctx, "test", 20*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 2*gigabyte {
+		t.Fatalf("ballast of stopped container = %d, want %d", c.ballast, 2*gigabyte)
+	}
+	if err := dc.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 5*gigabyte {
+		t.Fatalf("ballast after Start = %d, want %d", c.ballast, 5*gigabyte)
+	}
+
+	// 超过 storage-opt size 时只能重新创建
+	err := dc.SetQuota(ctx, "test", 30*gigabyte)
+	if !errors.Is(err, ErrRecreateRequired) || KindOf(err) != KindConflict {
+		t.Fatalf("SetQuota() error = %v, want ErrRecreateRequired", err)
+	}
+	if err := dc.SetQuota(ctx, "test", 0); !errors.Is(err, ErrInvalidArgument) || KindOf(err) != KindInvalid {
+		t.Fatalf("expected ErrInvalidArgument for a non-positive size, got %v", err)
+	}
+}
+
+func TestQuotaIgnoresContainerFile(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+
+	// 旧版本使用的 /.ballast-quota 在容器的可写层中，容器内的用户可以随意修改
+	for _, content := range []string{`{"base_storage":25000000000,"ballast":0}`, "garbage"} {
+		api.mu.Lock()
+		c.files = map[string][]byte{"/.ballast-quota": []byte(content)}
+		api.mu.Unlock()
+
+		if err := dc.Restart(ctx, "test"); err != nil {
+			t.Fatal(err)
+		}
+		if c.ballast != 5*gigabyte {
+			t.Fatalf("ballast with tampered file %q = %d, want %d", content, c.ballast, 5*gigabyte)
+		}
+	}
+}
+
+func TestQuotaDir(t *testing.T) {
+	api := newFakeDockerAPI()
+	dir := t.TempDir()
+	dc := newTestContainer(api, WithQuotaDir(dir))
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	if err := dc.SetQuota(ctx, "test", 23*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, c.json.ID+".json")
+	if data, err := os.ReadFile(file); err != nil || string(data) != `{"base_storage":23000000000,"ballast":2000000000}` {
+		t.Fatalf("quota file = %s, %v", data, err)
+	}
+
+	// 新的实例从目录中读取配额
+	other := newTestContainer(api, WithQuotaDir(dir))
+	if got := other.ballastCeilingOf(c.json.ID, storageLimits{limit: 25 * gigabyte, ceiling: 5 * gigabyte}); got != 2*gigabyte {
+		t.Fatalf("ceiling = %d, want %d", got, 2*gigabyte)
+	}
+
+	// 无法解析的文件当作没有配额，超过 threshold 的配额被限制在 threshold 以内
+	limits := storageLimits{limit: 25 * gigabyte, ceiling: 5 * gigabyte}
+	for content, want := range map[string]int64{
+		"garbage":                  5 * gigabyte,
+		`{"ballast":-1}`:           0,
+		`{"ballast":100000000000}`: 25 * gigabyte,
+		`{"ballast":3000000000}`:   3 * gigabyte,
+	} {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fresh := newTestContainer(api, WithQuotaDir(dir))
+		if got := fresh.ballastCeilingOf(c.json.ID, limits); got != want {
+			t.Errorf("ceiling with quota file %q = %d, want %d", content, got, want)
+		}
+	}
+
+	// 删除容器时同时删除配额文件
+	if err := dc.ForceRemove("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("quota file should be removed, got %v", err)
+	}
+}
+
+func TestQuotaForgottenOnReplace(t *testing.T) {
+	api := newFakeDockerAPI()
+	dir := t.TempDir()
+	dc := newTestContainer(api, WithQuotaDir(dir))
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	old := api.container("test").json.ID
+	if err := dc.SetQuota(ctx, "test", 23*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+
+	// ConflictReplace 删除同名容器时同时删除它的配额
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", OnConflict: ConflictReplace}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, old+".json")); !os.IsNotExist(err) {
+		t.Fatalf("quota file of the replaced container should be removed, got %v", err)
+	}
+	if _, ok := dc.loadQuota(old); ok {
+		t.Fatal("quota of the replaced container should be forgotten")
+	}
+
+	// 启动失败后删除容器时同样删除配额
+	api.startErr = errors.New("OCI runtime create failed")
+	next := fmt.Sprintf("id-%d", api.nextID+1)
+	if err := dc.saveQuota(next, quota{BaseStorage: 20 * gigabyte, Ballast: gigabyte}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.Run("failed"); err == nil {
+		t.Fatal("expected error when container fails to start")
+	}
+	if _, err := os.Stat(filepath.Join(dir, next+".json")); !os.IsNotExist(err) {
+		t.Fatalf("quota file of the failed container should be removed, got %v", err)
+	}
+}
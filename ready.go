@@ -0,0 +1,82 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultReadyTimeout = 30 * time.Second
+
+	readyPollInterval = 100 * time.Millisecond
+)
+
+// WithReadyTimeout 设置启动容器后等待容器就绪的最长时间，默认为 30s
+func WithReadyTimeout(timeout time.Duration) Option {
+	return func(dc *DockerContainer) {
+		dc.readyTimeout = timeout
+	}
+}
+
+// WithReadyProbe 设置等待容器就绪时是否还要在容器内成功执行一次 true，默认关闭
+//
+// 在负载较高的主机上，容器进入 running 状态后文件系统可能还没有准备好，开启后可以避免第一次 exec 失败。
+func WithReadyProbe(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.readyProbe = enabled
+	}
+}
+
+// waitReady 轮询容器状态直到容器处于运行状态，开启 readyProbe 时还要等到 exec 成功，
+// 超过 readyTimeout 或者容器已经退出时返回错误
+func (dc *DockerContainer) waitReady(ctx context.Context, containerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, dc.readyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ready, err := dc.isReady(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container %s is not ready after %s: %w", containerID, dc.readyTimeout, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// isReady 检查一次容器是否就绪，容器已经退出时返回错误
+func (dc *DockerContainer) isReady(ctx context.Context, containerID string) (bool, error) {
+	containerInspect, err := dc.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container %s: %w", containerID, wrapNotFound(err))
+	}
+
+	state := containerInspect.State
+	if state == nil {
+		return false, nil
+	}
+	if state.Status == "exited" || state.Status == "dead" {
+		return false, fmt.Errorf("container %s exited with code %d before ready: %w", containerID, state.ExitCode, ErrContainerNotRunning)
+	}
+	if !state.Running {
+		return false, nil
+	}
+
+	if dc.readyProbe {
+		if _, err := dc.executeCommand(ctx, containerID, []string{"true"}); err != nil {
+			debugf(dc.logger, "Container %s is running but exec is not ready yet: %v", containerID, err)
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunWaitsForReady(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.startingInspects = 3
+	dc := newTestContainer(api, WithReadyProbe(true))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if api.container("test").ballast != 5*gigabyte {
+		t.Fatalf("expected /ballast to be created, got %d", api.container("test").ballast)
+	}
+
+	var probed bool
+	for _, cmd := range api.commands {
+		if strings.Join(cmd, " ") == "true" {
+			probed = true
+		}
+		if strings.Contains(strings.Join(cmd, " "), "fallocate") && !probed {
+			t.Fatal("fallocate ran before the ready probe")
+		}
+	}
+	if !probed {
+		t.Fatal("expected ready probe to run")
+	}
+}
+
+func TestRunReadyTimeout(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.startingInspects = 1000
+	dc := newTestContainer(api, WithReadyTimeout(50*time.Millisecond))
+
+	if _, err := dc.Run("test"); err == nil {
+		t.Fatal("expected error when container never becomes ready")
+	}
+	if _, ok := api.containers["test"]; ok {
+		t.Fatal("container should be removed after ready timeout")
+	}
+}
+
+func TestIsReadyExited(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.json.State.Running = false
+	c.json.State.Status = "exited"
+
+	if _, err := dc.isReady(context.Background(), c.json.ID); !errors.Is(err, ErrContainerNotRunning) {
+		t.Fatalf("expected ErrContainerNotRunning, got %v", err)
+	}
+}
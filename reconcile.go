@@ -0,0 +1,123 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ReconcileResult 描述一次 Reconcile 对 /ballast 做出的修改
+type ReconcileResult struct {
+	Name string
+	// OldSize 和 NewSize 调整前后 /ballast 的大小，/ballast 不存在时为 0
+	OldSize int64
+	NewSize int64
+	// Ceiling ballast 标签中记录的 /ballast 大小，调用过 SetQuota 时为调整后的大小
+	Ceiling int64
+	// Skipped 为 true 表示容器没有运行，没有进行检查
+	Skipped bool
+	// Err ReconcileAll 中该容器调整失败的原因
+	Err error
+}
+
+// Changed 返回 /ballast 的大小是否被修改
+func (r ReconcileResult) Changed() bool {
+	return r.OldSize != r.NewSize
+}
+
+// Reconcile 将容器的 /ballast 恢复为 ballast 标签中记录的大小，调用过 SetQuota 时使用调整后的大小
+//
+// /ballast 大于标签记录的大小时会被缩小；缺失或者偏小时按照 GrowBallast 的规则扩大，
+// 不会占用 targetFree 以内的剩余空间，因此调整后仍然可能小于标签记录的大小。
+// 容器没有运行或者被暂停时返回 ErrContainerNotRunning。
+func (dc *DockerContainer) Reconcile(ctx context.Context, name string) (_ ReconcileResult, err error) {
+	defer wrapOp("reconcile", name, &err)
+	defer dc.lock(name)()
+	return dc.reconcile(ctx, name)
+}
+
+// reconcile 是 Reconcile 不加锁的实现，调用方需要持有 name 对应的锁
+func (dc *DockerContainer) reconcile(ctx context.Context, name string) (ReconcileResult, error) {
+	result := ReconcileResult{Name: name}
+
+	containerInspect, limits, err := dc.inspectLimits(ctx, name)
+	if err != nil {
+		return result, fmt.Errorf("failed to check container %s: %w", name, err)
+	}
+	if !limits.limited {
+		return result, fmt.Errorf("container %s has no storage limit", name)
+	}
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		return result, fmt.Errorf("failed to reconcile container %s: %w", name, ErrContainerNotRunning)
+	}
+	result.Ceiling = dc.ballastCeilingOf(containerInspect.ID, limits)
+	path := limits.path
+
+	result.OldSize, err = statBallast(dc, ctx, containerInspect.ID, path)
+	if err != nil && !errors.Is(err, ErrBallastNotFound) {
+		return result, fmt.Errorf("failed to get ballast size of container %s: %w", name, err)
+	}
+	result.NewSize = result.OldSize
+
+	switch {
+	case result.OldSize > result.Ceiling:
+		if dc.dryRunf("Would shrink %s of container %s from %d to %d bytes", path, name, result.OldSize, result.Ceiling) {
+			result.NewSize = result.Ceiling
+			return result, nil
+		}
+		_, result.NewSize, err = shrinkBallast(dc, ctx, containerInspect.ID, path, result.OldSize-result.Ceiling)
+		if err != nil {
+			return result, fmt.Errorf("failed to shrink ballast file of container %s: %w", name, err)
+		}
+	case result.OldSize < result.Ceiling:
+		if err := dc.growBallastTo(ctx, name, containerInspect.ID, limits, result.Ceiling); err != nil {
+			return result, err
+		}
+		if dc.dryRun {
+			return result, nil
+		}
+		result.NewSize, err = statBallast(dc, ctx, containerInspect.ID, path)
+		if err != nil && !errors.Is(err, ErrBallastNotFound) {
+			return result, fmt.Errorf("failed to get ballast size of container %s: %w", name, err)
+		}
+	default:
+		return result, nil
+	}
+
+	dc.logger.Infof("Reconciled %s of container %s from %d to %d bytes", path, name, result.OldSize, result.NewSize)
+	return result, nil
+}
+
+// ReconcileAll 对 List 返回的所有运行中的容器执行 Reconcile，单个容器的错误记录在 ReconcileResult.Err 中，
+// 没有运行的容器会被跳过
+func (dc *DockerContainer) ReconcileAll(ctx context.Context) (_ []ReconcileResult, err error) {
+	defer wrapOp("reconcile_all", "", &err)
+
+	infos, err := dc.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ReconcileResult, 0, len(infos))
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if info.State != "running" {
+			results = append(results, ReconcileResult{Name: info.Name, Skipped: true})
+			continue
+		}
+
+		result, err := dc.Reconcile(ctx, info.Name)
+		if errors.Is(err, ErrContainerNotRunning) {
+			result.Skipped = true
+			err = nil
+		}
+		if err != nil {
+			dc.logger.Errorf("Failed to reconcile container %s: %v", info.Name, err)
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
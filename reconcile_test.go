@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReconcileAll(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	for _, name := range []string{"missing", "oversized", "ok", "stopped"} {
+		if _, err := dc.Run(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api.container("missing").ballast = -1
+	api.container("oversized").ballast = 6 * gigabyte
+	if err := dc.Stop("stopped"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := dc.ReconcileAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]ReconcileResult)
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("reconcile %s: %v", r.Name, r.Err)
+		}
+		byName[r.Name] = r
+	}
+
+	if r := byName["missing"]; !r.Changed() || r.OldSize != 0 || r.NewSize != 5*gigabyte {
+		t.Errorf("missing: %+v", r)
+	}
+	if r := byName["oversized"]; !r.Changed() || r.NewSize != 5*gigabyte {
+		t.Errorf("oversized: %+v", r)
+	}
+	if r := byName["ok"]; r.Changed() || r.Skipped {
+		t.Errorf("ok: %+v", r)
+	}
+	if r := byName["stopped"]; !r.Skipped {
+		t.Errorf("stopped: %+v", r)
+	}
+	for _, name := range []string{"missing", "oversized", "ok"} {
+		if got := api.container(name).ballast; got != 5*gigabyte {
+			t.Errorf("%s: /ballast = %d, want %d", name, got, int64(5*gigabyte))
+		}
+	}
+}
+
+func TestReconcileBoundedByFreeSpace(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.ballast = -1
+	// 剩余 3GB，扩大 /ballast 时需要保留 1GB
+	c.dataUsed = 22 * gigabyte
+
+	result, err := dc.Reconcile(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NewSize != 2*gigabyte {
+		t.Fatalf("NewSize = %d, want %d", result.NewSize, int64(2*gigabyte))
+	}
+}
+
+func TestReconcileRoundTrips(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	api.container("test").ballast = -1
+
+	// 扩大 /ballast 时复用 Reconcile 已经读取的标签和最大大小，只 inspect 一次容器
+	api.inspects = 0
+	result, err := dc.Reconcile(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Changed() || api.inspects != 1 {
+		t.Fatalf("Reconcile = %+v and inspected the container %d times, want 1", result, api.inspects)
+	}
+
+	api.inspects = 0
+	if _, err := dc.StopWithResult(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if api.inspects != 1 {
+		t.Fatalf("Stop inspected the container %d times, want 1", api.inspects)
+	}
+}
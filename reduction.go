@@ -0,0 +1,90 @@
+package container
+
+import "fmt"
+
+// ReductionPolicy 计算 Stop 调整 /ballast 时每一轮减少的字节数
+//
+// ballast 是 /ballast 当前的大小，shortfall 是剩余空间距离 WithTargetFreeSpace 的缺口。
+// 每一轮减少之后会重新执行 df，剩余空间仍然没有大于目标时按照新的缺口再次调用 Reduction，直到 /ballast 被完全删除。
+// 返回值小于等于 0 时按照 DeficitReduction 计算，避免调整无法结束。
+type ReductionPolicy interface {
+	Reduction(ballast, shortfall int64) int64
+}
+
+// FixedReduction 每一轮固定减少 step 字节，step 必须为正数
+//
+// 减少的大小与缺口无关，step 较小时适合保守的部署，但是用户快速写满磁盘时需要多轮才能释放足够的空间。
+func FixedReduction(step int64) ReductionPolicy {
+	return fixedReduction{step: step}
+}
+
+// ProportionalReduction 每一轮减少 /ballast 当前大小的 fraction，fraction 的范围为 (0, 1]
+//
+// /ballast 较大时每一轮释放的空间较多，适合激进的部署，/ballast 越小减少得越慢。
+func ProportionalReduction(fraction float64) ReductionPolicy {
+	return proportionalReduction{fraction: fraction}
+}
+
+// DeficitReduction 每一轮减少缺口加上 100MB 的余量，通常一轮就能让剩余空间超过目标，是默认的策略
+func DeficitReduction() ReductionPolicy {
+	return deficitReduction{}
+}
+
+// WithReductionPolicy 设置 Stop 调整 /ballast 时每一轮减少的大小，默认为 DeficitReduction
+func WithReductionPolicy(policy ReductionPolicy) Option {
+	return func(dc *DockerContainer) {
+		dc.reductionPolicy = policy
+	}
+}
+
+type fixedReduction struct {
+	step int64
+}
+
+func (p fixedReduction) Reduction(_, _ int64) int64 {
+	return p.step
+}
+
+type proportionalReduction struct {
+	fraction float64
+}
+
+func (p proportionalReduction) Reduction(ballast, _ int64) int64 {
+	reduction := int64(float64(ballast) * p.fraction)
+	if reduction < 1 && ballast > 0 {
+		// /ballast 很小时至少减少 1 字节，保证每一轮都有进展
+		return 1
+	}
+	return reduction
+}
+
+type deficitReduction struct{}
+
+func (deficitReduction) Reduction(_, shortfall int64) int64 {
+	return shortfall + reductionCushion
+}
+
+// validateReductionPolicy 检查内置策略的参数
+func validateReductionPolicy(policy ReductionPolicy) error {
+	switch p := policy.(type) {
+	case nil:
+		return fmt.Errorf("invalid reduction policy, must not be nil")
+	case fixedReduction:
+		if p.step <= 0 {
+			return fmt.Errorf("invalid fixed reduction step %d bytes, must be positive", p.step)
+		}
+	case proportionalReduction:
+		if p.fraction <= 0 || p.fraction > 1 {
+			return fmt.Errorf("invalid proportional reduction fraction %g, must be in (0, 1]", p.fraction)
+		}
+	}
+	return nil
+}
+
+// reduction 使用 WithReductionPolicy 设置的策略计算 /ballast 这一轮减少的字节数
+func (dc *DockerContainer) reduction(ballast, shortfall int64) int64 {
+	if reduction := dc.reductionPolicy.Reduction(ballast, shortfall); reduction > 0 {
+		return reduction
+	}
+	return deficitReduction{}.Reduction(ballast, shortfall)
+}
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReductionPolicies(t *testing.T) {
+	const (
+		ballast   = 5 * gigabyte
+		shortfall = 200 * megabyte
+	)
+	tests := []struct {
+		name   string
+		policy ReductionPolicy
+		want   int64
+	}{
+		{name: "fixed", policy: FixedReduction(500 * megabyte), want: 500 * megabyte},
+		{name: "proportional", policy: ProportionalReduction(0.25), want: 1250 * megabyte},
+		{name: "deficit", policy: DeficitReduction(), want: shortfall + reductionCushion},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Reduction(ballast, shortfall); got != tt.want {
+			t.Errorf("%s: Reduction(%d, %d) = %d, want %d", tt.name, ballast, shortfall, got, tt.want)
+		}
+	}
+
+	if got := ProportionalReduction(0.5).Reduction(1, shortfall); got != 1 {
+		t.Errorf("proportional reduction of tiny ballast = %d, want 1", got)
+	}
+}
+
+type zeroReduction struct{}
+
+func (zeroReduction) Reduction(_, _ int64) int64 { return 0 }
+
+func TestReductionFallsBackToDeficit(t *testing.T) {
+	dc := newTestContainer(newFakeDockerAPI(), WithReductionPolicy(zeroReduction{}))
+	if got, want := dc.reduction(gigabyte, 200*megabyte), int64(200*megabyte+reductionCushion); got != want {
+		t.Errorf("reduction() = %d, want %d", got, want)
+	}
+}
+
+func TestValidateReductionPolicy(t *testing.T) {
+	tests := []struct {
+		policy  ReductionPolicy
+		wantErr bool
+	}{
+		{policy: DeficitReduction()},
+		{policy: FixedReduction(megabyte)},
+		{policy: ProportionalReduction(1)},
+		{policy: nil, wantErr: true},
+		{policy: FixedReduction(0), wantErr: true},
+		{policy: ProportionalReduction(0), wantErr: true},
+		{policy: ProportionalReduction(1.5), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewWithClient(newFakeDockerAPI(), WithReductionPolicy(tt.policy))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("WithReductionPolicy(%#v) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStopWithReductionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ReductionPolicy
+		want   []int64
+	}{
+		{name: "fixed", policy: FixedReduction(100 * megabyte), want: []int64{4900 * megabyte, 4800 * megabyte, 4700 * megabyte}},
+		{name: "proportional", policy: ProportionalReduction(0.1), want: []int64{4500 * megabyte}},
+		{name: "deficit", policy: DeficitReduction(), want: []int64{4700 * megabyte}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int64
+			api := newFakeDockerAPI()
+			dc := newTestContainer(api, WithReductionPolicy(tt.policy), WithOnAdjust(func(_ string, _, newSize int64) {
+				sizes = append(sizes, newSize)
+			}))
+			if _, err := dc.Run("test"); err != nil {
+				t.Fatal(err)
+			}
+			// 剩余空间为 800MB，距离默认的目标 1GB 缺口为 200MB
+			api.container("test").dataUsed = int64(defaultStorageSize) - 800*megabyte
+
+			if err := dc.Stop("test"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("ballast sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
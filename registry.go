@@ -0,0 +1,69 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/errdefs"
+)
+
+// CredentialHelper 返回拉取镜像 ref 时使用的认证信息，例如从 docker-credential-* 或者密钥管理服务中读取
+type CredentialHelper func(ctx context.Context, ref string) (registry.AuthConfig, error)
+
+// WithRegistryCredentials 使用用户名和密码拉取私有仓库中的镜像，会覆盖 WithRegistryAuth 和 WithCredentialHelper
+func WithRegistryCredentials(username, password string) Option {
+	return WithCredentialHelper(func(context.Context, string) (registry.AuthConfig, error) {
+		return registry.AuthConfig{Username: username, Password: password}, nil
+	})
+}
+
+// WithCredentialHelper 设置拉取镜像时获取认证信息的函数，每次拉取镜像时都会调用，会覆盖 WithRegistryAuth
+func WithCredentialHelper(helper CredentialHelper) Option {
+	return func(dc *DockerContainer) {
+		dc.credentialHelper = helper
+	}
+}
+
+// registryAuthFor 返回拉取 ref 时使用的 base64 编码的认证信息，没有配置时返回空字符串
+func (dc *DockerContainer) registryAuthFor(ctx context.Context, ref string) (string, error) {
+	if dc.credentialHelper == nil {
+		return dc.registryAuth, nil
+	}
+	authConfig, err := dc.credentialHelper(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to get credentials: %v", ErrRegistryAuth, err)
+	}
+	auth, err := registry.EncodeAuthConfig(authConfig)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to encode credentials: %v", ErrRegistryAuth, err)
+	}
+	return auth, nil
+}
+
+// authFailureMessages 是 Docker daemon 和镜像仓库在认证失败时返回的错误信息，
+// daemon 通常以 500 返回这类错误，只能通过错误信息判断
+var authFailureMessages = []string{
+	"unauthorized",
+	"authentication required",
+	"no basic auth credentials",
+	"incorrect username or password",
+}
+
+// wrapAuthError 将拉取镜像时的认证失败转换为 ErrRegistryAuth，镜像不存在等其他错误原样返回
+func wrapAuthError(err error) error {
+	if err == nil || errdefs.IsNotFound(err) {
+		return err
+	}
+	if errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) {
+		return fmt.Errorf("%w: %v", ErrRegistryAuth, err)
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range authFailureMessages {
+		if strings.Contains(msg, m) {
+			return fmt.Errorf("%w: %v", ErrRegistryAuth, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/errdefs"
+)
+
+func TestRegistryCredentials(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithRegistryCredentials("user", "secret"))
+	if err := dc.PullImage(context.Background(), "registry.example.com/app:1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	authConfig, err := registry.DecodeAuthConfig(api.pullAuth[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authConfig.Username != "user" || authConfig.Password != "secret" {
+		t.Fatalf("auth config = %+v", authConfig)
+	}
+
+	var refs []string
+	dc = newTestContainer(api, WithCredentialHelper(func(_ context.Context, ref string) (registry.AuthConfig, error) {
+		refs = append(refs, ref)
+		return registry.AuthConfig{}, errors.New("helper not installed")
+	}))
+	err = dc.PullImage(context.Background(), "registry.example.com/app:2", nil)
+	if !errors.Is(err, ErrRegistryAuth) || len(refs) != 1 || refs[0] != "registry.example.com/app:2" {
+		t.Fatalf("PullImage() error = %v, helper called with %v", err, refs)
+	}
+}
+
+func TestPullImageAuthFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"unauthorized", errdefs.Unauthorized(errors.New("unauthorized")), KindUnauthorized},
+		{"daemon message", errdefs.System(errors.New("Head \"https://registry.example.com/v2/app/manifests/1\": unauthorized: incorrect username or password")), KindUnauthorized},
+		{"not found", errdefs.NotFound(errors.New("pull access denied for app, repository does not exist or may require 'docker login'")), KindNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeDockerAPI()
+			api.pullErr = tt.err
+			dc := newTestContainer(api)
+
+			err := dc.PullImage(context.Background(), "registry.example.com/app:1", nil)
+			if KindOf(err) != tt.want {
+				t.Fatalf("PullImage() error = %v, kind = %s, want %s", err, KindOf(err), tt.want)
+			}
+			if errors.Is(err, ErrRegistryAuth) != (tt.want == KindUnauthorized) {
+				t.Fatalf("errors.Is(%v, ErrRegistryAuth) = %v", err, errors.Is(err, ErrRegistryAuth))
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// RetryPolicy 描述调用 Docker API 遇到临时错误时的重试策略
+//
+// 第 n 次重试前会等待 BaseDelay * 2^(n-1)，最多不超过 MaxDelay，
+// Jitter 为 0.2 时实际等待时间会在 ±20% 的范围内随机浮动。
+type RetryPolicy struct {
+	// MaxAttempts 最多调用的次数（包括第一次），小于等于 1 时不重试
+	MaxAttempts int
+	// BaseDelay 第一次重试前的等待时间
+	BaseDelay time.Duration
+	// MaxDelay 单次等待时间的上限，为 0 时不限制
+	MaxDelay time.Duration
+	// Jitter 等待时间随机浮动的比例，取值范围为 [0, 1]
+	Jitter float64
+}
+
+// DefaultRetryPolicy 是推荐的重试策略，最多调用 3 次，等待 200ms、400ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+	Jitter:      0.2,
+}
+
+// WithRetry 设置调用 Docker API 遇到临时错误（连接被重置、daemon 返回 5xx）时的重试策略，默认不重试
+//
+// 名称冲突、容器不存在等 4xx 错误不会被重试。日志、镜像拉取和 exec 的输出流不会被重试。
+// 创建容器的响应丢失后重试遇到名称冲突时，如果同名容器是之前的请求创建的（还没有启动，镜像和标签都相同），直接使用该容器。
+func WithRetry(policy RetryPolicy) Option {
+	return func(dc *DockerContainer) {
+		dc.retry = &policy
+	}
+}
+
+// delay 返回第 attempt 次重试前的等待时间，attempt 从 1 开始
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// do 执行 fn，遇到临时错误时按照重试策略重试，ctx 被取消时返回最后一次的错误
+func (r *retryAPI) do(ctx context.Context, op string, fn func() error) error {
+	p := r.policy
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !isTransient(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		d := p.delay(attempt)
+		r.logger.Infof("Docker API %s failed with transient error, retrying in %s (attempt %d/%d): %v", op, d, attempt, p.MaxAttempts, err)
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransient 判断错误是否是可以重试的临时错误
+func isTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errdefs.IsSystem(err), errdefs.IsUnavailable(err), client.IsErrConnectionFailed(err):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return false
+}
+
+// retryAPI 为 DockerAPI 的请求-响应类方法增加重试，流式方法直接调用底层实现
+type retryAPI struct {
+	DockerAPI
+	policy RetryPolicy
+	logger Logger
+}
+
+func (r *retryAPI) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (resp container.CreateResponse, err error) {
+	attempt := 0
+	err = r.do(ctx, "ContainerCreate", func() error {
+		attempt++
+		resp, err = r.DockerAPI.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, containerName)
+		if err != nil && attempt > 1 && errdefs.IsConflict(err) {
+			// 上一次请求可能已经创建了容器，只是响应在连接被重置时丢失，重试时遇到的是自己创建的容器
+			if id, ok := r.createdBefore(ctx, config, containerName); ok {
+				r.logger.Infof("Container %s was created by a previous attempt, reusing %s", containerName, id)
+				resp, err = container.CreateResponse{ID: id}, nil
+			}
+		}
+		return err
+	})
+	return resp, err
+}
+
+// createdBefore 检查同名容器是否是之前的 ContainerCreate 请求创建的：容器还没有启动，镜像和 config 中的标签都相同
+func (r *retryAPI) createdBefore(ctx context.Context, config *container.Config, containerName string) (string, bool) {
+	if containerName == "" || config == nil {
+		return "", false
+	}
+	existing, err := r.DockerAPI.ContainerInspect(ctx, containerName)
+	if err != nil || existing.ContainerJSONBase == nil || existing.State == nil || existing.Config == nil {
+		return "", false
+	}
+	if existing.State.Status != "created" || existing.Config.Image != config.Image {
+		return "", false
+	}
+	for key, value := range config.Labels {
+		if v, ok := existing.Config.Labels[key]; !ok || v != value {
+			return "", false
+		}
+	}
+	return existing.ID, true
+}
+
+func (r *retryAPI) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	return r.do(ctx, "ContainerStart", func() error {
+		return r.DockerAPI.ContainerStart(ctx, containerID, options)
+	})
+}
+
+func (r *retryAPI) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return r.do(ctx, "ContainerStop", func() error {
+		return r.DockerAPI.ContainerStop(ctx, containerID, options)
+	})
+}
+
+func (r *retryAPI) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	return r.do(ctx, "ContainerRemove", func() error {
+		return r.DockerAPI.ContainerRemove(ctx, containerID, options)
+	})
+}
+
+func (r *retryAPI) ContainerPause(ctx context.Context, containerID string) error {
+	return r.do(ctx, "ContainerPause", func() error {
+		return r.DockerAPI.ContainerPause(ctx, containerID)
+	})
+}
+
+func (r *retryAPI) ContainerUnpause(ctx context.Context, containerID string) error {
+	return r.do(ctx, "ContainerUnpause", func() error {
+		return r.DockerAPI.ContainerUnpause(ctx, containerID)
+	})
+}
+
+func (r *retryAPI) ContainerInspect(ctx context.Context, containerID string) (resp types.ContainerJSON, err error) {
+	err = r.do(ctx, "ContainerInspect", func() error {
+		resp, err = r.DockerAPI.ContainerInspect(ctx, containerID)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) ContainerList(ctx context.Context, options container.ListOptions) (resp []types.Container, err error) {
+	err = r.do(ctx, "ContainerList", func() error {
+		resp, err = r.DockerAPI.ContainerList(ctx, options)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (resp types.IDResponse, err error) {
+	err = r.do(ctx, "ContainerExecCreate", func() error {
+		resp, err = r.DockerAPI.ContainerExecCreate(ctx, containerID, options)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) ContainerExecInspect(ctx context.Context, execID string) (resp container.ExecInspect, err error) {
+	err = r.do(ctx, "ContainerExecInspect", func() error {
+		resp, err = r.DockerAPI.ContainerExecInspect(ctx, execID)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) ImageInspectWithRaw(ctx context.Context, imageID string) (resp types.ImageInspect, raw []byte, err error) {
+	err = r.do(ctx, "ImageInspectWithRaw", func() error {
+		resp, raw, err = r.DockerAPI.ImageInspectWithRaw(ctx, imageID)
+		return err
+	})
+	return resp, raw, err
+}
+
+func (r *retryAPI) Info(ctx context.Context) (resp system.Info, err error) {
+	err = r.do(ctx, "Info", func() error {
+		resp, err = r.DockerAPI.Info(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (resp network.Inspect, err error) {
+	err = r.do(ctx, "NetworkInspect", func() error {
+		resp, err = r.DockerAPI.NetworkInspect(ctx, networkID, options)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryAPI) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	return r.do(ctx, "NetworkConnect", func() error {
+		return r.DockerAPI.NetworkConnect(ctx, networkID, containerID, config)
+	})
+}
@@ -0,0 +1,165 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// flakyDockerAPI 在 ContainerCreate 前 failures 次调用时返回 err
+type flakyDockerAPI struct {
+	*fakeDockerAPI
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyDockerAPI) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return container.CreateResponse{}, f.err
+	}
+	return f.fakeDockerAPI.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, containerName)
+}
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+func TestRetryTransientError(t *testing.T) {
+	api := &flakyDockerAPI{fakeDockerAPI: newFakeDockerAPI(), failures: 2, err: errdefs.System(errors.New("internal server error"))}
+	c, err := NewWithClient(api, WithRetry(testRetryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if api.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", api.calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	api := &flakyDockerAPI{fakeDockerAPI: newFakeDockerAPI(), failures: 5, err: fmt.Errorf("read: %w", syscall.ECONNRESET)}
+	c, err := NewWithClient(api, WithRetry(testRetryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Run("test"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected ECONNRESET, got %v", err)
+	}
+	if api.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", api.calls)
+	}
+}
+
+func TestRetrySkipsClientError(t *testing.T) {
+	api := &flakyDockerAPI{fakeDockerAPI: newFakeDockerAPI()}
+	c, err := NewWithClient(api, WithRetry(testRetryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	api.calls = 0
+	if _, err := c.Run("test"); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict, got %v", err)
+	}
+	if api.calls != 1 {
+		t.Fatalf("name conflict should not be retried, got %d calls", api.calls)
+	}
+}
+
+// lostResponseAPI 在 ContainerCreate 前 failures 次调用时创建容器，但是返回连接被重置的错误
+type lostResponseAPI struct {
+	*fakeDockerAPI
+	failures int
+	calls    int
+}
+
+func (f *lostResponseAPI) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.calls++
+	resp, err := f.fakeDockerAPI.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, containerName)
+	if err == nil && f.calls <= f.failures {
+		return container.CreateResponse{}, fmt.Errorf("read: %w", syscall.ECONNRESET)
+	}
+	return resp, err
+}
+
+func TestRetryReusesCreatedContainer(t *testing.T) {
+	api := &lostResponseAPI{fakeDockerAPI: newFakeDockerAPI(), failures: 1}
+	c, err := NewWithClient(api, WithRetry(testRetryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := c.Run("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", api.calls)
+	}
+	created := api.container("test")
+	if id != created.json.ID || !created.json.State.Running {
+		t.Fatalf("Run() = %q, want the running container %s created by the first attempt", id, created.json.ID)
+	}
+	if len(api.containers) != 1 {
+		t.Fatalf("expected 1 container, got %d", len(api.containers))
+	}
+
+	// 重试时遇到的同名容器不是之前的请求创建的，仍然返回 ErrNameConflict
+	flaky := &flakyDockerAPI{fakeDockerAPI: newFakeDockerAPI(), failures: 1, err: fmt.Errorf("read: %w", syscall.ECONNRESET)}
+	if _, err := flaky.fakeDockerAPI.ContainerCreate(context.Background(), &container.Config{Image: defaultImage, Labels: map[string]string{"app": "other"}}, &container.HostConfig{}, &network.NetworkingConfig{}, &ocispec.Platform{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = NewWithClient(flaky, WithRetry(testRetryPolicy)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run("test"); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict, got %v", err)
+	}
+	if flaky.calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", flaky.calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errdefs.System(errors.New("500")), want: true},
+		{err: errdefs.Unavailable(errors.New("503")), want: true},
+		{err: fmt.Errorf("write: %w", syscall.EPIPE), want: true},
+		{err: errdefs.Conflict(errors.New("409")), want: false},
+		{err: errdefs.NotFound(errors.New("404")), want: false},
+		{err: errors.New("unknown"), want: false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		if got := p.delay(attempt + 1); got != want {
+			t.Errorf("delay(%d) = %s, want %s", attempt+1, got, want)
+		}
+	}
+}
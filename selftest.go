@@ -0,0 +1,138 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/system"
+)
+
+// SelfTest 使用的临时容器的大小，足够验证 /ballast 的创建和缩小，不会占用太多磁盘
+const (
+	selfTestStorageSize = 100 * 1000 * 1000
+	selfTestBallastSize = 10 * 1000 * 1000
+	selfTestShrinkBytes = 5 * 1000 * 1000
+)
+
+// SelfTestStep 是 SelfTest 中一个步骤的结果
+type SelfTestStep struct {
+	Name string
+	// Duration 步骤的耗时
+	Duration time.Duration
+	// Err 步骤失败的原因，为 nil 表示通过
+	Err error
+}
+
+// Passed 返回步骤是否通过
+func (s SelfTestStep) Passed() bool {
+	return s.Err == nil
+}
+
+// SelfTestReport 是 SelfTest 的结果，Steps 按照执行的顺序排列，某一步失败后只会继续执行 remove
+type SelfTestReport struct {
+	// Container 临时容器的名称
+	Container string
+	Steps     []SelfTestStep
+	// Duration 所有步骤的总耗时
+	Duration time.Duration
+}
+
+// Passed 返回是否所有步骤都通过
+func (r SelfTestReport) Passed() bool {
+	for _, step := range r.Steps {
+		if !step.Passed() {
+			return false
+		}
+	}
+	return len(r.Steps) > 0
+}
+
+// SelfTest 是部署时的预检，依次检查 Docker 能否连接、是否为 Linux 容器并且存储驱动支持 storage-opt size、
+// 能否使用 WithImage 设置的镜像创建带有 /ballast 的容器以及能否缩小 /ballast，最后删除临时容器
+//
+// 每一步的结果和耗时记录在返回的 SelfTestReport 中，有步骤失败时同时返回第一个失败的原因。
+// 无论在哪一步失败，临时容器都会被删除，删除失败同样记录在 remove 步骤中。
+func (dc *DockerContainer) SelfTest(ctx context.Context) (report SelfTestReport, err error) {
+	name := fmt.Sprintf("ballast-selftest-%d", time.Now().UnixNano())
+	defer wrapOp("self_test", name, &err)
+
+	report.Container = name
+	start := time.Now()
+	step := func(stepName string, fn func() error) error {
+		stepStart := time.Now()
+		stepErr := fn()
+		report.Steps = append(report.Steps, SelfTestStep{Name: stepName, Duration: time.Since(stepStart), Err: stepErr})
+		if stepErr != nil {
+			dc.logger.Errorf("Self test step %s failed: %v", stepName, stepErr)
+			return fmt.Errorf("self test step %s failed: %w", stepName, stepErr)
+		}
+		return nil
+	}
+	defer func() {
+		// ForceRemove 不使用 ctx，ctx 被取消后同样会删除临时容器
+		removeErr := step("remove", func() error {
+			return dc.ForceRemove(name)
+		})
+		if err == nil {
+			err = removeErr
+		}
+		report.Duration = time.Since(start)
+		if err == nil {
+			dc.logger.Infof("Successfully passed self test in %s", report.Duration)
+		}
+	}()
+
+	var info system.Info
+	if err := step("docker", func() (err error) {
+		info, err = dc.cli.Info(ctx)
+		return err
+	}); err != nil {
+		return report, err
+	}
+	if err := step("storage_opt", func() error {
+		if err := checkPlatform(info); err != nil {
+			return err
+		}
+		return checkStorageOptSupport(info)
+	}); err != nil {
+		return report, err
+	}
+	if err := step("image", func() error {
+		return dc.ensureImage(ctx, dc.image)
+	}); err != nil {
+		return report, err
+	}
+	if err := step("run", func() error {
+		_, err := dc.RunWithOptions(ctx, RunOptions{Name: name, StorageSize: selfTestStorageSize, BallastSize: selfTestBallastSize})
+		return err
+	}); err != nil {
+		return report, err
+	}
+	if err := step("ballast", func() error {
+		return dc.checkBallastSize(ctx, name, selfTestBallastSize)
+	}); err != nil {
+		return report, err
+	}
+	if err := step("shrink", func() error {
+		if err := dc.ShrinkBallast(ctx, name, selfTestShrinkBytes); err != nil {
+			return err
+		}
+		return dc.checkBallastSize(ctx, name, selfTestBallastSize-selfTestShrinkBytes)
+	}); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// checkBallastSize 检查容器 name 中 /ballast 的大小是否为 want
+func (dc *DockerContainer) checkBallastSize(ctx context.Context, name string, want int64) error {
+	size, err := dc.BallastSize(ctx, name)
+	if err != nil {
+		return err
+	}
+	if size != want {
+		return fmt.Errorf("ballast size of container %s is %d, want %d", name, size, want)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+)
+
+func TestSelfTest(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+
+	report, err := dc.SelfTest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed() {
+		t.Fatalf("report should pass: %+v", report)
+	}
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+	if got := strings.Join(names, ","); got != "docker,storage_opt,image,run,ballast,shrink,remove" {
+		t.Fatalf("steps = %s", got)
+	}
+	if api.container(report.Container) != nil {
+		t.Fatalf("container %s should be removed", report.Container)
+	}
+}
+
+func TestSelfTestFailure(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.info = system.Info{Driver: "overlay2", DriverStatus: [][2]string{{"Backing Filesystem", "extfs"}}}
+	dc := newTestContainer(api)
+
+	report, err := dc.SelfTest(context.Background())
+	if !errors.Is(err, ErrStorageOptUnsupported) {
+		t.Fatalf("expected ErrStorageOptUnsupported, got %v", err)
+	}
+	if report.Passed() || len(report.Steps) != 3 || report.Steps[1].Passed() || report.Steps[2].Name != "remove" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// /ballast 创建失败时同样删除临时容器
+	api = newFakeDockerAPI()
+	api.execFn = func(_ *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if !strings.Contains(strings.Join(cmd, " "), ballastPath) {
+			return fakeExecResult{}, false
+		}
+		return fakeExecResult{stderr: "fallocate: fallocate failed: Operation not supported\n", exitCode: 1}, true
+	}
+	dc = newTestContainer(api, WithKeepOnFailure(true))
+	report, err = dc.SelfTest(context.Background())
+	if err == nil {
+		t.Fatal("expected the run step to fail")
+	}
+	if step := report.Steps[len(report.Steps)-2]; step.Name != "run" || step.Passed() {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if !report.Steps[len(report.Steps)-1].Passed() || api.container(report.Container) != nil {
+		t.Fatalf("container %s should be removed", report.Container)
+	}
+}
@@ -0,0 +1,239 @@
+// Package server 通过 HTTP 接口对外提供 container.Container 的操作，用于将 ballast 容器的管理作为守护进程运行
+//
+// 支持的接口：
+//
+//	POST   /containers/{name}        创建并启动容器，请求体为可选的 RunRequest
+//	GET    /containers/{name}        返回 container.Info
+//	DELETE /containers/{name}        删除容器，?force=true 时删除正在运行的容器
+//	POST   /containers/{name}/stop   停止容器，返回 StopResponse
+//	POST   /containers/{name}/start  启动容器，返回 StartResponse
+//
+// 每个请求使用 http.Request 的 context，客户端断开连接时会取消正在进行的创建、启动、停止和查询操作。
+// Remove 在 container.Container 中不接收 context，无法被取消。
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/docker/docker/api/types/mount"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+// Server 实现了 http.Handler
+type Server struct {
+	c      container.Container
+	logger container.Logger
+	mux    *http.ServeMux
+}
+
+// Option 用于定制 Server 的行为
+type Option func(s *Server)
+
+// WithLogger 设置请求失败时输出日志使用的 Logger，默认为 container.KlogLogger
+func WithLogger(logger container.Logger) Option {
+	return func(s *Server) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+// New 创建一个操作 c 所管理容器的 Server
+func New(c container.Container, opts ...Option) *Server {
+	s := &Server{
+		c:      c,
+		logger: container.KlogLogger{},
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("POST /containers/{name}", s.run)
+	s.mux.HandleFunc("GET /containers/{name}", s.inspect)
+	s.mux.HandleFunc("DELETE /containers/{name}", s.remove)
+	s.mux.HandleFunc("POST /containers/{name}/stop", s.stop)
+	s.mux.HandleFunc("POST /containers/{name}/start", s.start)
+	return s
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// RunRequest 是 POST /containers/{name} 的请求体，零值字段使用 container.Container 的默认配置
+type RunRequest struct {
+	Image       string            `json:"image,omitempty"`
+	Cmd         []string          `json:"cmd,omitempty"`
+	Entrypoint  []string          `json:"entrypoint,omitempty"`
+	Env         []string          `json:"env,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	StorageSize int64             `json:"storage_size,omitempty"`
+	BallastSize int64             `json:"ballast_size,omitempty"`
+	BallastPath string            `json:"ballast_path,omitempty"`
+	Memory      int64             `json:"memory,omitempty"`
+	NanoCPUs    int64             `json:"nano_cpus,omitempty"`
+	PidsLimit   int64             `json:"pids_limit,omitempty"`
+	// 以下字段与 container.RunOptions 中的同名字段相同，Mounts 使用 Docker API 的格式
+	BallastMount string        `json:"ballast_mount,omitempty"`
+	Mounts       []mount.Mount `json:"mounts,omitempty"`
+	NetworkMode  string        `json:"network_mode,omitempty"`
+	Networks     []string      `json:"networks,omitempty"`
+	DNS          []string      `json:"dns,omitempty"`
+	ExtraHosts   []string      `json:"extra_hosts,omitempty"`
+	// BallastOptional 为 true 时创建 /ballast 失败不会删除容器，响应中的 Warning 为失败的原因
+	BallastOptional bool `json:"ballast_optional,omitempty"`
+}
+
+// RunResponse 是 POST /containers/{name} 的响应，Warning 不为空时容器已经创建并且正在运行，只是 /ballast 创建失败
+type RunResponse struct {
+	ID      string `json:"id"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// StartResponse 是 POST /containers/{name}/start 的响应
+type StartResponse struct {
+	ID string `json:"id"`
+}
+
+// StopResponse 是 POST /containers/{name}/stop 的响应，AdjustError 为调整 /ballast 失败的原因
+type StopResponse struct {
+	container.StopResult
+	AdjustError string `json:"adjust_error,omitempty"`
+}
+
+// ErrorResponse 是请求失败时的响应，Kind 为 container.KindOf 返回的错误类别
+type ErrorResponse struct {
+	Error string         `json:"error"`
+	Kind  container.Kind `json:"kind,omitempty"`
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		err = &container.OpError{Op: "run", Container: name, Kind: container.KindInvalid, Err: err}
+		s.writeError(w, "run", name, statusOf(err), err)
+		return
+	}
+
+	id, err := s.c.RunWithOptions(r.Context(), container.RunOptions{
+		Name:            name,
+		Image:           req.Image,
+		Cmd:             req.Cmd,
+		Entrypoint:      req.Entrypoint,
+		Env:             req.Env,
+		Labels:          req.Labels,
+		StorageSize:     req.StorageSize,
+		BallastSize:     req.BallastSize,
+		BallastPath:     req.BallastPath,
+		Memory:          req.Memory,
+		NanoCPUs:        req.NanoCPUs,
+		PidsLimit:       req.PidsLimit,
+		BallastMount:    req.BallastMount,
+		Mounts:          req.Mounts,
+		NetworkMode:     req.NetworkMode,
+		Networks:        req.Networks,
+		DNS:             req.DNS,
+		ExtraHosts:      req.ExtraHosts,
+		BallastOptional: req.BallastOptional,
+	})
+	var warning *container.BallastWarning
+	if errors.As(err, &warning) {
+		// 容器已经创建，返回 201，避免客户端重试时创建重复的容器
+		s.logger.Errorf("Failed to create ballast for container %s: %v", name, err)
+		writeJSON(w, http.StatusCreated, RunResponse{ID: warning.ID, Warning: err.Error()})
+		return
+	}
+	if err != nil {
+		s.writeError(w, "run", name, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, RunResponse{ID: id})
+}
+
+func (s *Server) inspect(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	info, err := s.c.Inspect(r.Context(), name)
+	if err != nil {
+		s.writeError(w, "inspect", name, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) remove(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	remove := s.c.Remove
+	if r.URL.Query().Get("force") == "true" {
+		remove = s.c.ForceRemove
+	}
+	if err := remove(name); err != nil {
+		s.writeError(w, "remove", name, statusOf(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) stop(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	result, err := s.c.StopWithResult(r.Context(), name)
+	if err != nil {
+		s.writeError(w, "stop", name, statusOf(err), err)
+		return
+	}
+	resp := StopResponse{StopResult: result}
+	if result.AdjustError != nil {
+		resp.AdjustError = result.AdjustError.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	id, err := s.c.StartWithID(r.Context(), name)
+	if err != nil {
+		s.writeError(w, "start", name, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, StartResponse{ID: id})
+}
+
+// statusOf 按照错误类别将 container 包返回的错误转换为 HTTP 状态码
+func statusOf(err error) int {
+	switch container.KindOf(err) {
+	case container.KindNotFound:
+		return http.StatusNotFound
+	case container.KindInvalid:
+		return http.StatusBadRequest
+	case container.KindConflict:
+		return http.StatusConflict
+	case container.KindNoSpace:
+		return http.StatusInsufficientStorage
+	case container.KindUnauthorized:
+		// 认证失败的是 Docker daemon 到镜像仓库的请求，不是客户端的请求，因此不使用 401
+		return http.StatusBadGateway
+	case container.KindTransient:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) writeError(w http.ResponseWriter, op, name string, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		s.logger.Errorf("Failed to %s container %s: %v", op, name, err)
+	}
+	writeJSON(w, status, ErrorResponse{Error: err.Error(), Kind: container.KindOf(err)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
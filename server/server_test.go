@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+// stubContainer 只实现 Server 用到的方法
+type stubContainer struct {
+	container.Container
+	runOpts  container.RunOptions
+	runCtx   chan error
+	removed  map[string]bool
+	started  []string
+	stopped  []string
+	infos    map[string]container.Info
+	stopping container.StopResult
+}
+
+func (s *stubContainer) RunWithOptions(ctx context.Context, opts container.RunOptions) (string, error) {
+	s.runOpts = opts
+	if s.runCtx != nil {
+		// 模拟耗时的创建，直到请求被取消
+		<-ctx.Done()
+		s.runCtx <- ctx.Err()
+		return "", ctx.Err()
+	}
+	if opts.Name == "optional" && opts.BallastOptional {
+		return "id-optional", &container.BallastWarning{Container: opts.Name, ID: "id-optional", Err: errors.New("fallocate failed")}
+	}
+	if opts.Name == "exists" {
+		return "", fmt.Errorf("failed to create container exists: %w", container.ErrNameConflict)
+	}
+	return "id-" + opts.Name, nil
+}
+
+func (s *stubContainer) Inspect(_ context.Context, name string) (container.Info, error) {
+	info, ok := s.infos[name]
+	if !ok {
+		return container.Info{}, fmt.Errorf("failed to inspect container %s: %w", name, container.ErrContainerNotFound)
+	}
+	return info, nil
+}
+
+func (s *stubContainer) Remove(name string) error {
+	if name == "running" {
+		return container.ErrContainerRunning
+	}
+	s.removed[name] = false
+	return nil
+}
+
+func (s *stubContainer) ForceRemove(name string) error {
+	s.removed[name] = true
+	return nil
+}
+
+func (s *stubContainer) StopWithResult(_ context.Context, name string) (container.StopResult, error) {
+	s.stopped = append(s.stopped, name)
+	return s.stopping, nil
+}
+
+func (s *stubContainer) StartWithID(_ context.Context, name string) (string, error) {
+	s.started = append(s.started, name)
+	return "id-" + name, nil
+}
+
+func newTestServer() (*stubContainer, *httptest.Server) {
+	stub := &stubContainer{removed: make(map[string]bool), infos: make(map[string]container.Info)}
+	return stub, httptest.NewServer(New(stub))
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRun(t *testing.T) {
+	stub, srv := newTestServer()
+	defer srv.Close()
+
+	resp := do(t, http.MethodPost, srv.URL+"/containers/web", `{"image":"alpine","storage_size":30000000000,"memory":1000000000}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	var run RunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != "id-web" {
+		t.Fatalf("id = %q, want id-web", run.ID)
+	}
+	if stub.runOpts.Name != "web" || stub.runOpts.Image != "alpine" || stub.runOpts.StorageSize != 30000000000 || stub.runOpts.Memory != 1000000000 {
+		t.Fatalf("unexpected run options %+v", stub.runOpts)
+	}
+
+	// 请求体可以为空
+	if resp := do(t, http.MethodPost, srv.URL+"/containers/default", ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if resp := do(t, http.MethodPost, srv.URL+"/containers/bad", "{"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp := do(t, http.MethodPost, srv.URL+"/containers/exists", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestRunBallastWarning(t *testing.T) {
+	_, srv := newTestServer()
+	defer srv.Close()
+
+	resp := do(t, http.MethodPost, srv.URL+"/containers/optional", `{"ballast_optional":true}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	var run RunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != "id-optional" || !strings.Contains(run.Warning, "fallocate failed") {
+		t.Fatalf("response = %+v, want id-optional with a warning", run)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	stub, srv := newTestServer()
+	defer srv.Close()
+	stub.runCtx = make(chan error, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/containers/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the client request to be canceled")
+	}
+
+	select {
+	case err := <-stub.runCtx:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("run context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run was not canceled after the client disconnected")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	stub, srv := newTestServer()
+	defer srv.Close()
+	stub.infos["web"] = container.Info{ID: "id-web", Name: "web", State: "running", Limited: true, BallastBytes: 5000000000}
+
+	resp := do(t, http.MethodGet, srv.URL+"/containers/web", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var info container.Info
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info != stub.infos["web"] {
+		t.Fatalf("info = %+v, want %+v", info, stub.infos["web"])
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/containers/missing", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || !strings.Contains(errResp.Error, "not found") || errResp.Kind != container.KindNotFound {
+		t.Fatalf("error response = %+v, %v", errResp, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	stub, srv := newTestServer()
+	defer srv.Close()
+
+	if resp := do(t, http.MethodDelete, srv.URL+"/containers/web", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if resp := do(t, http.MethodDelete, srv.URL+"/containers/running", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+	if resp := do(t, http.MethodDelete, srv.URL+"/containers/running?force=true", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if force, ok := stub.removed["web"]; !ok || force {
+		t.Fatal("web should be removed without force")
+	}
+	if !stub.removed["running"] {
+		t.Fatal("running should be force removed")
+	}
+}
+
+func TestStopAndStart(t *testing.T) {
+	stub, srv := newTestServer()
+	defer srv.Close()
+	stub.stopping = container.StopResult{Adjusted: true, ReducedBytes: 300000000, UsedBytes: 24200000000, FreeBytes: 800000000, AdjustError: errors.New("rm failed")}
+
+	resp := do(t, http.MethodPost, srv.URL+"/containers/web/stop", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var stop map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stop); err != nil {
+		t.Fatal(err)
+	}
+	if stop["adjusted"] != true || stop["reduced_bytes"] != float64(300000000) || stop["adjust_error"] != "rm failed" {
+		t.Fatalf("stop response = %v", stop)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/containers/web/start", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var start StartResponse
+	if err := json.NewDecoder(resp.Body).Decode(&start); err != nil || start.ID != "id-web" {
+		t.Fatalf("start response = %+v, %v", start, err)
+	}
+	if len(stub.stopped) != 1 || len(stub.started) != 1 {
+		t.Fatalf("stopped = %v, started = %v", stub.stopped, stub.started)
+	}
+}
+
+// unreachableAPI 是没有连接 Docker daemon 的 container.DockerAPI，参数校验失败的请求不会调用 Docker API，
+// 调用任何方法都会 panic
+type unreachableAPI struct {
+	container.DockerAPI
+}
+
+func TestRunInvalidArguments(t *testing.T) {
+	c, err := container.NewWithClient(unreachableAPI{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(New(c))
+	defer srv.Close()
+
+	for _, body := range []string{
+		`{"labels":{"io.ballast/threshold":"1"}}`,
+		`{"memory":-1}`,
+		`{"pids_limit":-1}`,
+		`{"ballast_path":"ballast"}`,
+		`{"network_mode":"host","dns":["8.8.8.8"]}`,
+		`{"network_mode":"container:db","extra_hosts":["db:10.0.0.1"]}`,
+		`{"network_mode":"host","networks":["backend"]}`,
+		`{"ballast_mount":"/data","ballast_path":"/other/ballast","mounts":[{"Type":"volume","Source":"data","Target":"/data"}]}`,
+	} {
+		resp := do(t, http.MethodPost, srv.URL+"/containers/web", body)
+		var errResp ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest || errResp.Kind != container.KindInvalid {
+			t.Errorf("POST %s: status = %d, kind = %q, want %d and %q (%s)", body, resp.StatusCode, errResp.Kind, http.StatusBadRequest, container.KindInvalid, errResp.Error)
+		}
+	}
+}
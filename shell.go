@@ -0,0 +1,57 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// defaultShells 没有设置 WithShell 时依次检查的 shell，最后一个不做检查，前面的都不存在时直接使用
+var defaultShells = []string{"/bin/bash", "/bin/sh"}
+
+// WithShell 设置在容器内执行组合命令（例如 fallocate 失败后使用 dd）时使用的 shell，必须支持 -c 参数
+//
+// 不设置时依次检查容器中是否有 /bin/bash 和 /bin/sh，使用第一个存在的，alpine、busybox 等精简镜像中只有 /bin/sh。
+// 检查的结果按照容器缓存，每个容器只检查一次。
+func WithShell(shell string) Option {
+	return func(dc *DockerContainer) {
+		dc.shell = shell
+	}
+}
+
+// shellCommand 使用容器 containerID 中的 shell 包装命令
+func (dc *DockerContainer) shellCommand(ctx context.Context, containerID, cmd string) ([]string, error) {
+	shell, err := dc.shellOf(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return []string{shell, "-c", cmd}, nil
+}
+
+// shellOf 返回容器 containerID 中使用的 shell，设置了 WithShell 时直接返回，否则按照 defaultShells 检查并缓存
+//
+// 检查的命令以非 0 退出码结束表示 shell 不存在，其他错误（例如容器没有运行）直接返回，不会缓存。
+func (dc *DockerContainer) shellOf(ctx context.Context, containerID string) (string, error) {
+	if dc.shell != "" {
+		return dc.shell, nil
+	}
+	if shell, ok := dc.shellCache.Load(containerID); ok {
+		return shell.(string), nil
+	}
+
+	shell := defaultShells[len(defaultShells)-1]
+	for _, candidate := range defaultShells[:len(defaultShells)-1] {
+		_, err := dc.executeCommand(ctx, containerID, []string{candidate, "-c", "true"})
+		if err == nil {
+			shell = candidate
+			break
+		}
+		var exitErr *exitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to detect shell in container %s: %w", dc.containerName(containerID), err)
+		}
+	}
+	dc.logger.Infof("Using %s in container %s", shell, dc.containerName(containerID))
+	dc.shellCache.Store(containerID, shell)
+	return shell, nil
+}
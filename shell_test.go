@@ -0,0 +1,94 @@
+package container
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// shellCommands 返回 commands 中使用 shell -c 执行的命令
+func shellCommands(commands [][]string, shell string) []string {
+	var found []string
+	for _, cmd := range commands {
+		if len(cmd) == 3 && cmd[0] == shell && cmd[1] == "-c" {
+			found = append(found, cmd[2])
+		}
+	}
+	return found
+}
+
+func TestShellFallback(t *testing.T) {
+	api := newFakeDockerAPI()
+	// 模拟 alpine 镜像，容器中没有 /bin/bash
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] == "/bin/bash" {
+			return fakeExecResult{stdout: `OCI runtime exec failed: exec failed: unable to start container process: exec: "/bin/bash": stat /bin/bash: no such file or directory: unknown`, exitCode: 126}, true
+		}
+		return fakeExecResult{}, false
+	}
+	dc := newTestContainer(api)
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := shellCommands(api.commands, "/bin/sh"); len(got) != 1 || got[0] != "fallocate -l 5000000000 /ballast" {
+		t.Fatalf("/bin/sh commands = %q, want fallocate", got)
+	}
+	if c := api.container("test"); c.ballast != int64(ballastSize) {
+		t.Fatalf("ballast = %d, want %d", c.ballast, ballastSize)
+	}
+
+	// 检查的结果被缓存，之后的命令不会再检查 /bin/bash
+	api.container("test").dataUsed = int64(defaultStorageSize) - 800*megabyte
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := shellCommands(api.commands, "/bin/bash"); len(got) != 1 || got[0] != "true" {
+		t.Fatalf("/bin/bash commands = %q, want a single probe", got)
+	}
+	if got := shellCommands(api.commands, "/bin/sh"); len(got) != 2 {
+		t.Fatalf("/bin/sh commands = %q, want fallocate twice", got)
+	}
+}
+
+func TestWithShell(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithShell("/bin/ash"))
+
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range api.commands {
+		if strings.Contains(cmd[0], "sh") && cmd[0] != "/bin/ash" {
+			t.Fatalf("unexpected shell command %q", cmd)
+		}
+	}
+	if got := shellCommands(api.commands, "/bin/ash"); len(got) != 1 {
+		t.Fatalf("/bin/ash commands = %q, want fallocate", got)
+	}
+
+	if _, err := NewWithClient(newFakeDockerAPI(), WithShell("/bin/sh -e")); err == nil {
+		t.Fatal("expected error for shell with arguments")
+	}
+}
+
+func TestShellProbeError(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	// 容器停止后无法检查，错误不会被缓存为 /bin/sh
+	c := api.container("test")
+	dc.forgetLabels("test")
+	api.mu.Lock()
+	c.json.State.Running = false
+	api.mu.Unlock()
+
+	if _, err := dc.shellOf(context.Background(), c.json.ID); err == nil {
+		t.Fatal("expected error when container is not running")
+	}
+	if _, ok := dc.shellCache.Load(c.json.ID); ok {
+		t.Fatal("failed probe should not be cached")
+	}
+}
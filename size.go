@@ -0,0 +1,62 @@
+package container
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits 是 parseHumanSize 支持的单位（小写），SI 单位按照 1000 进位，IEC 单位按照 1024 进位
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": 1 << 40,
+	"p":   1000 * 1000 * 1000 * 1000 * 1000,
+	"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+	"pib": 1 << 50,
+}
+
+// parseHumanSize 将 humanize.Bytes（例如 25GB、1.2TB）或者 humanize.IBytes（例如 23GiB）格式的大小解析为字节数，
+// 也支持不带单位的字节数，单位不区分大小写，数字和单位之间可以有空格
+func parseHumanSize(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	i := strings.IndexFunc(v, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	num, unit := v, ""
+	if i >= 0 {
+		num, unit = v[:i], strings.TrimSpace(v[i:])
+	}
+	mult, ok := sizeUnits[strings.ToLower(unit)]
+	if num == "" || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	// 整数直接计算，避免浮点数的精度损失
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || n > math.MaxInt64/mult {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		return n * mult, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	size := math.Round(f * float64(mult))
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(size), nil
+}
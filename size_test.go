@@ -0,0 +1,44 @@
+package container
+
+import "testing"
+
+func TestParseHumanSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"25000000000", 25 * gigabyte},
+		{"0", 0},
+		{"512B", 512},
+		{"1kB", 1000},
+		{"1KiB", 1024},
+		{"1.5MB", 1500 * 1000},
+		{"1MiB", 1 << 20},
+		{"25GB", 25 * gigabyte},
+		{"25 GB", 25 * gigabyte},
+		{"25gb", 25 * gigabyte},
+		{"25G", 25 * gigabyte},
+		{"23GiB", 23 << 30},
+		{"1.5GiB", 3 << 29},
+		{"1.2TB", 1200 * gigabyte},
+		{"2TiB", 2 << 40},
+		{"1PB", 1000 * 1000 * gigabyte},
+		{"1PiB", 1 << 50},
+	}
+	for _, tt := range tests {
+		got, err := parseHumanSize(tt.in)
+		if err != nil {
+			t.Errorf("parseHumanSize(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHumanSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "GB", "25XB", "-1GB", "1.2.3GB", "25 G B", "99999999999PB"} {
+		if got, err := parseHumanSize(in); err == nil {
+			t.Errorf("parseHumanSize(%q) = %d, want an error", in, got)
+		}
+	}
+}
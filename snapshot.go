@@ -0,0 +1,155 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// WithSnapshotStripBallast 设置 Commit 和 Export 之前是否删除容器的 /ballast，默认关闭
+//
+// /ballast 位于容器的可写层，会被原样写入提交的镜像和导出的 tar，使它们增加 /ballast 大小的体积。
+// 开启后 /ballast 在提交或者导出前被删除，完成后恢复为原来的大小，期间容器的系统盘没有 /ballast 的保护。
+func WithSnapshotStripBallast(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.snapshotStripBallast = enabled
+	}
+}
+
+// Commit 将容器 name 提交为镜像 ref，返回镜像的 ID，ref 为空时创建没有标签的镜像，提交期间容器会被暂停
+//
+// 开启 WithSnapshotStripBallast 时，/ballast 会在提交前被删除，无论提交是否成功都会在之后恢复。
+// 恢复失败时镜像已经提交，返回镜像的 ID 以及恢复失败的原因，此时可以调用 GrowBallast 或者 Reconcile 重新创建 /ballast。
+func (dc *DockerContainer) Commit(ctx context.Context, name, ref string) (imageID string, err error) {
+	defer wrapOp("commit", name, &err)
+	defer dc.lock(name)()
+
+	if dc.dryRunf("Would commit container %s as %q", name, ref) {
+		return "", nil
+	}
+
+	restore, err := dc.stripBallast(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if restoreErr := restore(); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+	}()
+
+	resp, err := dc.cli.ContainerCommit(ctx, name, container.CommitOptions{Reference: ref, Pause: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit container %s: %w", name, err)
+	}
+	dc.logger.Infof("Successfully committed container %s as %s", name, resp.ID)
+	return resp.ID, nil
+}
+
+// Export 以 tar 的格式导出容器 name 的文件系统，调用方需要关闭返回的 io.ReadCloser
+//
+// 开启 WithSnapshotStripBallast 时，/ballast 会在导出前被删除，在返回的 io.ReadCloser 被关闭时恢复，
+// 导出失败时立即恢复。恢复失败的原因由 Close 返回。关闭之前同一个容器上的 Stop、/ballast 的调整等操作会一直等待。
+func (dc *DockerContainer) Export(ctx context.Context, name string) (_ io.ReadCloser, err error) {
+	defer wrapOp("export", name, &err)
+	unlock := dc.lock(name)
+
+	restore, err := dc.stripBallast(ctx, name)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	rc, err := dc.cli.ContainerExport(ctx, name)
+	if err != nil {
+		err = fmt.Errorf("failed to export container %s: %w", name, err)
+		if restoreErr := restore(); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+		unlock()
+		return nil, err
+	}
+	return &exportReader{ReadCloser: rc, done: func() error {
+		defer unlock()
+		return restore()
+	}}, nil
+}
+
+// exportReader 在关闭时恢复 Export 删除的 /ballast，重复调用 Close 只会恢复一次
+type exportReader struct {
+	io.ReadCloser
+
+	once sync.Once
+	done func() error
+	err  error
+}
+
+func (r *exportReader) Close() error {
+	r.once.Do(func() {
+		r.err = errors.Join(r.ReadCloser.Close(), r.done())
+	})
+	return r.err
+}
+
+// stripBallast 在没有开启 WithSnapshotStripBallast 时什么也不做，否则删除容器 name 的 /ballast，
+// 返回将 /ballast 恢复为原来大小的函数
+//
+// 没有限制系统盘、容器没有运行（或者被暂停）、/ballast 在卷上或者不存在时不会删除 /ballast，返回的函数同样什么也不做。
+// 返回的函数不使用 ctx 的取消，ctx 被取消后同样会恢复 /ballast。
+func (dc *DockerContainer) stripBallast(ctx context.Context, name string) (restore func() error, err error) {
+	noop := func() error { return nil }
+	if !dc.snapshotStripBallast {
+		return noop, nil
+	}
+
+	containerInspect, limits, err := dc.inspectLimits(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !limits.limited {
+		return noop, nil
+	}
+	if containerInspect.State == nil || !containerInspect.State.Running || containerInspect.State.Paused {
+		dc.logger.Infof("Container %s is not running, keeping %s in the snapshot", name, limits.path)
+		return noop, nil
+	}
+	if mount := dc.mountOf(containerInspect.Config.Labels); mount != rootMount {
+		// 卷上的 /ballast 本来就不会被提交或者导出
+		return noop, nil
+	}
+
+	if dc.dryRunf("Would remove %s of container %s before snapshot", limits.path, name) {
+		return noop, nil
+	}
+
+	id := containerInspect.ID
+	oldSize, newSize, err := shrinkBallast(dc, ctx, id, limits.path, math.MaxInt64)
+	if errors.Is(err, ErrBallastNotFound) {
+		return noop, nil
+	}
+	restore = func() error {
+		if newSize >= oldSize {
+			return nil
+		}
+		if _, err := dc.allocateBallast(context.WithoutCancel(ctx), id, limits.path, newSize, oldSize); err != nil {
+			dc.logger.Errorf("Failed to restore %s of container %s to %d bytes: %v", limits.path, name, oldSize, err)
+			return fmt.Errorf("failed to restore ballast file of container %s: %w", name, err)
+		}
+		dc.logger.Infof("Restored %s of container %s to %d bytes", limits.path, name, oldSize)
+		return nil
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to remove ballast file of container %s: %w", name, err)
+		if restoreErr := restore(); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+		return nil, err
+	}
+	dc.logger.Infof("Removed %s of container %s before snapshot, %d bytes will be restored", limits.path, name, oldSize)
+	return restore, nil
+}
@@ -0,0 +1,83 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	size := api.container("test").ballast
+
+	// 默认不删除 /ballast
+	imageID, err := dc.Commit(context.Background(), "test", "test:snapshot")
+	if err != nil || imageID == "" {
+		t.Fatalf("Commit() = %q, %v, want an image ID", imageID, err)
+	}
+	commit := api.commits[0]
+	if commit.options.Reference != "test:snapshot" || !commit.options.Pause || commit.ballast != size {
+		t.Fatalf("commit = %+v, want reference test:snapshot with the ballast of %d bytes", commit, size)
+	}
+
+	// 开启后提交时 /ballast 不存在，之后恢复为原来的大小
+	dc = newTestContainer(api, WithSnapshotStripBallast(true))
+	if _, err := dc.Commit(context.Background(), "test", ""); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if got := api.commits[1].ballast; got != -1 {
+		t.Fatalf("ballast size at commit = %d, want removed", got)
+	}
+	if got := api.container("test").ballast; got != size {
+		t.Fatalf("ballast size after commit = %d, want %d", got, size)
+	}
+
+	// 提交失败同样恢复 /ballast
+	api.commitErr = errors.New("commit failed")
+	if _, err := dc.Commit(context.Background(), "test", ""); !errors.Is(err, api.commitErr) {
+		t.Fatalf("Commit() error = %v, want %v", err, api.commitErr)
+	}
+	if got := api.container("test").ballast; got != size {
+		t.Fatalf("ballast size after failed commit = %d, want %d", got, size)
+	}
+}
+
+func TestExport(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api, WithSnapshotStripBallast(true))
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	size := api.container("test").ballast
+
+	rc, err := dc.Export(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if data, err := io.ReadAll(rc); err != nil || string(data) != "rootfs" {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+	// 关闭之前 /ballast 保持删除
+	if api.exports[0] != -1 || api.container("test").ballast != -1 {
+		t.Fatalf("ballast size during export = %d, want removed", api.container("test").ballast)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if got := api.container("test").ballast; got != size {
+		t.Fatalf("ballast size after export = %d, want %d", got, size)
+	}
+
+	// 关闭后锁被释放
+	if err := dc.ShrinkBallast(context.Background(), "test", 1); err != nil {
+		t.Fatalf("ShrinkBallast() error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerSpec 描述 FromSpec 中的一个容器，零值字段与 RunOptions 一样使用默认值
+type ContainerSpec struct {
+	Name        string            `yaml:"name" json:"name"`
+	Image       string            `yaml:"image,omitempty" json:"image,omitempty"`
+	Cmd         []string          `yaml:"cmd,omitempty" json:"cmd,omitempty"`
+	StorageSize SpecSize          `yaml:"storageSize,omitempty" json:"storageSize,omitempty"`
+	BallastSize SpecSize          `yaml:"ballastSize,omitempty" json:"ballastSize,omitempty"`
+	Env         []string          `yaml:"env,omitempty" json:"env,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// SpecSize 是 ContainerSpec 中的大小，单位为字节，可以写成整数或者带单位的字符串（例如 20GB、20GiB）
+type SpecSize int64
+
+// UnmarshalYAML 实现 yaml.Unmarshaler
+func (s *SpecSize) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: invalid size, must be a number or a string like 20GB", node.Line)
+	}
+	size, err := parseHumanSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid size %q: %w", node.Line, node.Value, err)
+	}
+	*s = SpecSize(size)
+	return nil
+}
+
+// runOptions 将 spec 转换为 RunOptions
+func (spec ContainerSpec) runOptions() RunOptions {
+	return RunOptions{
+		Name:        spec.Name,
+		Image:       spec.Image,
+		Cmd:         spec.Cmd,
+		Env:         spec.Env,
+		Labels:      spec.Labels,
+		StorageSize: int64(spec.StorageSize),
+		BallastSize: int64(spec.BallastSize),
+	}
+}
+
+// FromSpec 按照 r 中的描述依次创建并启动容器，返回与描述顺序一致的容器 ID
+//
+// r 的内容为 ContainerSpec 的列表，YAML 和 JSON 格式都可以，例如：
+//
+//	# containers.yaml
+//	- name: web
+//	  image: nginx
+//	  storageSize: 20GB
+//	  ballastSize: 5GB
+//	  env: [KEY=VALUE]
+//
+// FromSpec 是全部成功或者全部失败的：任意一个容器创建失败时，已经创建的容器会被强制删除，
+// 返回的错误指出失败的容器，回滚失败的容器会一并列在错误中。描述不合法或者名称重复时返回 ErrInvalidSpec，
+// 不会创建任何容器。
+func (dc *DockerContainer) FromSpec(ctx context.Context, r io.Reader) (_ []string, err error) {
+	defer wrapOp("from_spec", "", &err)
+
+	specs, err := parseSpec(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	ids := make([]string, 0, len(specs))
+	for i, spec := range specs {
+		id, err := dc.RunWithOptions(ctx, spec.runOptions())
+		if err != nil {
+			return nil, dc.rollbackSpec(specs[:i], fmt.Errorf("failed to run container %s from spec: %w", spec.Name, err))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseSpec 解析并校验 FromSpec 的描述，未知的字段会被当作错误，避免拼写错误被忽略
+func parseSpec(r io.Reader) ([]ContainerSpec, error) {
+	var specs []ContainerSpec
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&specs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+
+	seen := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if err := validateName(spec.Name); err != nil {
+			return nil, fmt.Errorf("%w: container #%d: %v", ErrInvalidSpec, i+1, err)
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("%w: duplicate container name %s", ErrInvalidSpec, spec.Name)
+		}
+		seen[spec.Name] = true
+	}
+	return specs, nil
+}
+
+// rollbackSpec 强制删除 FromSpec 已经创建的容器，返回包含 cause 和所有回滚错误的错误
+func (dc *DockerContainer) rollbackSpec(created []ContainerSpec, cause error) error {
+	errs := []error{cause}
+	for i := len(created) - 1; i >= 0; i-- {
+		name := created[i].Name
+		if err := dc.remove(name, true); err != nil {
+			dc.logger.Errorf("Failed to roll back container %s: %v", name, err)
+			errs = append(errs, fmt.Errorf("failed to roll back container %s: %w", name, err))
+			continue
+		}
+		dc.logger.Infof("Successfully rolled back container %s", name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	yamlSpec := `
+- name: web
+  image: nginx
+  cmd: [nginx, -g, daemon off;]
+  storageSize: 30GB
+  ballastSize: 2000000000
+  env: [KEY=VALUE]
+  labels:
+    app: web
+- name: db
+`
+	jsonSpec := `[{"name": "web", "image": "nginx", "cmd": ["nginx", "-g", "daemon off;"], "storageSize": "30GB",
+		"ballastSize": 2000000000, "env": ["KEY=VALUE"], "labels": {"app": "web"}}, {"name": "db"}]`
+	want := []ContainerSpec{
+		{
+			Name:        "web",
+			Image:       "nginx",
+			Cmd:         []string{"nginx", "-g", "daemon off;"},
+			StorageSize: 30 * gigabyte,
+			BallastSize: 2 * gigabyte,
+			Env:         []string{"KEY=VALUE"},
+			Labels:      map[string]string{"app": "web"},
+		},
+		{Name: "db"},
+	}
+	for _, spec := range []string{yamlSpec, jsonSpec} {
+		specs, err := parseSpec(strings.NewReader(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(specs, want) {
+			t.Fatalf("parseSpec() = %+v, want %+v", specs, want)
+		}
+	}
+
+	for _, spec := range []string{
+		"- name: web\n  storage: 30GB\n",
+		"- name: web\n  storageSize: lots\n",
+		"- name: web\n- name: web\n",
+		"- image: nginx\n",
+		"name: web\n",
+	} {
+		if _, err := parseSpec(strings.NewReader(spec)); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("parseSpec(%q) error = %v, want ErrInvalidSpec", spec, err)
+		}
+	}
+}
+
+func TestFromSpec(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	ctx := context.Background()
+
+	ids, err := dc.FromSpec(ctx, strings.NewReader("- name: web\n  storageSize: 30GB\n- name: db\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != api.container("web").json.ID || ids[1] != api.container("db").json.ID {
+		t.Fatalf("FromSpec() = %v", ids)
+	}
+	if got := api.container("web").json.Config.Labels[dc.labelKey(labelBaseStorage)]; got != "30000000000" {
+		t.Fatalf("base_storage of web = %s, want 30000000000", got)
+	}
+
+	// 第三个容器与已有的容器同名，之前创建的容器会被回滚
+	_, err = dc.FromSpec(ctx, strings.NewReader("- name: cache\n- name: queue\n- name: web\n"))
+	if !errors.Is(err, ErrNameConflict) || KindOf(err) != KindConflict {
+		t.Fatalf("FromSpec() error = %v, want ErrNameConflict", err)
+	}
+	for _, name := range []string{"cache", "queue"} {
+		if api.container(name) != nil {
+			t.Fatalf("container %s should be rolled back", name)
+		}
+	}
+	if api.container("web") == nil {
+		t.Fatal("existing container web should be kept")
+	}
+
+	if _, err := dc.FromSpec(ctx, strings.NewReader("- name: a\n")); KindOf(err) != KindInvalid {
+		t.Fatalf("FromSpec() error = %v, want KindInvalid", err)
+	}
+}
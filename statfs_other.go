@@ -0,0 +1,10 @@
+//go:build !unix
+
+package container
+
+import "fmt"
+
+// statfs 在非 unix 平台上不支持
+func statfs(path string) (total, available int64, err error) {
+	return 0, 0, fmt.Errorf("%w: statfs is not supported on this platform", ErrDiskInfoUnavailable)
+}
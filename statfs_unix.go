@@ -0,0 +1,14 @@
+//go:build unix
+
+package container
+
+import "syscall"
+
+// statfs 返回 path 所在文件系统的总容量和非特权用户可用的剩余空间
+func statfs(path string) (total, available int64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Blocks) * int64(st.Bsize), int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,108 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Stat 是容器在某一时刻的资源使用情况，计算方式与 docker stats 一致
+type Stat struct {
+	// Time 采集的时间
+	Time time.Time
+	// CPUPercent CPU 使用率，多核时可能超过 100
+	CPUPercent float64
+	// MemoryUsage 内存使用量（不包括 page cache）和内存限制，单位为字节
+	MemoryUsage int64
+	MemoryLimit int64
+	// BlockRead 和 BlockWrite 累计读写磁盘的字节数
+	BlockRead  int64
+	BlockWrite int64
+}
+
+// Stats 返回容器的资源使用情况，stream 为 false 时只返回一次
+//
+// 数据流结束、ctx 被取消或者解析失败时 channel 会被关闭。
+func (dc *DockerContainer) Stats(ctx context.Context, name string, stream bool) (_ <-chan Stat, err error) {
+	defer wrapOp("stats", name, &err)
+
+	resp, err := dc.cli.ContainerStats(ctx, name, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats of container %s: %w", name, wrapNotFound(err))
+	}
+
+	ch := make(chan Stat)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var s container.StatsResponse
+			if err := decoder.Decode(&s); err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					dc.logger.Errorf("Failed to decode stats of container %s: %v", name, err)
+				}
+				return
+			}
+			select {
+			case ch <- toStat(s):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// toStat 将 Docker 返回的原始数据转换为 Stat
+func toStat(s container.StatsResponse) Stat {
+	stat := Stat{
+		Time:        s.Read,
+		CPUPercent:  cpuPercent(s.PreCPUStats, s.CPUStats),
+		MemoryUsage: int64(memoryUsage(s.MemoryStats)),
+		MemoryLimit: int64(s.MemoryStats.Limit),
+	}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stat.BlockRead += int64(entry.Value)
+		case "write":
+			stat.BlockWrite += int64(entry.Value)
+		}
+	}
+	return stat
+}
+
+// cpuPercent 根据两次采集之间容器和整个系统的 CPU 时间计算使用率
+func cpuPercent(prev, cur container.CPUStats) float64 {
+	cpuDelta := float64(cur.CPUUsage.TotalUsage) - float64(prev.CPUUsage.TotalUsage)
+	systemDelta := float64(cur.SystemUsage) - float64(prev.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(cur.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// memoryUsage 返回去掉 page cache 之后的内存使用量，兼容 cgroup v1 和 v2
+func memoryUsage(m container.MemoryStats) uint64 {
+	cache, ok := m.Stats["total_inactive_file"]
+	if !ok {
+		cache = m.Stats["inactive_file"]
+	}
+	if cache > m.Usage {
+		return m.Usage
+	}
+	return m.Usage - cache
+}
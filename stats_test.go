@@ -0,0 +1,86 @@
+package container
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStats(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	var s container.StatsResponse
+	s.Read = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.PreCPUStats.CPUUsage.TotalUsage = 1000
+	s.PreCPUStats.SystemUsage = 10000
+	s.CPUStats.CPUUsage.TotalUsage = 1500
+	s.CPUStats.SystemUsage = 20000
+	s.CPUStats.OnlineCPUs = 4
+	s.MemoryStats = container.MemoryStats{Usage: 300, Limit: 1000, Stats: map[string]uint64{"inactive_file": 100}}
+	s.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{
+		{Op: "read", Value: 10}, {Op: "Write", Value: 20}, {Op: "read", Value: 5},
+	}
+	api.container("test").stats = []container.StatsResponse{s, s}
+
+	ch, err := dc.Stats(context.Background(), "test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Stat
+	for stat := range ch {
+		got = append(got, stat)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 stats, got %d", len(got))
+	}
+
+	stat := got[0]
+	if math.Abs(stat.CPUPercent-20) > 1e-9 {
+		t.Errorf("CPUPercent = %v, want 20", stat.CPUPercent)
+	}
+	if stat.MemoryUsage != 200 || stat.MemoryLimit != 1000 {
+		t.Errorf("memory = %d/%d, want 200/1000", stat.MemoryUsage, stat.MemoryLimit)
+	}
+	if stat.BlockRead != 15 || stat.BlockWrite != 20 {
+		t.Errorf("block io = %d/%d, want 15/20", stat.BlockRead, stat.BlockWrite)
+	}
+	if !stat.Time.Equal(s.Read) {
+		t.Errorf("Time = %v, want %v", stat.Time, s.Read)
+	}
+}
+
+func TestStatsCanceled(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	api.container("test").stats = make([]container.StatsResponse, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := dc.Stats(ctx, "test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed after cancel")
+		}
+	}
+}
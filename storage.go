@@ -0,0 +1,145 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/dustin/go-humanize"
+)
+
+// checkStorageOptSupport 检查存储驱动是否支持限制容器系统盘大小
+//
+// overlay2 只有在底层文件系统为 xfs（并且使用 pquota 挂载）时才支持，
+// devicemapper、btrfs、zfs 以及 Windows 的 windowsfilter 原生支持。
+// pquota 挂载参数无法通过 Info 接口获取，如果缺失会在创建容器时由 Docker 报错。
+func checkStorageOptSupport(info system.Info) error {
+	switch info.Driver {
+	case "devicemapper", "btrfs", "zfs", "windowsfilter":
+		return nil
+	case "overlay2":
+		for _, status := range info.DriverStatus {
+			if status[0] == "Backing Filesystem" {
+				if strings.EqualFold(status[1], "xfs") {
+					return nil
+				}
+				return fmt.Errorf("%w: overlay2 on %s, xfs with pquota is required", ErrStorageOptUnsupported, status[1])
+			}
+		}
+		return fmt.Errorf("%w: overlay2 with unknown backing filesystem", ErrStorageOptUnsupported)
+	default:
+		return fmt.Errorf("%w: %s", ErrStorageOptUnsupported, info.Driver)
+	}
+}
+
+// checkPlatform 检查 Docker daemon 是否运行 Linux 容器，旧版本的 daemon 没有返回 OSType 时视为 Linux
+//
+// Windows 容器的 storage-opt size 含义不同，也没有 fallocate、stat 和 df，创建的 /ballast 无法工作，
+// 因此直接返回 ErrUnsupportedPlatform。
+func checkPlatform(info system.Info) error {
+	if info.OSType == "" || strings.EqualFold(info.OSType, "linux") {
+		return nil
+	}
+	return fmt.Errorf("%w: %s, only linux containers are supported", ErrUnsupportedPlatform, info.OSType)
+}
+
+// checkBallastSupport 查询 Docker 的信息，判断新创建的容器能否使用 /ballast，
+// storageOpt 为 true 时同时检查存储驱动是否支持限制容器系统盘大小
+func (dc *DockerContainer) checkBallastSupport(ctx context.Context, storageOpt bool) error {
+	info, err := dc.cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get docker info: %w", err)
+	}
+	if err := checkPlatform(info); err != nil {
+		return err
+	}
+	if !storageOpt {
+		return nil
+	}
+	return checkStorageOptSupport(info)
+}
+
+// minDiskUsageVersion 是支持 DiskUsage 接口的最低 API 版本
+const minDiskUsageVersion = "1.25"
+
+// HostDiskInfo 返回宿主机的存储驱动，以及存储的总容量和可以分配给新容器的剩余空间，单位为字节，
+// 调度方可以在 Run 之前判断宿主机能否放下 StorageSize + BallastSize 的容器
+//
+// devicemapper 会在 Info 中报告容量；其他存储驱动只能在 Docker daemon 位于本机（unix://）时
+// 通过 DockerRootDir 所在的文件系统获取，否则返回 ErrDiskInfoUnavailable。
+// 已经创建的受限容器最多还能写入 threshold 与当前大小之差，这部分空间会从剩余空间中扣除。
+// 协商后的 API 版本不支持 DiskUsage 时返回 ErrDiskUsageUnsupported。
+func (dc *DockerContainer) HostDiskInfo(ctx context.Context) (driver string, total, available int64, err error) {
+	defer wrapOp("host_disk_info", "", &err)
+
+	info, err := dc.cli.Info(ctx)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	driver = info.Driver
+
+	// Info 会触发 API 版本协商，之后 ClientVersion 才是实际使用的版本
+	if version := dc.cli.ClientVersion(); versions.LessThan(version, minDiskUsageVersion) {
+		return driver, 0, 0, fmt.Errorf("%w: API version %s, %s is required", ErrDiskUsageUnsupported, version, minDiskUsageVersion)
+	}
+
+	total, available, ok := driverCapacity(info)
+	if !ok {
+		if !strings.HasPrefix(dc.cli.DaemonHost(), "unix://") || info.DockerRootDir == "" {
+			return driver, 0, 0, fmt.Errorf("%w: storage driver %s does not report its capacity", ErrDiskInfoUnavailable, driver)
+		}
+		if total, available, err = statfs(info.DockerRootDir); err != nil {
+			return driver, 0, 0, fmt.Errorf("failed to get capacity of %s: %w", info.DockerRootDir, err)
+		}
+	}
+
+	du, err := dc.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ContainerObject}})
+	if err != nil {
+		return driver, 0, 0, fmt.Errorf("failed to get docker disk usage: %w", err)
+	}
+	available -= dc.reservedSpace(du.Containers)
+	if available < 0 {
+		available = 0
+	}
+	return driver, total, available, nil
+}
+
+// driverCapacity 从 devicemapper 的 DriverStatus 中读取数据空间的总容量和剩余空间
+func driverCapacity(info system.Info) (total, available int64, ok bool) {
+	var hasTotal, hasAvailable bool
+	for _, status := range info.DriverStatus {
+		size, err := humanize.ParseBytes(status[1])
+		if err != nil {
+			continue
+		}
+		switch status[0] {
+		case "Data Space Total":
+			total, hasTotal = int64(size), true
+		case "Data Space Available":
+			available, hasAvailable = int64(size), true
+		}
+	}
+	return total, available, hasTotal && hasAvailable
+}
+
+// reservedSpace 计算受限容器还能继续写入的空间，即 threshold 与 SizeRw 之差的总和
+func (dc *DockerContainer) reservedSpace(containers []*types.Container) int64 {
+	var reserved int64
+	for _, c := range containers {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		limit, limited, err := dc.storageLimit(name, c.Labels)
+		if err != nil || !limited {
+			continue
+		}
+		if limit > c.SizeRw {
+			reserved += limit - c.SizeRw
+		}
+	}
+	return reserved
+}
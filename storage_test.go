@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+)
+
+func TestCheckStorageOptSupport(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      system.Info
+		supported bool
+	}{
+		{"overlay2 on xfs", system.Info{Driver: "overlay2", DriverStatus: [][2]string{{"Backing Filesystem", "xfs"}}}, true},
+		{"overlay2 on extfs", system.Info{Driver: "overlay2", DriverStatus: [][2]string{{"Backing Filesystem", "extfs"}}}, false},
+		{"overlay2 unknown", system.Info{Driver: "overlay2"}, false},
+		{"devicemapper", system.Info{Driver: "devicemapper"}, true},
+		{"btrfs", system.Info{Driver: "btrfs"}, true},
+		{"vfs", system.Info{Driver: "vfs"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStorageOptSupport(tt.info)
+			if tt.supported && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.supported && !errors.Is(err, ErrStorageOptUnsupported) {
+				t.Fatalf("expected ErrStorageOptUnsupported, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	for osType, supported := range map[string]bool{"": true, "linux": true, "windows": false} {
+		err := checkPlatform(system.Info{OSType: osType})
+		if supported && err != nil {
+			t.Fatalf("checkPlatform(%q) unexpected error: %v", osType, err)
+		}
+		if !supported && !errors.Is(err, ErrUnsupportedPlatform) {
+			t.Fatalf("checkPlatform(%q) = %v, want ErrUnsupportedPlatform", osType, err)
+		}
+	}
+
+	// Windows 上拒绝创建带有 /ballast 的容器，不创建容器
+	api := newFakeDockerAPI()
+	api.info = system.Info{OSType: "windows", Driver: "windowsfilter"}
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("Run() error = %v, want ErrUnsupportedPlatform", err)
+	}
+	if api.container("test") != nil {
+		t.Fatal("container should not be created on windows")
+	}
+	if _, err := dc.RunWithOptions(context.Background(), RunOptions{Name: "test", DisableBallast: true}); err != nil {
+		t.Fatalf("RunWithOptions() without ballast error = %v", err)
+	}
+}
+
+func TestHostDiskInfo(t *testing.T) {
+	api := newFakeDockerAPI()
+	api.info = system.Info{
+		Driver:       "devicemapper",
+		DriverStatus: [][2]string{{"Data Space Total", "107.4GB"}, {"Data Space Available", "80GB"}},
+	}
+	dc := newTestContainer(api)
+	ctx := context.Background()
+	if _, err := dc.RunWithOptions(ctx, RunOptions{Name: "test", StorageSize: 20 * gigabyte, BallastSize: 5 * gigabyte}); err != nil {
+		t.Fatal(err)
+	}
+
+	// test 已经写入 5GB 的 /ballast，最多还能再写入 20GB
+	driver, total, available, err := dc.HostDiskInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver != "devicemapper" || total != 107400*megabyte || available != 60*gigabyte {
+		t.Fatalf("HostDiskInfo() = %s, %d, %d", driver, total, available)
+	}
+
+	// overlay2 不报告容量，远程的 Docker daemon 无法获取
+	api.info = system.Info{Driver: "overlay2", DockerRootDir: t.TempDir()}
+	if _, _, _, err := dc.HostDiskInfo(ctx); !errors.Is(err, ErrDiskInfoUnavailable) {
+		t.Fatalf("HostDiskInfo() error = %v, want ErrDiskInfoUnavailable", err)
+	}
+
+	// 本机的 Docker daemon 通过 DockerRootDir 所在的文件系统获取
+	api.host = "unix:///var/run/docker.sock"
+	if _, total, _, err := dc.HostDiskInfo(ctx); err != nil || total <= 0 {
+		t.Fatalf("HostDiskInfo() total = %d, error = %v", total, err)
+	}
+
+	api.version = "1.24"
+	if _, _, _, err := dc.HostDiskInfo(ctx); !errors.Is(err, ErrDiskUsageUnsupported) {
+		t.Fatalf("HostDiskInfo() error = %v, want ErrDiskUsageUnsupported", err)
+	}
+}
@@ -0,0 +1,76 @@
+package container
+
+import "context"
+
+// Tracer 为 Run、Stop、容器内执行的每一条命令以及 /ballast 的调整创建 span，默认不做任何事情
+//
+// 本包不直接依赖 OpenTelemetry，子包 tracing 提供了基于 go.opentelemetry.io/otel/trace 的实现。
+type Tracer interface {
+	// Start 创建一个名为 name 的 span，返回的 ctx 中包含该 span，之后的 span 会作为它的子 span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span 是 Tracer 创建的 span
+type Span interface {
+	// SetAttributes 为 span 增加属性
+	SetAttributes(attrs ...Attribute)
+	// RecordError 记录操作失败的原因，并将 span 标记为失败
+	RecordError(err error)
+	// End 结束 span
+	End()
+}
+
+// Attribute 是 span 的属性，Value 的类型为 string、int64、int 或者 bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// span 属性的名称
+const (
+	attrContainerName = "container.name"
+	attrContainerID   = "container.id"
+	attrImage         = "container.image"
+	attrBallastPath   = "ballast.path"
+	attrBallastBytes  = "ballast.bytes"
+	attrFreedBytes    = "ballast.freed_bytes"
+	attrUsedBytes     = "disk.used_bytes"
+	attrExecCommand   = "exec.command"
+	attrExecExitCode  = "exec.exit_code"
+)
+
+// WithTracer 设置创建 span 使用的 Tracer，为 nil 时不创建 span
+func WithTracer(tracer Tracer) Option {
+	return func(dc *DockerContainer) {
+		if tracer == nil {
+			tracer = noopTracer{}
+		}
+		dc.tracer = tracer
+	}
+}
+
+// startSpan 使用 dc.tracer 创建 span
+func (dc *DockerContainer) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return dc.tracer.Start(ctx, name, attrs...)
+}
+
+// endSpan 在 defer 中使用，*errp 不为 nil 时记录错误后结束 span
+func endSpan(span Span, errp *error) {
+	if *errp != nil {
+		span.RecordError(*errp)
+	}
+	span.End()
+}
+
+// noopTracer 是默认的 Tracer，不创建任何 span
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// recordedSpan 是 recordingTracer 记录的 span
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+// recordingTracer 记录所有创建的 span，通过 ctx 记录父 span
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	span := &recordedSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	span.SetAttributes(attrs...)
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	var spans []*recordedSpan
+	for _, s := range t.spans {
+		if s.name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func TestTracerSpans(t *testing.T) {
+	api := newFakeDockerAPI()
+	tracer := &recordingTracer{}
+	dc := newTestContainer(api, WithTracer(tracer))
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	if run := tracer.named("ballast.run"); len(run) != 1 || run[0].attrs[attrContainerName] != "test" || !run[0].ended {
+		t.Fatalf("run spans = %+v", run)
+	}
+
+	api.container("test").dataUsed = int64(defaultStorageSize) - 800*megabyte
+	tracer.spans = nil
+	if _, err := dc.StopWithResult(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := tracer.named("ballast.stop")
+	if len(stop) != 1 || stop[0].attrs[attrFreedBytes] != int64(300*megabyte) || !stop[0].ended {
+		t.Fatalf("stop spans = %+v", stop)
+	}
+	adjust := tracer.named("ballast.adjust")
+	if len(adjust) != 1 || adjust[0].parent != stop[0] || adjust[0].attrs[attrFreedBytes] != int64(300*megabyte) {
+		t.Fatalf("adjust spans = %+v", adjust)
+	}
+	execs := tracer.named("ballast.exec")
+	if len(execs) == 0 {
+		t.Fatal("no exec spans recorded")
+	}
+	for _, s := range execs {
+		if s.parent != stop[0] && s.parent != adjust[0] {
+			t.Fatalf("exec span %+v is not part of the stop trace", s)
+		}
+		if _, ok := s.attrs[attrExecExitCode]; !ok || !s.ended {
+			t.Fatalf("exec span %+v has no exit code", s)
+		}
+	}
+
+	// 失败的操作会记录错误
+	tracer.spans = nil
+	if _, err := dc.StopWithResult(context.Background(), "missing"); err == nil {
+		t.Fatal("expected an error for a missing container")
+	}
+	if stop := tracer.named("ballast.stop"); len(stop) != 1 || stop[0].err == nil {
+		t.Fatalf("stop spans = %+v, want the error recorded", stop)
+	}
+}
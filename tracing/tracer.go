@@ -0,0 +1,68 @@
+// Package tracing 使用 OpenTelemetry 实现 container.Tracer
+//
+// 该包单独依赖 go.opentelemetry.io/otel，不使用追踪的调用方不需要引入该依赖。
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+// Tracer 将 container.Tracer 的 span 转发到 OpenTelemetry
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New 创建一个使用 tracer 创建 span 的 Tracer，通常为 otel.Tracer("github.com/mayooot/docker-container-ballast")
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start 实现 container.Tracer
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...container.Attribute) (context.Context, container.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttributes(attrs ...container.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
+
+// convert 将 container.Attribute 转换为 OpenTelemetry 的属性，不支持的类型按照 fmt.Sprint 转换为字符串
+func convert(attrs []container.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
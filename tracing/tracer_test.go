@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	container "github.com/mayooot/docker-container-ballast"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := New(provider.Tracer("test"))
+
+	ctx, stop := tracer.Start(context.Background(), "ballast.stop", container.Attribute{Key: "container.name", Value: "web"})
+	_, exec := tracer.Start(ctx, "ballast.exec", container.Attribute{Key: "exec.command", Value: "df -P -B1 /"})
+	exec.SetAttributes(container.Attribute{Key: "exec.exit_code", Value: 0})
+	exec.End()
+	stop.SetAttributes(container.Attribute{Key: "ballast.freed_bytes", Value: int64(300000000)})
+	stop.RecordError(errors.New("stop failed"))
+	stop.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended %d spans, want 2", len(spans))
+	}
+	execSpan, stopSpan := spans[0], spans[1]
+	if execSpan.Parent().SpanID() != stopSpan.SpanContext().SpanID() {
+		t.Fatal("exec span should be a child of the stop span")
+	}
+
+	wantAttrs := map[attribute.Key]attribute.Value{
+		"container.name":      attribute.StringValue("web"),
+		"ballast.freed_bytes": attribute.Int64Value(300000000),
+	}
+	for _, kv := range stopSpan.Attributes() {
+		if want, ok := wantAttrs[kv.Key]; ok && kv.Value != want {
+			t.Errorf("attribute %s = %v, want %v", kv.Key, kv.Value.Emit(), want.Emit())
+		}
+		delete(wantAttrs, kv.Key)
+	}
+	if len(wantAttrs) != 0 {
+		t.Errorf("missing attributes %v", wantAttrs)
+	}
+	if stopSpan.Status().Code != codes.Error || len(stopSpan.Events()) != 1 {
+		t.Fatalf("stop span status = %+v, events = %v, want the error recorded", stopSpan.Status(), stopSpan.Events())
+	}
+}
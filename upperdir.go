@@ -0,0 +1,78 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types"
+)
+
+// WithUpperDirAccess 设置是否允许在宿主机上直接修改容器 overlay 的 upperdir，默认关闭
+//
+// 暂停的容器无法执行命令，开启后 ShrinkBallast 会在宿主机上找到 GraphDriver.Data["UpperDir"] 中的 /ballast 并将其截断。
+// 需要当前进程与 Docker daemon 在同一台宿主机上，并且有权限读写 /var/lib/docker（通常需要 root），
+// 只支持 overlay2 等会返回 UpperDir 的存储驱动，不支持 BackendLoop。容器没有被暂停时仍然在容器内执行命令。
+func WithUpperDirAccess(enabled bool) Option {
+	return func(dc *DockerContainer) {
+		dc.upperDirAccess = enabled
+	}
+}
+
+// upperDirOf 返回容器可写层在宿主机上的路径，存储驱动没有提供 UpperDir 时返回 ErrUpperDirUnavailable
+func upperDirOf(containerInspect types.ContainerJSON) (string, error) {
+	if containerInspect.GraphDriver.Data != nil {
+		if dir := containerInspect.GraphDriver.Data["UpperDir"]; dir != "" {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: storage driver %q", ErrUpperDirUnavailable, containerInspect.GraphDriver.Name)
+}
+
+// shrinkBallastOnHost 在宿主机上将 upperDir 中 path 处的 /ballast 减少 reductionBytes 字节，返回调整前后的大小
+//
+// 与 shrinkBallast 不同，保留的分片直接被截断，不需要重新创建。
+func (dc *DockerContainer) shrinkBallastOnHost(upperDir, path string, reductionBytes int64) (oldSize, newSize int64, err error) {
+	if dc.backend == BackendLoop {
+		return 0, 0, fmt.Errorf("cannot shrink %s from the host with the loop backend", path)
+	}
+
+	var sizes []int64
+	for i := 0; ; i++ {
+		fi, err := os.Stat(filepath.Join(upperDir, dc.chunkPath(path, i)))
+		if errors.Is(err, fs.ErrNotExist) {
+			if i == 0 {
+				return 0, 0, fmt.Errorf("%w: %s", ErrBallastNotFound, path)
+			}
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		sizes = append(sizes, fi.Size())
+		oldSize += fi.Size()
+		if dc.chunkSize <= 0 {
+			break
+		}
+	}
+
+	newSize = shrunkBallastSize(oldSize, reductionBytes)
+	newLayout := ballastLayout(newSize, dc.chunkSize)
+	// 从最后一个分片开始删除，失败时之前的分片仍然完整
+	for i := len(sizes) - 1; i >= 0; i-- {
+		hostPath := filepath.Join(upperDir, dc.chunkPath(path, i))
+		switch {
+		case i >= len(newLayout):
+			if err := os.Remove(hostPath); err != nil {
+				return oldSize, oldSize, fmt.Errorf("failed to remove ballast file: %w", err)
+			}
+		case newLayout[i] != sizes[i]:
+			if err := os.Truncate(hostPath, newLayout[i]); err != nil {
+				return oldSize, oldSize, fmt.Errorf("failed to truncate ballast file: %w", err)
+			}
+		}
+	}
+	return oldSize, newSize, nil
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hostBallast 在 upperDir 中创建稀疏的 /ballast 分片，模拟宿主机上容器的可写层
+func hostBallast(t *testing.T, dc *DockerContainer, upperDir string, sizes ...int64) {
+	t.Helper()
+	for i, size := range sizes {
+		f, err := os.Create(filepath.Join(upperDir, dc.chunkPath("/ballast", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Truncate(size); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func hostFileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return -1
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
+
+func TestShrinkPausedBallast(t *testing.T) {
+	api := newFakeDockerAPI()
+	upperDir := t.TempDir()
+	dc := newTestContainer(api, WithUpperDirAccess(true))
+	ctx := context.Background()
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+	c := api.container("test")
+	c.json.GraphDriver.Name = "overlay2"
+	c.json.GraphDriver.Data = map[string]string{"UpperDir": upperDir}
+	hostBallast(t, dc, upperDir, 5*gigabyte)
+
+	if err := dc.Pause(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.ShrinkBallast(ctx, "test", 2*gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if got := hostFileSize(t, filepath.Join(upperDir, "ballast")); got != 3*gigabyte {
+		t.Fatalf("ballast on the host = %d, want %d", got, 3*gigabyte)
+	}
+	if c.ballast != 5*gigabyte {
+		t.Fatal("paused container should not be adjusted by exec")
+	}
+
+	// 没有开启时不会修改宿主机上的文件
+	err := newTestContainer(api).ShrinkBallast(ctx, "test", gigabyte)
+	if !errors.Is(err, ErrContainerNotRunning) {
+		t.Fatalf("ShrinkBallast() error = %v, want ErrContainerNotRunning", err)
+	}
+
+	// 存储驱动没有提供 UpperDir
+	c.json.GraphDriver.Data = nil
+	if err := dc.ShrinkBallast(ctx, "test", gigabyte); !errors.Is(err, ErrUpperDirUnavailable) {
+		t.Fatalf("ShrinkBallast() error = %v, want ErrUpperDirUnavailable", err)
+	}
+
+	// 恢复后仍然在容器内执行命令
+	if err := dc.Unpause(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.ShrinkBallast(ctx, "test", gigabyte); err != nil {
+		t.Fatal(err)
+	}
+	if c.ballast != 4*gigabyte {
+		t.Fatalf("ballast = %d, want %d", c.ballast, 4*gigabyte)
+	}
+}
+
+func TestShrinkBallastOnHostChunks(t *testing.T) {
+	upperDir := t.TempDir()
+	dc := &DockerContainer{chunkSize: 2 * gigabyte}
+	hostBallast(t, dc, upperDir, 2*gigabyte, 2*gigabyte, gigabyte)
+
+	oldSize, newSize, err := dc.shrinkBallastOnHost(upperDir, "/ballast", 2500*megabyte)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldSize != 5*gigabyte || newSize != 2500*megabyte {
+		t.Fatalf("shrinkBallastOnHost() = %d, %d", oldSize, newSize)
+	}
+	for i, want := range []int64{2 * gigabyte, 500 * megabyte, -1} {
+		path := filepath.Join(upperDir, dc.chunkPath("/ballast", i))
+		if got := hostFileSize(t, path); got != want {
+			t.Fatalf("size of %s = %d, want %d", path, got, want)
+		}
+	}
+
+	if _, _, err := dc.shrinkBallastOnHost(t.TempDir(), "/ballast", gigabyte); !errors.Is(err, ErrBallastNotFound) {
+		t.Fatalf("shrinkBallastOnHost() error = %v, want ErrBallastNotFound", err)
+	}
+}
@@ -0,0 +1,26 @@
+package container
+
+import "context"
+
+// defaultExecUser 在容器内执行 /ballast 相关命令时默认使用的用户，创建和截断根目录下的 /ballast 通常需要 root
+const defaultExecUser = "root"
+
+// WithExecUser 设置在容器内执行 df、fallocate 等 /ballast 相关命令时使用的用户，默认 root，
+// 为空时使用容器主进程的用户
+//
+// 镜像通过 USER 指定了非 root 用户时，以主进程的用户执行命令通常没有权限创建 /ballast。
+// 新创建的容器会将用户记录在 exec_user 标签中，之后修改该选项不会影响已经创建的容器。
+func WithExecUser(user string) Option {
+	return func(dc *DockerContainer) {
+		dc.execUser = user
+	}
+}
+
+// execUserOf 返回在容器 containerID 内执行 /ballast 相关命令时使用的用户，
+// 旧版本创建的容器没有 exec_user 标签时使用 WithExecUser 设置的用户
+func (dc *DockerContainer) execUserOf(ctx context.Context, containerID string) string {
+	if user, ok := dc.labelValue(dc.containerLabels(ctx, containerID), labelExecUser); ok {
+		return user
+	}
+	return dc.execUser
+}
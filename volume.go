@@ -0,0 +1,56 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	pathpkg "path"
+	"strings"
+)
+
+// rootMount 是 /ballast 默认所在的挂载点，即容器的系统盘
+const rootMount = "/"
+
+// validateBallastMount 校验 opts.BallastMount，必须是 Mounts 中某个挂载的 Target，并且 BallastPath 位于该挂载点下
+func validateBallastMount(opts RunOptions) error {
+	if opts.BallastMount == rootMount {
+		return nil
+	}
+	if !pathpkg.IsAbs(opts.BallastMount) || pathpkg.Clean(opts.BallastMount) != opts.BallastMount {
+		return fmt.Errorf("%w: invalid ballast mount %q, must be a clean absolute path", ErrInvalidArgument, opts.BallastMount)
+	}
+	found := false
+	for _, m := range opts.Mounts {
+		if m.Target == opts.BallastMount {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: invalid ballast mount %q, no mount has this target", ErrInvalidArgument, opts.BallastMount)
+	}
+	if !strings.HasPrefix(opts.BallastPath, opts.BallastMount+"/") {
+		return fmt.Errorf("%w: ballast path %s is not in ballast mount %s", ErrInvalidArgument, opts.BallastPath, opts.BallastMount)
+	}
+	return nil
+}
+
+// mountOf 从容器的 mount 标签中读取 /ballast 所在的挂载点，旧版本创建的容器没有该标签时为根目录
+func (dc *DockerContainer) mountOf(labels map[string]string) string {
+	if v, ok := dc.labelValue(labels, labelMount); ok && v != "" {
+		return v
+	}
+	return rootMount
+}
+
+// dfTarget 返回 df 检查 /ballast 所在文件系统时使用的路径，即 /ballast 所在的目录
+//
+// df 报告的是路径所在的文件系统，/ballast 位于单独挂载的目录（例如没有设置 BallastMount 的 /data）时，
+// 释放的空间与 df 看到的剩余空间仍然在同一个文件系统上。该目录总是位于 mount 标签记录的挂载点内。
+func (dc *DockerContainer) dfTarget(labels map[string]string) string {
+	return pathpkg.Dir(dc.ballastPathOf(labels))
+}
+
+// dfTargetOfContainer 返回容器 containerID 中 df 使用的路径
+func (dc *DockerContainer) dfTargetOfContainer(ctx context.Context, containerID string) string {
+	return dc.dfTarget(dc.containerLabels(ctx, containerID))
+}
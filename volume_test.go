@@ -0,0 +1,114 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+func TestBallastMount(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	opts := RunOptions{
+		Name:         "test",
+		Mounts:       []mount.Mount{{Type: mount.TypeVolume, Source: "data", Target: "/data"}},
+		BallastMount: "/data",
+	}
+	if _, err := dc.RunWithOptions(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	c := api.container("test")
+	labels := c.json.Config.Labels
+	if labels[defaultLabelPrefix+labelMount] != "/data" || labels[defaultLabelPrefix+labelBallastPath] != "/data/ballast" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if _, ok := c.hostConfig.StorageOpt["size"]; ok {
+		t.Fatalf("storage-opt size should not be set for a ballast on a volume, got %v", c.hostConfig.StorageOpt)
+	}
+	if c.fileSize("/data/ballast") <= 0 {
+		t.Fatal("ballast should be created on the volume")
+	}
+
+	// Stop 使用 df 检查卷的剩余空间，而不是系统盘
+	api.commands = nil
+	if err := dc.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	var dfs []string
+	for _, cmd := range api.commands {
+		if cmd[0] == "df" {
+			dfs = append(dfs, strings.Join(cmd, " "))
+		}
+	}
+	if len(dfs) == 0 {
+		t.Fatal("Stop should check the disk usage")
+	}
+	for _, df := range dfs {
+		if df != "df -P -B1 /data" {
+			t.Fatalf("unexpected df command %q", df)
+		}
+	}
+}
+
+func TestValidateBallastMount(t *testing.T) {
+	mounts := []mount.Mount{{Type: mount.TypeBind, Source: "/srv/data", Target: "/data"}}
+	tests := []struct {
+		name    string
+		opts    RunOptions
+		wantErr bool
+	}{
+		{"root", RunOptions{BallastMount: rootMount, BallastPath: "/ballast"}, false},
+		{"volume", RunOptions{Mounts: mounts, BallastMount: "/data", BallastPath: "/data/sub/ballast"}, false},
+		{"unknown mount", RunOptions{Mounts: mounts, BallastMount: "/other", BallastPath: "/other/ballast"}, true},
+		{"outside the mount", RunOptions{Mounts: mounts, BallastMount: "/data", BallastPath: "/database/ballast"}, true},
+		{"relative", RunOptions{Mounts: mounts, BallastMount: "data", BallastPath: "data/ballast"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateBallastMount(tt.opts); (err != nil) != tt.wantErr {
+				t.Fatalf("validateBallastMount() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiskUsageFollowsBallastPath(t *testing.T) {
+	// /data 是单独挂载的文件系统，只有 /ballast 和 19.5GB 的其他数据，系统盘的使用量不随 /ballast 变化
+	const dataPath = "/data/ballast"
+	api := newFakeDockerAPI()
+	api.execFn = func(c *fakeContainer, cmd []string) (fakeExecResult, bool) {
+		if cmd[0] != "df" {
+			return fakeExecResult{}, false
+		}
+		total, used := c.limit(), c.limit()
+		if cmd[len(cmd)-1] == "/data" {
+			used = 19500*megabyte + max(c.fileSize(dataPath), 0)
+		}
+		return fakeExecResult{stdout: fmt.Sprintf("Filesystem 1-blocks Used Available Capacity Mounted on\n/dev/sdb %d %d %d 0%% /data\n",
+			total, used, total-used)}, true
+	}
+	dc := newTestContainer(api, WithBallastPath(dataPath))
+	opts := RunOptions{Name: "test", Mounts: []mount.Mount{{Type: mount.TypeBind, Source: "/srv/data", Target: "/data"}}}
+	if _, err := dc.RunWithOptions(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 减小 /ballast 后 df /data 的剩余空间随之增加，调整在达到 targetFree 后停止，不会删除整个 /ballast
+	result, err := dc.StopWithResult(context.Background(), "test")
+	if err != nil || result.AdjustError != nil {
+		t.Fatalf("StopWithResult() = %+v, %v", result, err)
+	}
+	size := api.container("test").fileSize(dataPath)
+	if !result.Adjusted || size <= 0 || size >= int64(ballastSize) {
+		t.Fatalf("ballast size after stop = %d, want partly reduced", size)
+	}
+	for _, cmd := range api.commands {
+		if cmd[0] == "df" && cmd[len(cmd)-1] != "/data" {
+			t.Fatalf("unexpected df command %q", cmd)
+		}
+	}
+}
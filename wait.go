@@ -0,0 +1,48 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// WaitStopped 阻塞直到容器停止运行，返回容器的退出码，容器已经停止时立即返回
+func (dc *DockerContainer) WaitStopped(ctx context.Context, name string) (_ int64, err error) {
+	defer wrapOp("wait", name, &err)
+
+	exitCode, err := dc.wait(ctx, name, container.WaitConditionNotRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to wait for container %s to stop: %w", name, wrapNotFound(err))
+	}
+	return exitCode, nil
+}
+
+// WaitRemoved 阻塞直到容器被删除，容器已经不存在时立即返回
+func (dc *DockerContainer) WaitRemoved(ctx context.Context, name string) (err error) {
+	defer wrapOp("wait", name, &err)
+
+	if _, err := dc.wait(ctx, name, container.WaitConditionRemoved); err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to wait for container %s to be removed: %w", name, err)
+	}
+	return nil
+}
+
+// wait 调用 ContainerWait 等待容器达到 condition，返回容器的退出码
+func (dc *DockerContainer) wait(ctx context.Context, name string, condition container.WaitCondition) (int64, error) {
+	respCh, errCh := dc.cli.ContainerWait(ctx, name, condition)
+	select {
+	case resp := <-respCh:
+		if resp.Error != nil && resp.Error.Message != "" {
+			return resp.StatusCode, errors.New(resp.Error.Message)
+		}
+		return resp.StatusCode, nil
+	case err := <-errCh:
+		return 0, err
+	}
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitStopped(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		c := api.containers["test"]
+		c.json.State.Running = false
+		c.json.State.Status = "exited"
+		c.json.State.ExitCode = 137
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	exitCode, err := dc.WaitStopped(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 137 {
+		t.Fatalf("exit code = %d, want 137", exitCode)
+	}
+
+	if _, err := dc.WaitStopped(ctx, "missing"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestWaitRemoved(t *testing.T) {
+	api := newFakeDockerAPI()
+	dc := newTestContainer(api)
+	if _, err := dc.Run("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := dc.WaitRemoved(ctx, "test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded while container exists, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = dc.ForceRemove("test")
+	}()
+	if err := dc.WaitRemoved(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.WaitRemoved(context.Background(), "test"); err != nil {
+		t.Fatalf("waiting for a removed container should succeed, got %v", err)
+	}
+}